@@ -13,3 +13,604 @@ const (
 	IngressClassKey     = "kubernetes.io/ingress.class"
 	DefaultIngressClass = "sail"
 )
+
+const (
+	LocalityWeightedLbKey = "/locality-weighted-lb"
+	DNSDiscoveryKey       = "/dns-discovery"
+	DNSLookupFamilyKey    = "/dns-lookup-family"
+
+	LbPolicyKey                 = "/lb-policy"
+	HashOnKey                   = "/hash-on"
+	HashOnHeaderKey             = "/hash-on-header"
+	HashOnCookieKey             = "/hash-on-cookie"
+	HashOnCookieTTLKey          = "/hash-on-cookie-ttl"
+	ConnectTimeoutKey           = "/connect-timeout"
+	MaxRequestsPerConnectionKey = "/max-requests-per-connection"
+	MaxConnectionsKey           = "/max-connections"
+	MaxPendingRequestsKey       = "/max-pending-requests"
+	MaxRequestsKey              = "/max-requests"
+	MaxRetriesKey               = "/max-retries"
+
+	HealthCheckKey                   = "/health-check"
+	HealthCheckPathKey               = "/health-check-path"
+	HealthCheckIntervalKey           = "/health-check-interval"
+	HealthCheckTimeoutKey            = "/health-check-timeout"
+	HealthCheckHealthyThresholdKey   = "/health-check-healthy-threshold"
+	HealthCheckUnhealthyThresholdKey = "/health-check-unhealthy-threshold"
+
+	OutlierConsecutive5xxKey     = "/outlier-consecutive-5xx"
+	OutlierIntervalKey           = "/outlier-interval"
+	OutlierBaseEjectionTimeKey   = "/outlier-base-ejection-time"
+	OutlierMaxEjectionPercentKey = "/outlier-max-ejection-percent"
+
+	RequestTimeoutKey       = "/request-timeout"
+	IdleTimeoutKey          = "/idle-timeout"
+	RetryOnKey              = "/retry-on"
+	NumRetriesKey           = "/num-retries"
+	PerTryTimeoutKey        = "/per-try-timeout"
+	RetryBackoffBaseKey     = "/retry-backoff-base-interval"
+	RetryBackoffMaxKey      = "/retry-backoff-max-interval"
+	HedgeOnPerTryTimeoutKey = "/hedge-on-per-try-timeout"
+
+	UpstreamTLSKey              = "/upstream-tls"
+	UpstreamSNIKey              = "/upstream-sni"
+	UpstreamTLSVerifyKey        = "/upstream-tls-verify"
+	UpstreamCASecretKey         = "/upstream-ca-secret"
+	UpstreamCAConfigMapKey      = "/upstream-ca-configmap"
+	UpstreamClientCertSecretKey = "/upstream-client-cert-secret"
+
+	AuthTLSSecretKey       = "/auth-tls-secret"
+	AuthTLSVerifyClientKey = "/auth-tls-verify-client"
+	AuthTLSVerifyDepthKey  = "/auth-tls-verify-depth"
+
+	SSLRedirectKey           = "/ssl-redirect"
+	SSLRedirectCodeKey       = "/ssl-redirect-code"
+	HSTSKey                  = "/hsts"
+	HSTSMaxAgeKey            = "/hsts-max-age"
+	HSTSIncludeSubDomainsKey = "/hsts-include-subdomains"
+	HSTSPreloadKey           = "/hsts-preload"
+
+	RewriteTargetKey = "/rewrite-target"
+	RewriteRegexKey  = "/rewrite-regex"
+	StripPrefixKey   = "/strip-prefix"
+	HostRewriteKey   = "/host-rewrite"
+
+	// header policies are configured through the -add, -set and -remove variants of these keys
+	RequestHeadersKey      = "/request-headers"
+	ResponseHeadersKey     = "/response-headers"
+	HostRequestHeadersKey  = "/host-request-headers"
+	HostResponseHeadersKey = "/host-response-headers"
+
+	EnableCorsKey           = "/enable-cors"
+	CorsAllowOriginKey      = "/cors-allow-origin"
+	CorsAllowOriginRegexKey = "/cors-allow-origin-regex"
+	CorsAllowMethodsKey     = "/cors-allow-methods"
+	CorsAllowHeadersKey     = "/cors-allow-headers"
+	CorsExposeHeadersKey    = "/cors-expose-headers"
+	CorsMaxAgeKey           = "/cors-max-age"
+	CorsAllowCredentialsKey = "/cors-allow-credentials"
+
+	LocalRateLimitRequestsKey = "/local-ratelimit-requests"
+	LocalRateLimitIntervalKey = "/local-ratelimit-interval"
+	LocalRateLimitBurstKey    = "/local-ratelimit-burst"
+	GlobalRateLimitKey        = "/global-ratelimit-descriptors"
+
+	ExtAuthzServiceKey          = "/ext-authz-service"
+	ExtAuthzProtocolKey         = "/ext-authz-protocol"
+	ExtAuthzPathPrefixKey       = "/ext-authz-path-prefix"
+	ExtAuthzTimeoutKey          = "/ext-authz-timeout"
+	ExtAuthzFailureModeAllowKey = "/ext-authz-failure-mode-allow"
+	ExtAuthzRequestHeadersKey   = "/ext-authz-request-headers"
+	ExtAuthzUpstreamHeadersKey  = "/ext-authz-upstream-headers"
+	ExtAuthzSkipPathsKey        = "/ext-authz-skip-paths"
+
+	JWTIssuerKey            = "/jwt-issuer"
+	JWTAudiencesKey         = "/jwt-audiences"
+	JWTJWKSURIKey           = "/jwt-jwks-uri"
+	JWTJWKSSecretKey        = "/jwt-jwks-secret"
+	JWTJWKSCacheDurationKey = "/jwt-jwks-cache-duration"
+	JWTForwardKey           = "/jwt-forward"
+	JWTClaimHeadersKey      = "/jwt-claim-headers"
+	JWTSkipPathsKey         = "/jwt-skip-paths"
+
+	BasicAuthSecretKey = "/basic-auth-secret"
+	BasicAuthRealmKey  = "/basic-auth-realm"
+
+	AllowlistSourceRangeKey = "/allowlist-source-range"
+	DenylistSourceRangeKey  = "/denylist-source-range"
+
+	AccessLogPathKey       = "/access-log-path"
+	AccessLogFormatTypeKey = "/access-log-format-type"
+	AccessLogFormatKey     = "/access-log-format"
+	AccessLogServiceKey    = "/access-log-service"
+
+	TracingSamplingKey = "/tracing-sampling"
+	TracingTagsKey     = "/tracing-tags"
+
+	CompressionKey      = "/compression"
+	MaxRequestBytesKey  = "/max-request-bytes"
+	MaxResponseBytesKey = "/max-response-bytes"
+
+	WebSocketKey    = "/websocket"
+	AllowConnectKey = "/allow-connect"
+
+	ErrorPagesKey            = "/error-pages"
+	ErrorPagesConfigMapKey   = "/error-pages-configmap"
+	ErrorPagesContentTypeKey = "/error-pages-content-type"
+	DirectResponseStatusKey  = "/direct-response-status"
+	DirectResponseBodyKey    = "/direct-response-body"
+	DefaultResponseStatusKey = "/default-response-status"
+	DefaultResponseBodyKey   = "/default-response-body"
+)
+
+func ExtractLocalityWeightedLb(anns map[string]string) bool {
+	return anns[AnnotationPrefix+LocalityWeightedLbKey] == "true"
+}
+
+func ExtractDNSDiscovery(anns map[string]string) string {
+	return anns[AnnotationPrefix+DNSDiscoveryKey]
+}
+
+func ExtractDNSLookupFamily(anns map[string]string) string {
+	return anns[AnnotationPrefix+DNSLookupFamilyKey]
+}
+
+func ExtractLbPolicy(anns map[string]string) string {
+	return anns[AnnotationPrefix+LbPolicyKey]
+}
+
+func ExtractHashOn(anns map[string]string) string {
+	return anns[AnnotationPrefix+HashOnKey]
+}
+
+func ExtractHashOnHeader(anns map[string]string) string {
+	return anns[AnnotationPrefix+HashOnHeaderKey]
+}
+
+func ExtractHashOnCookie(anns map[string]string) string {
+	return anns[AnnotationPrefix+HashOnCookieKey]
+}
+
+func ExtractHashOnCookieTTL(anns map[string]string) string {
+	return anns[AnnotationPrefix+HashOnCookieTTLKey]
+}
+
+func ExtractConnectTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+ConnectTimeoutKey]
+}
+
+func ExtractMaxRequestsPerConnection(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxRequestsPerConnectionKey]
+}
+
+func ExtractMaxConnections(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxConnectionsKey]
+}
+
+func ExtractMaxPendingRequests(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxPendingRequestsKey]
+}
+
+func ExtractMaxRequests(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxRequestsKey]
+}
+
+func ExtractMaxRetries(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxRetriesKey]
+}
+
+func ExtractHealthCheck(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckKey]
+}
+
+func ExtractHealthCheckPath(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckPathKey]
+}
+
+func ExtractHealthCheckInterval(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckIntervalKey]
+}
+
+func ExtractHealthCheckTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckTimeoutKey]
+}
+
+func ExtractHealthCheckHealthyThreshold(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckHealthyThresholdKey]
+}
+
+func ExtractHealthCheckUnhealthyThreshold(anns map[string]string) string {
+	return anns[AnnotationPrefix+HealthCheckUnhealthyThresholdKey]
+}
+
+func ExtractOutlierConsecutive5xx(anns map[string]string) string {
+	return anns[AnnotationPrefix+OutlierConsecutive5xxKey]
+}
+
+func ExtractOutlierInterval(anns map[string]string) string {
+	return anns[AnnotationPrefix+OutlierIntervalKey]
+}
+
+func ExtractOutlierBaseEjectionTime(anns map[string]string) string {
+	return anns[AnnotationPrefix+OutlierBaseEjectionTimeKey]
+}
+
+func ExtractOutlierMaxEjectionPercent(anns map[string]string) string {
+	return anns[AnnotationPrefix+OutlierMaxEjectionPercentKey]
+}
+
+func ExtractRequestTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+RequestTimeoutKey]
+}
+
+func ExtractIdleTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+IdleTimeoutKey]
+}
+
+func ExtractRetryOn(anns map[string]string) string {
+	return anns[AnnotationPrefix+RetryOnKey]
+}
+
+func ExtractNumRetries(anns map[string]string) string {
+	return anns[AnnotationPrefix+NumRetriesKey]
+}
+
+func ExtractPerTryTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+PerTryTimeoutKey]
+}
+
+func ExtractRetryBackoffBase(anns map[string]string) string {
+	return anns[AnnotationPrefix+RetryBackoffBaseKey]
+}
+
+func ExtractRetryBackoffMax(anns map[string]string) string {
+	return anns[AnnotationPrefix+RetryBackoffMaxKey]
+}
+
+func ExtractHedgeOnPerTryTimeout(anns map[string]string) bool {
+	return anns[AnnotationPrefix+HedgeOnPerTryTimeoutKey] == "true"
+}
+
+func ExtractUpstreamTLS(anns map[string]string) bool {
+	return anns[AnnotationPrefix+UpstreamTLSKey] == "true"
+}
+
+func ExtractUpstreamSNI(anns map[string]string) string {
+	return anns[AnnotationPrefix+UpstreamSNIKey]
+}
+
+// ExtractUpstreamTLSVerify reports whether the servers of a Service are verified, which
+// is the default: against the CA of the upstream CA annotations, else against the CA
+// bundle of the system.
+func ExtractUpstreamTLSVerify(anns map[string]string) bool {
+	return anns[AnnotationPrefix+UpstreamTLSVerifyKey] != "false"
+}
+
+func ExtractUpstreamCASecret(anns map[string]string) string {
+	return anns[AnnotationPrefix+UpstreamCASecretKey]
+}
+
+func ExtractUpstreamCAConfigMap(anns map[string]string) string {
+	return anns[AnnotationPrefix+UpstreamCAConfigMapKey]
+}
+
+func ExtractUpstreamClientCertSecret(anns map[string]string) string {
+	return anns[AnnotationPrefix+UpstreamClientCertSecretKey]
+}
+
+func ExtractAuthTLSSecret(anns map[string]string) string {
+	return anns[AnnotationPrefix+AuthTLSSecretKey]
+}
+
+func ExtractAuthTLSVerifyClient(anns map[string]string) string {
+	return anns[AnnotationPrefix+AuthTLSVerifyClientKey]
+}
+
+func ExtractAuthTLSVerifyDepth(anns map[string]string) string {
+	return anns[AnnotationPrefix+AuthTLSVerifyDepthKey]
+}
+
+// ExtractSSLRedirect reports whether plaintext requests to the TLS hosts of an Ingress
+// are redirected to HTTPS, which is the default. Hosts requiring client certificates
+// are always redirected.
+func ExtractSSLRedirect(anns map[string]string) bool {
+	return anns[AnnotationPrefix+SSLRedirectKey] != "false"
+}
+
+func ExtractSSLRedirectCode(anns map[string]string) string {
+	return anns[AnnotationPrefix+SSLRedirectCodeKey]
+}
+
+func ExtractHSTS(anns map[string]string) bool {
+	return anns[AnnotationPrefix+HSTSKey] == "true"
+}
+
+func ExtractHSTSMaxAge(anns map[string]string) string {
+	return anns[AnnotationPrefix+HSTSMaxAgeKey]
+}
+
+func ExtractHSTSIncludeSubDomains(anns map[string]string) bool {
+	return anns[AnnotationPrefix+HSTSIncludeSubDomainsKey] == "true"
+}
+
+func ExtractHSTSPreload(anns map[string]string) bool {
+	return anns[AnnotationPrefix+HSTSPreloadKey] == "true"
+}
+
+func ExtractRewriteTarget(anns map[string]string) string {
+	return anns[AnnotationPrefix+RewriteTargetKey]
+}
+
+func ExtractRewriteRegex(anns map[string]string) string {
+	return anns[AnnotationPrefix+RewriteRegexKey]
+}
+
+func ExtractStripPrefix(anns map[string]string) bool {
+	return anns[AnnotationPrefix+StripPrefixKey] == "true"
+}
+
+func ExtractHostRewrite(anns map[string]string) string {
+	return anns[AnnotationPrefix+HostRewriteKey]
+}
+
+// ExtractHeadersToAdd returns the headers to append, one "Name: value" per line.
+func ExtractHeadersToAdd(anns map[string]string, key string) string {
+	return anns[AnnotationPrefix+key+"-add"]
+}
+
+// ExtractHeadersToSet returns the headers to overwrite, one "Name: value" per line.
+func ExtractHeadersToSet(anns map[string]string, key string) string {
+	return anns[AnnotationPrefix+key+"-set"]
+}
+
+// ExtractHeadersToRemove returns a comma separated list of header names.
+func ExtractHeadersToRemove(anns map[string]string, key string) string {
+	return anns[AnnotationPrefix+key+"-remove"]
+}
+
+func ExtractEnableCors(anns map[string]string) bool {
+	return anns[AnnotationPrefix+EnableCorsKey] == "true"
+}
+
+func ExtractCorsAllowOrigin(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsAllowOriginKey]
+}
+
+func ExtractCorsAllowOriginRegex(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsAllowOriginRegexKey]
+}
+
+func ExtractCorsAllowMethods(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsAllowMethodsKey]
+}
+
+func ExtractCorsAllowHeaders(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsAllowHeadersKey]
+}
+
+func ExtractCorsExposeHeaders(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsExposeHeadersKey]
+}
+
+func ExtractCorsMaxAge(anns map[string]string) string {
+	return anns[AnnotationPrefix+CorsMaxAgeKey]
+}
+
+func ExtractCorsAllowCredentials(anns map[string]string) bool {
+	return anns[AnnotationPrefix+CorsAllowCredentialsKey] == "true"
+}
+
+func ExtractLocalRateLimitRequests(anns map[string]string) string {
+	return anns[AnnotationPrefix+LocalRateLimitRequestsKey]
+}
+
+func ExtractLocalRateLimitInterval(anns map[string]string) string {
+	return anns[AnnotationPrefix+LocalRateLimitIntervalKey]
+}
+
+func ExtractLocalRateLimitBurst(anns map[string]string) string {
+	return anns[AnnotationPrefix+LocalRateLimitBurstKey]
+}
+
+// ExtractGlobalRateLimit returns a comma separated list of remote_address, path and
+// header:<name> entries making up the descriptor sent to the rate limit service.
+func ExtractGlobalRateLimit(anns map[string]string) string {
+	return anns[AnnotationPrefix+GlobalRateLimitKey]
+}
+
+// ExtractExtAuthzService returns the authorization service as namespace/name:port, or
+// name:port for a Service in the namespace of the Ingress.
+func ExtractExtAuthzService(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzServiceKey]
+}
+
+// ExtractExtAuthzProtocol returns grpc or http, defaulting to grpc.
+func ExtractExtAuthzProtocol(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzProtocolKey]
+}
+
+func ExtractExtAuthzPathPrefix(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzPathPrefixKey]
+}
+
+func ExtractExtAuthzTimeout(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzTimeoutKey]
+}
+
+func ExtractExtAuthzFailureModeAllow(anns map[string]string) bool {
+	return anns[AnnotationPrefix+ExtAuthzFailureModeAllowKey] == "true"
+}
+
+func ExtractExtAuthzRequestHeaders(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzRequestHeadersKey]
+}
+
+func ExtractExtAuthzUpstreamHeaders(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzUpstreamHeadersKey]
+}
+
+// ExtractExtAuthzSkipPaths returns a comma separated list of Ingress paths that are
+// not sent to the authorization service.
+func ExtractExtAuthzSkipPaths(anns map[string]string) string {
+	return anns[AnnotationPrefix+ExtAuthzSkipPathsKey]
+}
+
+// ExtractJWTIssuer returns the issuer whose tokens the routes of the Ingress require.
+func ExtractJWTIssuer(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTIssuerKey]
+}
+
+func ExtractJWTAudiences(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTAudiencesKey]
+}
+
+// ExtractJWTJWKSURI returns the http or https URI the JWKS of the issuer is fetched
+// from.
+func ExtractJWTJWKSURI(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTJWKSURIKey]
+}
+
+// ExtractJWTJWKSSecret returns the name of a Secret holding the JWKS under the jwks key.
+func ExtractJWTJWKSSecret(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTJWKSSecretKey]
+}
+
+func ExtractJWTJWKSCacheDuration(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTJWKSCacheDurationKey]
+}
+
+func ExtractJWTForward(anns map[string]string) bool {
+	return anns[AnnotationPrefix+JWTForwardKey] == "true"
+}
+
+// ExtractJWTClaimHeaders returns a comma separated list of claim:header entries
+// copying claims of the token to request headers.
+func ExtractJWTClaimHeaders(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTClaimHeadersKey]
+}
+
+// ExtractJWTSkipPaths returns a comma separated list of Ingress paths that do not
+// require a token.
+func ExtractJWTSkipPaths(anns map[string]string) string {
+	return anns[AnnotationPrefix+JWTSkipPathsKey]
+}
+
+// ExtractBasicAuthSecret returns the name of a Secret holding htpasswd entries under
+// the auth key. Only {SHA} entries, as written by htpasswd -s, are supported: Envoy
+// cannot check bcrypt, MD5 or crypt hashes, so users with such entries cannot log in.
+func ExtractBasicAuthSecret(anns map[string]string) string {
+	return anns[AnnotationPrefix+BasicAuthSecretKey]
+}
+
+func ExtractBasicAuthRealm(anns map[string]string) string {
+	return anns[AnnotationPrefix+BasicAuthRealmKey]
+}
+
+// ExtractAllowlistSourceRange returns a comma separated list of the CIDRs or addresses
+// of the only clients allowed.
+func ExtractAllowlistSourceRange(anns map[string]string) string {
+	return anns[AnnotationPrefix+AllowlistSourceRangeKey]
+}
+
+// ExtractDenylistSourceRange returns a comma separated list of the CIDRs or addresses
+// of rejected clients.
+func ExtractDenylistSourceRange(anns map[string]string) string {
+	return anns[AnnotationPrefix+DenylistSourceRangeKey]
+}
+
+// ExtractAccessLogPath returns the file access logs of an IngressClass are written to,
+// or off to disable them.
+func ExtractAccessLogPath(anns map[string]string) string {
+	return anns[AnnotationPrefix+AccessLogPathKey]
+}
+
+// ExtractAccessLogFormatType returns text or json.
+func ExtractAccessLogFormatType(anns map[string]string) string {
+	return anns[AnnotationPrefix+AccessLogFormatTypeKey]
+}
+
+// ExtractAccessLogFormat returns an Envoy format string, or for the json type a JSON
+// object of format strings.
+func ExtractAccessLogFormat(anns map[string]string) string {
+	return anns[AnnotationPrefix+AccessLogFormatKey]
+}
+
+// ExtractAccessLogService returns the gRPC access log service as namespace/name:port,
+// or off to disable it.
+func ExtractAccessLogService(anns map[string]string) string {
+	return anns[AnnotationPrefix+AccessLogServiceKey]
+}
+
+// ExtractTracingSampling returns the percentage of the requests of a route that are
+// traced, overriding the sampling of the controller.
+func ExtractTracingSampling(anns map[string]string) string {
+	return anns[AnnotationPrefix+TracingSamplingKey]
+}
+
+// ExtractTracingTags returns a comma separated list of name=value entries adding
+// literal tags, or name=header:<header> entries tagging with a request header.
+func ExtractTracingTags(anns map[string]string) string {
+	return anns[AnnotationPrefix+TracingTagsKey]
+}
+
+// ExtractCompression returns a comma separated list of the algorithms, gzip or brotli,
+// responses are compressed with, or off to disable compression.
+func ExtractCompression(anns map[string]string) string {
+	return anns[AnnotationPrefix+CompressionKey]
+}
+
+func ExtractMaxRequestBytes(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxRequestBytesKey]
+}
+
+func ExtractMaxResponseBytes(anns map[string]string) string {
+	return anns[AnnotationPrefix+MaxResponseBytesKey]
+}
+
+// ExtractWebSocket returns true or false to allow or forbid WebSocket upgrades,
+// which are otherwise allowed for ws and wss Service ports only.
+func ExtractWebSocket(anns map[string]string) string {
+	return anns[AnnotationPrefix+WebSocketKey]
+}
+
+// ExtractAllowConnect reports whether CONNECT requests to the hosts of the Ingress
+// are tunneled to the backend of their rule, which its paths must all share.
+func ExtractAllowConnect(anns map[string]string) bool {
+	return anns[AnnotationPrefix+AllowConnectKey] == "true"
+}
+
+// ExtractErrorPages returns one code=body entry per line, replacing the body of the
+// local replies with that status code.
+func ExtractErrorPages(anns map[string]string) string {
+	return anns[AnnotationPrefix+ErrorPagesKey]
+}
+
+// ExtractErrorPagesConfigMap returns the name of a ConfigMap whose keys are status
+// codes and whose values are the bodies of the local replies with those codes.
+func ExtractErrorPagesConfigMap(anns map[string]string) string {
+	return anns[AnnotationPrefix+ErrorPagesConfigMapKey]
+}
+
+func ExtractErrorPagesContentType(anns map[string]string) string {
+	return anns[AnnotationPrefix+ErrorPagesContentTypeKey]
+}
+
+// ExtractDirectResponseStatus returns the status code every route of the Ingress
+// answers with instead of forwarding the requests, as for maintenance.
+func ExtractDirectResponseStatus(anns map[string]string) string {
+	return anns[AnnotationPrefix+DirectResponseStatusKey]
+}
+
+func ExtractDirectResponseBody(anns map[string]string) string {
+	return anns[AnnotationPrefix+DirectResponseBodyKey]
+}
+
+// ExtractDefaultResponseStatus returns the status code of the requests to the hosts
+// of the rules of an Ingress without a default backend that match none of its paths.
+func ExtractDefaultResponseStatus(anns map[string]string) string {
+	return anns[AnnotationPrefix+DefaultResponseStatusKey]
+}
+
+func ExtractDefaultResponseBody(anns map[string]string) string {
+	return anns[AnnotationPrefix+DefaultResponseBodyKey]
+}
@@ -0,0 +1,179 @@
+package annotations
+
+import "testing"
+
+func TestStringExtractors(t *testing.T) {
+	tests := []struct {
+		key     string
+		extract func(map[string]string) string
+	}{
+		{key: "inendless.com/dns-discovery", extract: ExtractDNSDiscovery},
+		{key: "inendless.com/dns-lookup-family", extract: ExtractDNSLookupFamily},
+		{key: "inendless.com/lb-policy", extract: ExtractLbPolicy},
+		{key: "inendless.com/hash-on", extract: ExtractHashOn},
+		{key: "inendless.com/hash-on-header", extract: ExtractHashOnHeader},
+		{key: "inendless.com/hash-on-cookie", extract: ExtractHashOnCookie},
+		{key: "inendless.com/hash-on-cookie-ttl", extract: ExtractHashOnCookieTTL},
+		{key: "inendless.com/connect-timeout", extract: ExtractConnectTimeout},
+		{key: "inendless.com/max-requests-per-connection", extract: ExtractMaxRequestsPerConnection},
+		{key: "inendless.com/max-connections", extract: ExtractMaxConnections},
+		{key: "inendless.com/max-pending-requests", extract: ExtractMaxPendingRequests},
+		{key: "inendless.com/max-requests", extract: ExtractMaxRequests},
+		{key: "inendless.com/max-retries", extract: ExtractMaxRetries},
+		{key: "inendless.com/health-check", extract: ExtractHealthCheck},
+		{key: "inendless.com/health-check-path", extract: ExtractHealthCheckPath},
+		{key: "inendless.com/health-check-interval", extract: ExtractHealthCheckInterval},
+		{key: "inendless.com/health-check-timeout", extract: ExtractHealthCheckTimeout},
+		{key: "inendless.com/health-check-healthy-threshold", extract: ExtractHealthCheckHealthyThreshold},
+		{key: "inendless.com/health-check-unhealthy-threshold", extract: ExtractHealthCheckUnhealthyThreshold},
+		{key: "inendless.com/outlier-consecutive-5xx", extract: ExtractOutlierConsecutive5xx},
+		{key: "inendless.com/outlier-interval", extract: ExtractOutlierInterval},
+		{key: "inendless.com/outlier-base-ejection-time", extract: ExtractOutlierBaseEjectionTime},
+		{key: "inendless.com/outlier-max-ejection-percent", extract: ExtractOutlierMaxEjectionPercent},
+		{key: "inendless.com/request-timeout", extract: ExtractRequestTimeout},
+		{key: "inendless.com/idle-timeout", extract: ExtractIdleTimeout},
+		{key: "inendless.com/retry-on", extract: ExtractRetryOn},
+		{key: "inendless.com/num-retries", extract: ExtractNumRetries},
+		{key: "inendless.com/per-try-timeout", extract: ExtractPerTryTimeout},
+		{key: "inendless.com/retry-backoff-base-interval", extract: ExtractRetryBackoffBase},
+		{key: "inendless.com/retry-backoff-max-interval", extract: ExtractRetryBackoffMax},
+		{key: "inendless.com/upstream-sni", extract: ExtractUpstreamSNI},
+		{key: "inendless.com/upstream-ca-secret", extract: ExtractUpstreamCASecret},
+		{key: "inendless.com/upstream-ca-configmap", extract: ExtractUpstreamCAConfigMap},
+		{key: "inendless.com/upstream-client-cert-secret", extract: ExtractUpstreamClientCertSecret},
+		{key: "inendless.com/auth-tls-secret", extract: ExtractAuthTLSSecret},
+		{key: "inendless.com/auth-tls-verify-client", extract: ExtractAuthTLSVerifyClient},
+		{key: "inendless.com/auth-tls-verify-depth", extract: ExtractAuthTLSVerifyDepth},
+		{key: "inendless.com/ssl-redirect-code", extract: ExtractSSLRedirectCode},
+		{key: "inendless.com/hsts-max-age", extract: ExtractHSTSMaxAge},
+		{key: "inendless.com/rewrite-target", extract: ExtractRewriteTarget},
+		{key: "inendless.com/rewrite-regex", extract: ExtractRewriteRegex},
+		{key: "inendless.com/host-rewrite", extract: ExtractHostRewrite},
+		{key: "inendless.com/cors-allow-origin", extract: ExtractCorsAllowOrigin},
+		{key: "inendless.com/cors-allow-origin-regex", extract: ExtractCorsAllowOriginRegex},
+		{key: "inendless.com/cors-allow-methods", extract: ExtractCorsAllowMethods},
+		{key: "inendless.com/cors-allow-headers", extract: ExtractCorsAllowHeaders},
+		{key: "inendless.com/cors-expose-headers", extract: ExtractCorsExposeHeaders},
+		{key: "inendless.com/cors-max-age", extract: ExtractCorsMaxAge},
+		{key: "inendless.com/local-ratelimit-requests", extract: ExtractLocalRateLimitRequests},
+		{key: "inendless.com/local-ratelimit-interval", extract: ExtractLocalRateLimitInterval},
+		{key: "inendless.com/local-ratelimit-burst", extract: ExtractLocalRateLimitBurst},
+		{key: "inendless.com/global-ratelimit-descriptors", extract: ExtractGlobalRateLimit},
+		{key: "inendless.com/ext-authz-service", extract: ExtractExtAuthzService},
+		{key: "inendless.com/ext-authz-protocol", extract: ExtractExtAuthzProtocol},
+		{key: "inendless.com/ext-authz-path-prefix", extract: ExtractExtAuthzPathPrefix},
+		{key: "inendless.com/ext-authz-timeout", extract: ExtractExtAuthzTimeout},
+		{key: "inendless.com/ext-authz-request-headers", extract: ExtractExtAuthzRequestHeaders},
+		{key: "inendless.com/ext-authz-upstream-headers", extract: ExtractExtAuthzUpstreamHeaders},
+		{key: "inendless.com/ext-authz-skip-paths", extract: ExtractExtAuthzSkipPaths},
+		{key: "inendless.com/jwt-issuer", extract: ExtractJWTIssuer},
+		{key: "inendless.com/jwt-audiences", extract: ExtractJWTAudiences},
+		{key: "inendless.com/jwt-jwks-uri", extract: ExtractJWTJWKSURI},
+		{key: "inendless.com/jwt-jwks-secret", extract: ExtractJWTJWKSSecret},
+		{key: "inendless.com/jwt-jwks-cache-duration", extract: ExtractJWTJWKSCacheDuration},
+		{key: "inendless.com/jwt-claim-headers", extract: ExtractJWTClaimHeaders},
+		{key: "inendless.com/jwt-skip-paths", extract: ExtractJWTSkipPaths},
+		{key: "inendless.com/basic-auth-secret", extract: ExtractBasicAuthSecret},
+		{key: "inendless.com/basic-auth-realm", extract: ExtractBasicAuthRealm},
+		{key: "inendless.com/allowlist-source-range", extract: ExtractAllowlistSourceRange},
+		{key: "inendless.com/denylist-source-range", extract: ExtractDenylistSourceRange},
+		{key: "inendless.com/access-log-path", extract: ExtractAccessLogPath},
+		{key: "inendless.com/access-log-format-type", extract: ExtractAccessLogFormatType},
+		{key: "inendless.com/access-log-format", extract: ExtractAccessLogFormat},
+		{key: "inendless.com/access-log-service", extract: ExtractAccessLogService},
+		{key: "inendless.com/tracing-sampling", extract: ExtractTracingSampling},
+		{key: "inendless.com/tracing-tags", extract: ExtractTracingTags},
+		{key: "inendless.com/compression", extract: ExtractCompression},
+		{key: "inendless.com/max-request-bytes", extract: ExtractMaxRequestBytes},
+		{key: "inendless.com/max-response-bytes", extract: ExtractMaxResponseBytes},
+		{key: "inendless.com/websocket", extract: ExtractWebSocket},
+		{key: "inendless.com/error-pages", extract: ExtractErrorPages},
+		{key: "inendless.com/error-pages-configmap", extract: ExtractErrorPagesConfigMap},
+		{key: "inendless.com/error-pages-content-type", extract: ExtractErrorPagesContentType},
+		{key: "inendless.com/direct-response-status", extract: ExtractDirectResponseStatus},
+		{key: "inendless.com/direct-response-body", extract: ExtractDirectResponseBody},
+		{key: "inendless.com/default-response-status", extract: ExtractDefaultResponseStatus},
+		{key: "inendless.com/default-response-body", extract: ExtractDefaultResponseBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := tt.extract(map[string]string{tt.key: "value"}); got != "value" {
+				t.Errorf("got %q, want %q", got, "value")
+			}
+			if got := tt.extract(map[string]string{"other.com" + tt.key[len(AnnotationPrefix):]: "value"}); got != "" {
+				t.Errorf("got %q of another prefix, want none", got)
+			}
+			if got := tt.extract(nil); got != "" {
+				t.Errorf("got %q without annotations, want none", got)
+			}
+		})
+	}
+}
+
+func TestBoolExtractors(t *testing.T) {
+	tests := []struct {
+		key     string
+		extract func(map[string]string) bool
+		unset   bool
+	}{
+		{key: "inendless.com/locality-weighted-lb", extract: ExtractLocalityWeightedLb},
+		{key: "inendless.com/hedge-on-per-try-timeout", extract: ExtractHedgeOnPerTryTimeout},
+		{key: "inendless.com/upstream-tls", extract: ExtractUpstreamTLS},
+		{key: "inendless.com/upstream-tls-verify", extract: ExtractUpstreamTLSVerify, unset: true},
+		{key: "inendless.com/ssl-redirect", extract: ExtractSSLRedirect, unset: true},
+		{key: "inendless.com/hsts", extract: ExtractHSTS},
+		{key: "inendless.com/hsts-include-subdomains", extract: ExtractHSTSIncludeSubDomains},
+		{key: "inendless.com/hsts-preload", extract: ExtractHSTSPreload},
+		{key: "inendless.com/strip-prefix", extract: ExtractStripPrefix},
+		{key: "inendless.com/enable-cors", extract: ExtractEnableCors},
+		{key: "inendless.com/cors-allow-credentials", extract: ExtractCorsAllowCredentials},
+		{key: "inendless.com/ext-authz-failure-mode-allow", extract: ExtractExtAuthzFailureModeAllow},
+		{key: "inendless.com/jwt-forward", extract: ExtractJWTForward},
+		{key: "inendless.com/allow-connect", extract: ExtractAllowConnect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := tt.extract(nil); got != tt.unset {
+				t.Errorf("got %v when unset, want %v", got, tt.unset)
+			}
+			if got := tt.extract(map[string]string{tt.key: "true"}); !got {
+				t.Error("got false for true")
+			}
+			if got := tt.extract(map[string]string{tt.key: "false"}); got {
+				t.Error("got true for false")
+			}
+		})
+	}
+}
+
+func TestHeaderExtractors(t *testing.T) {
+	anns := map[string]string{
+		"inendless.com/request-headers-add":         "X-Tenant: a",
+		"inendless.com/request-headers-set":         "X-Gateway: inendless",
+		"inendless.com/request-headers-remove":      "X-Debug",
+		"inendless.com/host-response-headers-set":   "Server: inendless",
+		"inendless.com/response-headers-remove":     "Server",
+		"inendless.com/host-request-headers-remove": "Cookie",
+	}
+	tests := []struct {
+		name    string
+		extract func(map[string]string, string) string
+		key     string
+		want    string
+	}{
+		{name: "request add", extract: ExtractHeadersToAdd, key: RequestHeadersKey, want: "X-Tenant: a"},
+		{name: "request set", extract: ExtractHeadersToSet, key: RequestHeadersKey, want: "X-Gateway: inendless"},
+		{name: "request remove", extract: ExtractHeadersToRemove, key: RequestHeadersKey, want: "X-Debug"},
+		{name: "response add", extract: ExtractHeadersToAdd, key: ResponseHeadersKey},
+		{name: "response remove", extract: ExtractHeadersToRemove, key: ResponseHeadersKey, want: "Server"},
+		{name: "host response set", extract: ExtractHeadersToSet, key: HostResponseHeadersKey, want: "Server: inendless"},
+		{name: "host request remove", extract: ExtractHeadersToRemove, key: HostRequestHeadersKey, want: "Cookie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.extract(anns, tt.key); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package configuration
+
+// Syncer rebuilds the configuration of the proxies after the reconcilers changed the
+// cache.
+type Syncer interface {
+	Trigger()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -25,7 +26,8 @@ import (
 
 type CoreV1ServiceReconciler struct {
 	client.Client
-	cache            *store.CacheStores
+	Cache            *store.CacheStores
+	Syncer           Syncer
 	Log              logr.Logger
 	Scheme           *runtime.Scheme
 	CacheSyncTimeout time.Duration
@@ -44,19 +46,20 @@ func (r *CoreV1ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 	return c.Watch(
-		&source.Kind{Type: &corev1.Service{}},
+		source.Kind(mgr.GetCache(), &corev1.Service{}),
 		&handler.EnqueueRequestForObject{},
 	)
 }
 
 func (r *CoreV1ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
 	log := r.Log.WithValues("CoreV1Service", req.NamespacedName)
 	obj := new(corev1.Service)
 	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
 		if errors.IsNotFound(err) {
 			obj.Namespace = req.Namespace
 			obj.Name = req.Name
-			return ctrl.Result{}, r.cache.Delete(obj)
+			return ctrl.Result{}, r.Cache.Delete(obj)
 		}
 		return ctrl.Result{}, err
 	}
@@ -65,19 +68,19 @@ func (r *CoreV1ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
 		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "Service", "namespace", req.Namespace, "name", req.Name)
 
-		_, objectExistsInCache, err := r.cache.Get(obj)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 		if objectExistsInCache {
-			if err := r.cache.Delete(obj); err != nil {
+			if err := r.Cache.Delete(obj); err != nil {
 				return ctrl.Result{}, err
 			}
 			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
 		}
 		return ctrl.Result{}, nil
 	}
-	if err := r.cache.Add(obj.DeepCopyObject()); err != nil {
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
@@ -85,7 +88,8 @@ func (r *CoreV1ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 type CoreV1EndpointsReconciler struct {
 	client.Client
-	cache            *store.CacheStores
+	Cache            *store.CacheStores
+	Syncer           Syncer
 	Log              logr.Logger
 	Scheme           *runtime.Scheme
 	CacheSyncTimeout time.Duration
@@ -103,13 +107,13 @@ func (r *CoreV1EndpointsReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 	return c.Watch(
-		&source.Kind{Type: &corev1.Endpoints{}},
+		source.Kind(mgr.GetCache(), &corev1.Endpoints{}),
 		&handler.EnqueueRequestForObject{},
 	)
 }
 
 func (r *CoreV1EndpointsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-
+	defer r.Syncer.Trigger()
 	log := r.Log.WithValues("CoreV1Endpoints", req.NamespacedName)
 	// get the relevant object
 	obj := new(corev1.Endpoints)
@@ -117,26 +121,143 @@ func (r *CoreV1EndpointsReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		if errors.IsNotFound(err) {
 			obj.Namespace = req.Namespace
 			obj.Name = req.Name
-			return ctrl.Result{}, r.cache.Delete(obj)
+			return ctrl.Result{}, r.Cache.Delete(obj)
 		}
 		return ctrl.Result{}, err
 	}
 	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
 	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
 		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "Endpoints", "namespace", req.Namespace, "name", req.Name)
-		_, objectExistsInCache, err := r.cache.Get(obj)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+type DiscoveryV1EndpointSliceReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *DiscoveryV1EndpointSliceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("DiscoveryV1EndpointSlice", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &discoveryv1.EndpointSlice{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+
+func (r *DiscoveryV1EndpointSliceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("DiscoveryV1EndpointSlice", req.NamespacedName)
+	obj := new(discoveryv1.EndpointSlice)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Namespace = req.Namespace
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "EndpointSlice", "namespace", req.Namespace, "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+type CoreV1NodeReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *CoreV1NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("CoreV1Node", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &corev1.Node{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+
+func (r *CoreV1NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("CoreV1Node", req.NamespacedName)
+	obj := new(corev1.Node)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "Node", "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 		if objectExistsInCache {
-			if err := r.cache.Delete(obj); err != nil {
+			if err := r.Cache.Delete(obj); err != nil {
 				return ctrl.Result{}, err
 			}
 			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
 		}
 		return ctrl.Result{}, nil
 	}
-	if err := r.cache.Add(obj.DeepCopyObject()); err != nil {
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
@@ -144,7 +265,8 @@ func (r *CoreV1EndpointsReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 type CoreV1SecretReconciler struct {
 	client.Client
-	cache            *store.CacheStores
+	Cache            *store.CacheStores
+	Syncer           Syncer
 	Log              logr.Logger
 	Scheme           *runtime.Scheme
 	CacheSyncTimeout time.Duration
@@ -162,37 +284,96 @@ func (r *CoreV1SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 	return c.Watch(
-		&source.Kind{Type: &corev1.Secret{}},
+		source.Kind(mgr.GetCache(), &corev1.Secret{}),
 		&handler.EnqueueRequestForObject{},
 	)
 }
 func (r *CoreV1SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
 	log := r.Log.WithValues("CoreV1Secret", req.NamespacedName)
 	obj := new(corev1.Secret)
 	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
 		if errors.IsNotFound(err) {
 			obj.Namespace = req.Namespace
 			obj.Name = req.Name
-			return ctrl.Result{}, r.cache.Delete(obj)
+			return ctrl.Result{}, r.Cache.Delete(obj)
 		}
 		return ctrl.Result{}, err
 	}
 	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
 	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
 		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "Secret", "namespace", req.Namespace, "name", req.Name)
-		_, objectExistsInCache, err := r.cache.Get(obj)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+type CoreV1ConfigMapReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *CoreV1ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("CoreV1ConfigMap", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &corev1.ConfigMap{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+func (r *CoreV1ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("CoreV1ConfigMap", req.NamespacedName)
+	obj := new(corev1.ConfigMap)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Namespace = req.Namespace
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "ConfigMap", "namespace", req.Namespace, "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 		if objectExistsInCache {
-			if err := r.cache.Delete(obj); err != nil {
+			if err := r.Cache.Delete(obj); err != nil {
 				return ctrl.Result{}, err
 			}
 			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
 		}
 		return ctrl.Result{}, nil
 	}
-	if err := r.cache.Add(obj.DeepCopyObject()); err != nil {
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
@@ -205,6 +386,7 @@ type NetV1IngressReconciler struct {
 	Scheme                     *runtime.Scheme
 	CacheSyncTimeout           time.Duration
 	Cache                      *store.CacheStores
+	Syncer                     Syncer
 	StatusQueue                *status.Queue
 	IngressClassName           string
 	DisableIngressClassLookups bool
@@ -235,7 +417,7 @@ func (r *NetV1IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 	if !r.DisableIngressClassLookups {
 		err = c.Watch(
-			&source.Kind{Type: &netv1.IngressClass{}},
+			source.Kind(mgr.GetCache(), &netv1.IngressClass{}),
 			handler.EnqueueRequestsFromMapFunc(r.listClassless),
 			predicate.NewPredicateFuncs(ctrlutils.IsDefaultIngressClass),
 		)
@@ -245,15 +427,15 @@ func (r *NetV1IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 	preds := ctrlutils.GeneratePredicateFuncsForIngressClassFilter(r.IngressClassName)
 	return c.Watch(
-		&source.Kind{Type: &netv1.Ingress{}},
+		source.Kind(mgr.GetCache(), &netv1.Ingress{}),
 		&handler.EnqueueRequestForObject{},
 		preds,
 	)
 }
 
-func (r *NetV1IngressReconciler) listClassless(obj client.Object) []reconcile.Request {
+func (r *NetV1IngressReconciler) listClassless(ctx context.Context, obj client.Object) []reconcile.Request {
 	resourceList := &netv1.IngressList{}
-	if err := r.Client.List(context.Background(), resourceList); err != nil {
+	if err := r.Client.List(ctx, resourceList); err != nil {
 		r.Log.Error(err, "failed to list classless ingresses")
 		return nil
 	}
@@ -271,6 +453,7 @@ func (r *NetV1IngressReconciler) listClassless(obj client.Object) []reconcile.Re
 	return recs
 }
 func (r *NetV1IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
 	log := r.Log.WithValues("NetV1Ingress", req.NamespacedName)
 
 	// get the relevant object
@@ -328,6 +511,7 @@ func (r *NetV1IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request
 type NetV1IngressClassReconciler struct {
 	client.Client
 	Cache            *store.CacheStores
+	Syncer           Syncer
 	Log              logr.Logger
 	Scheme           *runtime.Scheme
 	CacheSyncTimeout time.Duration
@@ -345,12 +529,13 @@ func (r *NetV1IngressClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 	return c.Watch(
-		&source.Kind{Type: &netv1.IngressClass{}},
+		source.Kind(mgr.GetCache(), &netv1.IngressClass{}),
 		&handler.EnqueueRequestForObject{},
 	)
 }
 
 func (r *NetV1IngressClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
 	log := r.Log.WithValues("NetV1IngressClass", req.NamespacedName)
 
 	// get the relevant object
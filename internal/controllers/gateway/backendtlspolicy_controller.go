@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/controllers/configuration"
+	"kubernetes-controller/internal/store"
+	"kubernetes-controller/internal/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"time"
+)
+
+// GatewayV1alpha2BackendTLSPolicyReconciler keeps the BackendTLSPolicies in the cache,
+// which configure TLS towards the Services they target.
+type GatewayV1alpha2BackendTLSPolicyReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           configuration.Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *GatewayV1alpha2BackendTLSPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("GatewayV1alpha2BackendTLSPolicy", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &gatewayv1alpha2.BackendTLSPolicy{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+
+func (r *GatewayV1alpha2BackendTLSPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("GatewayV1alpha2BackendTLSPolicy", req.NamespacedName)
+	obj := new(gatewayv1alpha2.BackendTLSPolicy)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Namespace = req.Namespace
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "BackendTLSPolicy", "namespace", req.Namespace, "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -1 +1,80 @@
 package gateway
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/controllers/configuration"
+	"kubernetes-controller/internal/store"
+	"kubernetes-controller/internal/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"time"
+)
+
+// GatewayV1GatewayReconciler keeps the Gateways in the cache, whose listeners the
+// HTTPRoutes attach to.
+type GatewayV1GatewayReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           configuration.Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *GatewayV1GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("GatewayV1Gateway", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &gatewayv1.Gateway{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+
+func (r *GatewayV1GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("GatewayV1Gateway", req.NamespacedName)
+	obj := new(gatewayv1.Gateway)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Namespace = req.Namespace
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "namespace", req.Namespace, "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "Gateway", "namespace", req.Namespace, "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -1 +1,79 @@
 package gateway
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/controllers/configuration"
+	"kubernetes-controller/internal/store"
+	"kubernetes-controller/internal/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"time"
+)
+
+// GatewayV1GatewayClassReconciler keeps the GatewayClasses in the cache, so that the
+// Gateways of the classes of the controller are translated.
+type GatewayV1GatewayClassReconciler struct {
+	client.Client
+	Cache            *store.CacheStores
+	Syncer           configuration.Syncer
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	CacheSyncTimeout time.Duration
+}
+
+func (r *GatewayV1GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("GatewayV1GatewayClass", mgr, controller.Options{
+		Reconciler: r,
+		LogConstructor: func(_ *reconcile.Request) logr.Logger {
+			return r.Log
+		},
+		CacheSyncTimeout: r.CacheSyncTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(
+		source.Kind(mgr.GetCache(), &gatewayv1.GatewayClass{}),
+		&handler.EnqueueRequestForObject{},
+	)
+}
+
+func (r *GatewayV1GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Syncer.Trigger()
+	log := r.Log.WithValues("GatewayV1GatewayClass", req.NamespacedName)
+	obj := new(gatewayv1.GatewayClass)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if errors.IsNotFound(err) {
+			obj.Name = req.Name
+			return ctrl.Result{}, r.Cache.Delete(obj)
+		}
+		return ctrl.Result{}, err
+	}
+	log.V(util.DebugLevel).Info("reconciling resource", "name", req.Name)
+	if !obj.DeletionTimestamp.IsZero() && time.Now().After(obj.DeletionTimestamp.Time) {
+		log.V(util.DebugLevel).Info("resource is being deleted, its configuration will be removed", "type", "GatewayClass", "name", req.Name)
+		_, objectExistsInCache, err := r.Cache.Get(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if objectExistsInCache {
+			if err := r.Cache.Delete(obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil // wait until the object is no longer present in the cache
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.Cache.Add(obj.DeepCopyObject()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,114 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"kubernetes-controller/internal/envoy/parser"
+	"kubernetes-controller/internal/envoy/xds"
+	"time"
+)
+
+// TranslationFailedReason is the reason of the events recorded on the objects that
+// could not be fully translated.
+const TranslationFailedReason = "TranslationFailed"
+
+// Synchronizer rebuilds the configuration of the proxies from the cache whenever a
+// reconciler triggers it, at most once per interval, and pushes it to the xDS server.
+type Synchronizer struct {
+	parser    *parser.Parser
+	snapshots cachev3.SnapshotCache
+	recorder  record.EventRecorder
+	log       logr.Logger
+	interval  time.Duration
+
+	trigger chan struct{}
+	// reported are the failures of the last build, so that unchanged failures are
+	// not recorded again on every build
+	reported map[string]bool
+}
+
+func NewSynchronizer(p *parser.Parser, snapshots cachev3.SnapshotCache, recorder record.EventRecorder, log logr.Logger, interval time.Duration) *Synchronizer {
+	return &Synchronizer{
+		parser:    p,
+		snapshots: snapshots,
+		recorder:  recorder,
+		log:       log,
+		interval:  interval,
+		trigger:   make(chan struct{}, 1),
+		reported:  map[string]bool{},
+	}
+}
+
+// Trigger requests a rebuild; triggers arriving before it runs are coalesced.
+func (s *Synchronizer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the synchronizer until the context is done.
+func (s *Synchronizer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		select {
+		case <-s.trigger:
+			if err := s.Sync(ctx); err != nil {
+				s.log.Error(err, "failed to update the proxy configuration")
+			}
+		default:
+		}
+	}
+}
+
+// NeedLeaderElection is false as every replica serves its own proxies.
+func (s *Synchronizer) NeedLeaderElection() bool {
+	return false
+}
+
+// Sync builds the configuration and sets it as the snapshot of the proxies.
+func (s *Synchronizer) Sync(ctx context.Context) error {
+	cache := s.parser.Build()
+	s.reportFailures(s.parser.TranslationFailures())
+	snapshot, err := cache.Snapshot()
+	if err != nil {
+		return err
+	}
+	return s.snapshots.SetSnapshot(ctx, xds.Fleet, snapshot)
+}
+
+// reportFailures records the failures that are new since the last build as warning
+// events of their objects, and logs those of the controller configuration.
+func (s *Synchronizer) reportFailures(failures []parser.TranslationFailure) {
+	reported := map[string]bool{}
+	for _, f := range failures {
+		key := f.Reason
+		if f.Object != nil {
+			key = fmt.Sprintf("%s/%s/%s: %s", f.Object.GetUID(), f.Object.GetNamespace(), f.Object.GetName(), f.Reason)
+		}
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+		if s.reported[key] {
+			continue
+		}
+		if f.Object == nil {
+			s.log.Error(errors.New(f.Reason), "invalid controller configuration")
+			continue
+		}
+		s.recorder.Event(f.Object, corev1.EventTypeWarning, TranslationFailedReason, f.Reason)
+	}
+	s.reported = reported
+}
@@ -0,0 +1,147 @@
+package dataplane
+
+import (
+	"context"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"kubernetes-controller/internal/envoy/parser"
+	"kubernetes-controller/internal/envoy/xds"
+	"kubernetes-controller/internal/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStores returns an Ingress with a path to a Service, with a ready endpoint, and
+// a path to a Service that does not exist.
+func newStores(t *testing.T) *store.CacheStores {
+	stores := store.NewCacheStores()
+	pathType := netv1.PathTypePrefix
+	ready := true
+	port := int32(8080)
+	objects := []runtime.Object{
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+				Host: "example.com",
+				IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{
+					{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+							Name: "web", Port: netv1.ServiceBackendPort{Number: 80},
+						}},
+					},
+					{
+						Path:     "/missing",
+						PathType: &pathType,
+						Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+							Name: "missing", Port: netv1.ServiceBackendPort{Number: 80},
+						}},
+					},
+				}}},
+			}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "web-abcde",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{{
+				Addresses:  []string{"10.0.0.1"},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			}},
+			Ports: []discoveryv1.EndpointPort{{Name: stringPtr("http"), Port: &port}},
+		},
+	}
+	for _, obj := range objects {
+		if err := stores.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stores
+}
+
+func TestSynchronizerSync(t *testing.T) {
+	stores := newStores(t)
+	snapshots := cachev3.NewSnapshotCache(false, xds.FleetHash{}, nil)
+	recorder := record.NewFakeRecorder(10)
+	s := NewSynchronizer(parser.NewParser(store.New(*stores, ""), parser.Config{HTTPPort: 8080}),
+		snapshots, recorder, logr.Discard(), time.Second)
+
+	for i := 0; i < 2; i++ {
+		if err := s.Sync(context.Background()); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+	}
+	snapshot, err := snapshots.GetSnapshot(xds.Fleet)
+	if err != nil {
+		t.Fatalf("no snapshot for the proxies: %v", err)
+	}
+	if _, ok := snapshot.GetResources(resource.ClusterType)["default/web/80"]; !ok {
+		t.Errorf("snapshot has no cluster default/web/80: %v", snapshot.GetResources(resource.ClusterType))
+	}
+	if _, ok := snapshot.GetResources(resource.RouteType)["listener_0"]; !ok {
+		t.Errorf("snapshot has no route configuration listener_0")
+	}
+
+	// the failure of the second build is the same as the first, and is recorded once
+	if len(recorder.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.Contains(event, TranslationFailedReason) || !strings.Contains(event, "missing") {
+		t.Errorf("unexpected event %q", event)
+	}
+}
+
+func TestSynchronizerSnapshotVersion(t *testing.T) {
+	stores := newStores(t)
+	snapshots := cachev3.NewSnapshotCache(false, xds.FleetHash{}, nil)
+	s := NewSynchronizer(parser.NewParser(store.New(*stores, ""), parser.Config{
+		HTTPPort:    8080,
+		Compression: []string{"gzip", "brotli"},
+	}), snapshots, record.NewFakeRecorder(10), logr.Discard(), time.Second)
+
+	versions := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		if err := s.Sync(context.Background()); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		snapshot, err := snapshots.GetSnapshot(xds.Fleet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions[snapshot.GetVersion(resource.ListenerType)] = true
+	}
+	if len(versions) != 1 {
+		t.Errorf("rebuilding the same objects changed the version: %v", versions)
+	}
+}
+
+func TestSynchronizerTrigger(t *testing.T) {
+	s := NewSynchronizer(nil, nil, nil, logr.Discard(), time.Second)
+	// triggers are coalesced rather than blocking the reconcilers
+	for i := 0; i < 3; i++ {
+		s.Trigger()
+	}
+	if len(s.trigger) != 1 {
+		t.Errorf("got %d pending triggers, want 1", len(s.trigger))
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -1,5 +1,173 @@
 package parser
 
-func (p *Parser) ingressRulesFromIngress() {
+import (
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"net/http"
+	"reflect"
+	"strings"
+)
 
+func (p *Parser) ingressRulesFromIngress(cache *xdscache.Cache) {
+	for _, ing := range p.storer.ListIngressesV1() {
+		errorPages := p.errorPages(ing)
+		for i, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for j, path := range rule.HTTP.Paths {
+				r := pathToRoute(path)
+				r.Name = fmt.Sprintf("%s/%s/%d/%d", ing.Namespace, ing.Name, i, j)
+				r.Host = rule.Host
+				r.Origin = ingressOrigin(ing)
+				p.applyRouteAnnotations(ing, &r)
+				p.applyHTTPSRedirect(ing, &r)
+				p.applyDirectResponse(ing, &r)
+				if r.DirectResponseStatus == 0 {
+					r = p.routeToBackend(ing, r, path.Backend, cache)
+				}
+				r.ErrorPages = errorPages
+				p.applyAccessPolicies(ing, &r, path.Path, cache)
+				cache.Routes[r.Name] = r
+			}
+			if annotations.ExtractAllowConnect(ing.Annotations) && len(rule.HTTP.Paths) > 0 {
+				// CONNECT requests carry no path to pick a backend of the rule with
+				if !sameBackend(rule.HTTP.Paths) {
+					p.registerTranslationFailure(fmt.Sprintf("rule %d: CONNECT needs the paths of the rule to share one backend", i), ing)
+					continue
+				}
+				r := resources.Route{
+					Name:    fmt.Sprintf("%s/%s/%d/connect", ing.Namespace, ing.Name, i),
+					Host:    rule.Host,
+					Origin:  ingressOrigin(ing),
+					Connect: true,
+				}
+				p.applyDirectResponse(ing, &r)
+				if r.DirectResponseStatus == 0 {
+					r = p.routeToBackend(ing, r, rule.HTTP.Paths[0].Backend, cache)
+				}
+				p.applyAccessPolicies(ing, &r, "", cache)
+				cache.Routes[r.Name] = r
+			}
+		}
+		if ing.Spec.DefaultBackend != nil {
+			r := resources.Route{
+				Name:   fmt.Sprintf("%s/%s/default", ing.Namespace, ing.Name),
+				Prefix: "/",
+				Origin: ingressOrigin(ing),
+			}
+			p.applyRouteAnnotations(ing, &r)
+			p.applyDirectResponse(ing, &r)
+			if r.DirectResponseStatus == 0 {
+				r = p.routeToBackend(ing, r, *ing.Spec.DefaultBackend, cache)
+			}
+			r.ErrorPages = errorPages
+			p.applyAccessPolicies(ing, &r, "", cache)
+			cache.Routes[r.Name] = r
+		} else {
+			for _, r := range p.defaultResponseRoutes(ing) {
+				r.ErrorPages = errorPages
+				cache.Routes[r.Name] = r
+			}
+		}
+	}
+}
+
+// sameBackend reports whether the paths all route to the same backend.
+func sameBackend(paths []netv1.HTTPIngressPath) bool {
+	for _, path := range paths[1:] {
+		if !reflect.DeepEqual(path.Backend, paths[0].Backend) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeToBackend points the route at the cluster of the backend. Backends that cannot
+// be resolved answer with a 503 instead of being dropped, so the path does not fall
+// through to a less specific route.
+func (p *Parser) routeToBackend(ing *netv1.Ingress, r resources.Route, backend netv1.IngressBackend, cache *xdscache.Cache) resources.Route {
+	cluster, err := p.clusterForBackend(ing.Namespace, backend)
+	if err != nil {
+		p.closeRoute(ing, &r, err)
+		return r
+	}
+	cache.Clusters[cluster.Name] = cluster
+	if cluster.UpstreamTLS != nil && cluster.UpstreamTLS.ClientCertificate != nil {
+		cache.Secrets[cluster.UpstreamTLS.ClientCertificate.Name] = *cluster.UpstreamTLS.ClientCertificate
+	}
+	r.Cluster = cluster.Name
+	r.HashPolicy = cluster.HashPolicy
+	switch annotations.ExtractWebSocket(ing.Annotations) {
+	case "true":
+		r.WebSocket = true
+	case "false":
+		r.WebSocket = false
+	default:
+		r.WebSocket = cluster.WebSocket
+	}
+	return r
+}
+
+// applyAccessPolicies restricts who may use the route, path being the Ingress path it
+// was generated from.
+func (p *Parser) applyAccessPolicies(ing *netv1.Ingress, r *resources.Route, path string, cache *xdscache.Cache) {
+	p.applyIPFilter(ing, r)
+	p.applyBasicAuth(ing, r)
+	p.applyJWT(ing, r, path, cache)
+	p.applyExtAuthz(ing, r, path, cache)
+}
+
+func ingressOrigin(ing *netv1.Ingress) *resources.Origin {
+	return &resources.Origin{Kind: "Ingress", Namespace: ing.Namespace, Name: ing.Name, UID: string(ing.UID)}
+}
+
+// closeRoute answers the requests of a route that could not be fully translated with
+// a 503, so they are neither let through unchecked nor sent to a less specific route.
+func (p *Parser) closeRoute(ing *netv1.Ingress, r *resources.Route, err error) {
+	p.registerTranslationFailure(fmt.Sprintf("route %s: %v", r.Name, err), ing)
+	r.Cluster = ""
+	r.DirectResponseStatus = http.StatusServiceUnavailable
+}
+
+func (p *Parser) clusterForBackend(namespace string, backend netv1.IngressBackend) (resources.Cluster, error) {
+	if backend.Service == nil {
+		return resources.Cluster{}, errors.New("only Service backends are supported")
+	}
+	svc, err := p.storer.GetService(namespace, backend.Service.Name)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return p.clusterForExternalName(svc, backend.Service.Port)
+	}
+	port, err := resolveServicePort(svc, backend.Service.Port)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	return p.clusterForService(svc, port)
+}
+
+func pathToRoute(path netv1.HTTPIngressPath) resources.Route {
+	value := path.Path
+	if value == "" {
+		value = "/"
+	}
+	pathType := netv1.PathTypeImplementationSpecific
+	if path.PathType != nil {
+		pathType = *path.PathType
+	}
+	switch {
+	case pathType == netv1.PathTypeExact:
+		return resources.Route{Path: value}
+	case pathType == netv1.PathTypePrefix && value != "/":
+		return resources.Route{PathSeparatedPrefix: strings.TrimSuffix(value, "/")}
+	default:
+		return resources.Route{Prefix: value}
+	}
 }
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"testing"
+)
+
+func TestIngressTranslationFailures(t *testing.T) {
+	pathType := netv1.PathTypePrefix
+	serviceBackend := func(name string, port netv1.ServiceBackendPort) netv1.IngressBackend {
+		return netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: name, Port: port}}
+	}
+	web := serviceBackend("web", netv1.ServiceBackendPort{Name: "http"})
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		backend     netv1.IngressBackend
+		wantStatus  uint32
+		wantCluster string
+		wantFailure string
+	}{
+		{name: "valid", backend: web, wantCluster: "default/web/80"},
+		{
+			name:        "missing Service",
+			backend:     serviceBackend("missing", netv1.ServiceBackendPort{Number: 80}),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "route default/ingress/0/0: Service default/missing not found",
+		},
+		{
+			name:        "unknown port",
+			backend:     serviceBackend("web", netv1.ServiceBackendPort{Name: "grpc"}),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: `Service default/web has no port named "grpc"`,
+		},
+		{
+			name:        "resource backend",
+			backend:     netv1.IngressBackend{Resource: &corev1.TypedLocalObjectReference{Kind: "Bucket", Name: "assets"}},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "only Service backends are supported",
+		},
+		{
+			name:        "invalid source range",
+			annotations: map[string]string{"inendless.com/allowlist-source-range": "10.0.0.0/33"},
+			backend:     web,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "/allowlist-source-range",
+		},
+		{
+			name:        "invalid timeout",
+			annotations: map[string]string{"inendless.com/request-timeout": "30"},
+			backend:     web,
+			wantCluster: "default/web/80",
+			wantFailure: `invalid duration "30" in annotation inendless.com/request-timeout`,
+		},
+		{
+			name:        "invalid rewrite regex",
+			annotations: map[string]string{"inendless.com/rewrite-regex": "/("},
+			backend:     web,
+			wantCluster: "default/web/80",
+			wantFailure: `invalid rewrite regex "/("`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ingress", Annotations: tt.annotations},
+				Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+					Host: "web.example.com",
+					IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend:  tt.backend,
+					}}}},
+				}}},
+			}
+			p := newTestParser(t, Config{}, testService(), ing)
+			r, ok := p.Build().Routes["default/ingress/0/0"]
+			if !ok {
+				t.Fatal("no route of the Ingress")
+			}
+			if r.DirectResponseStatus != tt.wantStatus || r.Cluster != tt.wantCluster {
+				t.Errorf("route answers %d through %q, want %d through %q", r.DirectResponseStatus, r.Cluster, tt.wantStatus, tt.wantCluster)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure)
+			if f := p.TranslationFailures()[0]; f.Object != ing {
+				t.Errorf("failure reported on %v, want the Ingress", f.Object)
+			}
+		})
+	}
+
+	// failures are recorded by each Build anew
+	ing := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ingress"},
+		Spec:       netv1.IngressSpec{DefaultBackend: &netv1.IngressBackend{}},
+	}
+	p := newTestParser(t, Config{}, testService(), ing)
+	p.Build()
+	p.Build()
+	assertFailures(t, p, "only Service backends are supported")
+}
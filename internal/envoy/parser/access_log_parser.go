@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const accessLogOff = "off"
+
+var (
+	defaultAccessLogTextFormat = `[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" ` +
+		`%RESPONSE_CODE% %RESPONSE_FLAGS% %BYTES_RECEIVED% %BYTES_SENT% %DURATION% ` +
+		`%RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% "%REQ(X-FORWARDED-FOR)%" "%REQ(USER-AGENT)%" ` +
+		`"%REQ(X-REQUEST-ID)%" "%REQ(:AUTHORITY)%" "%UPSTREAM_HOST%" ` +
+		`%METADATA(ROUTE:` + resources.MetadataNamespace + `:namespace)%/%METADATA(ROUTE:` + resources.MetadataNamespace + `:name)%` + "\n"
+
+	defaultAccessLogJSONFormat = map[string]string{
+		"start_time":            "%START_TIME%",
+		"method":                "%REQ(:METHOD)%",
+		"path":                  "%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%",
+		"protocol":              "%PROTOCOL%",
+		"response_code":         "%RESPONSE_CODE%",
+		"response_flags":        "%RESPONSE_FLAGS%",
+		"bytes_received":        "%BYTES_RECEIVED%",
+		"bytes_sent":            "%BYTES_SENT%",
+		"duration":              "%DURATION%",
+		"upstream_service_time": "%RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)%",
+		"x_forwarded_for":       "%REQ(X-FORWARDED-FOR)%",
+		"user_agent":            "%REQ(USER-AGENT)%",
+		"request_id":            "%REQ(X-REQUEST-ID)%",
+		"authority":             "%REQ(:AUTHORITY)%",
+		"upstream_host":         "%UPSTREAM_HOST%",
+		"ingress_namespace":     "%METADATA(ROUTE:" + resources.MetadataNamespace + ":namespace)%",
+		"ingress_name":          "%METADATA(ROUTE:" + resources.MetadataNamespace + ":name)%",
+	}
+)
+
+// accessLogs returns the access logs of the listeners from the parser Config,
+// overridden by the annotations of the IngressClass of the controller.
+func (p *Parser) accessLogs(cache *xdscache.Cache) []resources.AccessLog {
+	path, formatType, format, service := p.cfg.AccessLogPath, p.cfg.AccessLogFormatType, p.cfg.AccessLogFormat, p.cfg.AccessLogService
+	// failures of the flags are reported without an object
+	var obj client.Object
+	if class, err := p.storer.GetIngressClassV1(p.cfg.IngressClass); err == nil {
+		anns := class.GetAnnotations()
+		for value, override := range map[*string]string{
+			&path:       annotations.ExtractAccessLogPath(anns),
+			&formatType: annotations.ExtractAccessLogFormatType(anns),
+			&format:     annotations.ExtractAccessLogFormat(anns),
+			&service:    annotations.ExtractAccessLogService(anns),
+		} {
+			if override != "" {
+				*value = override
+				obj = class
+			}
+		}
+	}
+
+	var logs []resources.AccessLog
+	if path != "" && path != accessLogOff {
+		log := resources.AccessLog{Path: path, TextFormat: defaultAccessLogTextFormat}
+		switch formatType {
+		case "", "text":
+			if format != "" {
+				log.TextFormat = strings.TrimSuffix(format, "\n") + "\n"
+			}
+		case "json":
+			log.JSONFormat = defaultAccessLogJSONFormat
+			if format != "" {
+				custom := map[string]string{}
+				if err := json.Unmarshal([]byte(format), &custom); err != nil {
+					p.registerTranslationFailure(fmt.Sprintf("access log format is not a JSON object of strings: %v", err), obj)
+				} else {
+					log.JSONFormat = custom
+				}
+			}
+		default:
+			p.registerTranslationFailure(fmt.Sprintf("invalid access log format type %q", formatType), obj)
+		}
+		logs = append(logs, log)
+	}
+	if service != "" && service != accessLogOff {
+		cluster, err := p.clusterForServiceRef(service, "", true, cache)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("access log service: %v", err), obj)
+		} else {
+			logs = append(logs, resources.AccessLog{GRPCCluster: cluster.Name})
+		}
+	}
+	return logs
+}
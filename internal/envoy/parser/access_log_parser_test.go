@@ -0,0 +1,124 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func TestAccessLogs(t *testing.T) {
+	stdout := Config{AccessLogPath: "/dev/stdout", AccessLogFormatType: "text"}
+	class := func(anns map[string]string) *netv1.IngressClass {
+		return &netv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "inendless", Annotations: anns}}
+	}
+	als := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "logging", Name: "als"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "grpc", Port: 9001}}},
+	}
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		objects      []runtime.Object
+		want         []resources.AccessLog
+		wantFailures []string
+	}{
+		{name: "none"},
+		{
+			name: "default text format",
+			cfg:  stdout,
+			want: []resources.AccessLog{{Path: "/dev/stdout", TextFormat: defaultAccessLogTextFormat}},
+		},
+		{
+			name: "custom text format",
+			cfg:  Config{AccessLogPath: "/dev/stdout", AccessLogFormat: "%RESPONSE_CODE%"},
+			want: []resources.AccessLog{{Path: "/dev/stdout", TextFormat: "%RESPONSE_CODE%\n"}},
+		},
+		{
+			name: "default JSON format",
+			cfg:  Config{AccessLogPath: "/dev/stdout", AccessLogFormatType: "json"},
+			want: []resources.AccessLog{{Path: "/dev/stdout", TextFormat: defaultAccessLogTextFormat, JSONFormat: defaultAccessLogJSONFormat}},
+		},
+		{
+			name: "custom JSON format",
+			cfg:  Config{AccessLogPath: "/dev/stdout", AccessLogFormatType: "json", AccessLogFormat: `{"status": "%RESPONSE_CODE%"}`},
+			want: []resources.AccessLog{{
+				Path:       "/dev/stdout",
+				TextFormat: defaultAccessLogTextFormat,
+				JSONFormat: map[string]string{"status": "%RESPONSE_CODE%"},
+			}},
+		},
+		{
+			name:         "invalid JSON format",
+			cfg:          Config{AccessLogPath: "/dev/stdout", AccessLogFormatType: "json", AccessLogFormat: `{"status": 200}`},
+			want:         []resources.AccessLog{{Path: "/dev/stdout", TextFormat: defaultAccessLogTextFormat, JSONFormat: defaultAccessLogJSONFormat}},
+			wantFailures: []string{"access log format is not a JSON object of strings"},
+		},
+		{
+			name:         "invalid format type",
+			cfg:          Config{AccessLogPath: "/dev/stdout", AccessLogFormatType: "xml"},
+			want:         []resources.AccessLog{{Path: "/dev/stdout", TextFormat: defaultAccessLogTextFormat}},
+			wantFailures: []string{`invalid access log format type "xml"`},
+		},
+		{
+			name:    "IngressClass turns file logs off",
+			cfg:     Config{IngressClass: "inendless", AccessLogPath: "/dev/stdout"},
+			objects: []runtime.Object{class(map[string]string{"inendless.com/access-log-path": "off"})},
+		},
+		{
+			name: "IngressClass overrides the format",
+			cfg:  Config{IngressClass: "inendless", AccessLogPath: "/dev/stdout"},
+			objects: []runtime.Object{class(map[string]string{
+				"inendless.com/access-log-format-type": "json",
+				"inendless.com/access-log-format":      `{"path": "%REQ(:PATH)%"}`,
+			})},
+			want: []resources.AccessLog{{
+				Path:       "/dev/stdout",
+				TextFormat: defaultAccessLogTextFormat,
+				JSONFormat: map[string]string{"path": "%REQ(:PATH)%"},
+			}},
+		},
+		{
+			name:    "access log service",
+			cfg:     Config{AccessLogService: "logging/als:grpc"},
+			objects: []runtime.Object{als},
+			want:    []resources.AccessLog{{GRPCCluster: "logging/als/9001/http2"}},
+		},
+		{
+			name:         "missing access log service",
+			cfg:          Config{AccessLogService: "logging/als:grpc"},
+			wantFailures: []string{"access log service: Service logging/als not found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, tt.cfg, tt.objects...)
+			cache := p.Build()
+			assertFailures(t, p, tt.wantFailures...)
+			if got := cache.Listeners["listener_0"].AccessLogs; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got access logs %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngressOrigin(t *testing.T) {
+	ing := testIngress("web", "web.example.com", nil)
+	ing.UID = "ingress-uid"
+	svc := testService()
+	svc.UID = "service-uid"
+	cache := newTestParser(t, Config{}, svc, ing).Build()
+
+	want := &resources.Origin{Kind: "Ingress", Namespace: "default", Name: "web", UID: "ingress-uid"}
+	if got := cache.Routes["default/web/0/0"].Origin; !reflect.DeepEqual(got, want) {
+		t.Errorf("got route origin %+v, want %+v", got, want)
+	}
+	want = &resources.Origin{Kind: "Service", Namespace: "default", Name: "web", UID: "service-uid"}
+	if got := cache.Clusters["default/web/80"].Origin; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cluster origin %+v, want %+v", got, want)
+	}
+}
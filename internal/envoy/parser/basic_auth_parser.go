@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"strings"
+)
+
+const (
+	htpasswdKey      = "auth"
+	htpasswdSHA1     = "{SHA}"
+	defaultAuthRealm = "Authentication Required"
+)
+
+// applyBasicAuth requires the credentials of a user of the htpasswd Secret of the
+// Ingress on the route. Only {SHA} entries can be checked by Envoy; other entries are
+// reported and left out. Routes whose Secret cannot be read answer with a 503.
+func (p *Parser) applyBasicAuth(ing *netv1.Ingress, r *resources.Route) {
+	anns := ing.GetAnnotations()
+	name := annotations.ExtractBasicAuthSecret(anns)
+	if name == "" {
+		return
+	}
+	secret, err := p.storer.GetSecret(ing.Namespace, name)
+	if err != nil {
+		p.closeRoute(ing, r, fmt.Errorf("basic auth: %v", err))
+		return
+	}
+
+	auth := &resources.BasicAuth{
+		Name:  fmt.Sprintf("%s.%s", ing.Namespace, ing.Name),
+		Realm: strings.ReplaceAll(annotations.ExtractBasicAuthRealm(anns), `"`, ""),
+	}
+	if auth.Realm == "" {
+		auth.Realm = defaultAuthRealm
+	}
+	for _, line := range strings.Split(string(secret.Data[htpasswdKey]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(hash, htpasswdSHA1) {
+			p.registerTranslationFailure(fmt.Sprintf("Secret %s/%s: entry of user %q is not a {SHA} htpasswd entry", ing.Namespace, name, user), ing)
+			continue
+		}
+		auth.Users = append(auth.Users, resources.BasicAuthUser{
+			Name:         user,
+			PasswordSHA1: strings.TrimPrefix(hash, htpasswdSHA1),
+		})
+	}
+	r.BasicAuth = auth
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestApplyBasicAuth(t *testing.T) {
+	htpasswd := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "htpasswd"},
+		Data: map[string][]byte{"auth": []byte(`# users
+alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=
+
+bob:$apr1$x1y2z3$abcdefghijklmnopqrstu/
+`)},
+	}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		want         *resources.BasicAuth
+		wantStatus   uint32
+		wantFailures []string
+	}{
+		{name: "none"},
+		{
+			name: "htpasswd",
+			annotations: map[string]string{
+				"inendless.com/basic-auth-secret": "htpasswd",
+				"inendless.com/basic-auth-realm":  `"Staff" only`,
+			},
+			want: &resources.BasicAuth{
+				Name:  "default.ingress",
+				Realm: "Staff only",
+				Users: []resources.BasicAuthUser{{Name: "alice", PasswordSHA1: "W6ph5Mm5Pz8GgiULbPgzG37mj9g="}},
+			},
+			wantFailures: []string{`entry of user "bob" is not a {SHA} htpasswd entry`},
+		},
+		{
+			name:         "missing Secret",
+			annotations:  map[string]string{"inendless.com/basic-auth-secret": "missing"},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantFailures: []string{"basic auth: "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testIngress("ingress", "web.example.com", tt.annotations)
+			p := newTestParser(t, Config{}, testService(), htpasswd, ing)
+			cache := p.Build()
+			assertFailures(t, p, tt.wantFailures...)
+
+			r := cache.Routes["default/ingress/0/0"]
+			if r.DirectResponseStatus != tt.wantStatus {
+				t.Errorf("route answers %d, want %d", r.DirectResponseStatus, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(r.BasicAuth, tt.want) {
+				t.Errorf("got basic auth %+v, want %+v", r.BasicAuth, tt.want)
+			}
+			var want []resources.BasicAuth
+			if tt.want != nil {
+				want = []resources.BasicAuth{*tt.want}
+			}
+			if got := cache.Listeners["listener_0"].BasicAuth; !reflect.DeepEqual(got, want) {
+				t.Errorf("got listener basic auth %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// the realm defaults when unset
+	ing := testIngress("ingress", "web.example.com", map[string]string{"inendless.com/basic-auth-secret": "htpasswd"})
+	p := newTestParser(t, Config{}, testService(), htpasswd, ing)
+	if got := p.Build().Routes["default/ingress/0/0"].BasicAuth.Realm; got != defaultAuthRealm {
+		t.Errorf("got realm %q, want %q", got, defaultAuthRealm)
+	}
+}
@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"time"
+)
+
+// applyServiceAnnotations configures load balancing, timeouts and circuit breaking of
+// the cluster from the annotations of its Service. Invalid values are reported and
+// left at Envoy's defaults.
+func (p *Parser) applyServiceAnnotations(svc *corev1.Service, cluster *resources.Cluster) {
+	anns := svc.Annotations
+
+	switch policy := annotations.ExtractLbPolicy(anns); policy {
+	case "":
+	case "round_robin", "least_request", "random":
+		cluster.LbPolicy = policy
+	case "ring_hash", "maglev":
+		cluster.LbPolicy = policy
+		cluster.HashPolicy = p.hashPolicy(svc)
+	default:
+		p.registerTranslationFailure(fmt.Sprintf("invalid load balancing policy %q", policy), svc)
+	}
+
+	if v := annotations.ExtractConnectTimeout(anns); v != "" {
+		cluster.ConnectTimeout = p.parseDuration(svc, annotations.ConnectTimeoutKey, v)
+	}
+	if v := annotations.ExtractMaxRequestsPerConnection(anns); v != "" {
+		cluster.MaxRequestsPerConnection = p.parseUint32(svc, annotations.MaxRequestsPerConnectionKey, v)
+	}
+	if v := annotations.ExtractMaxConnections(anns); v != "" {
+		cluster.CircuitBreakers.MaxConnections = p.parseUint32(svc, annotations.MaxConnectionsKey, v)
+	}
+	if v := annotations.ExtractMaxPendingRequests(anns); v != "" {
+		cluster.CircuitBreakers.MaxPendingRequests = p.parseUint32(svc, annotations.MaxPendingRequestsKey, v)
+	}
+	if v := annotations.ExtractMaxRequests(anns); v != "" {
+		cluster.CircuitBreakers.MaxRequests = p.parseUint32(svc, annotations.MaxRequestsKey, v)
+	}
+	if v := annotations.ExtractMaxRetries(anns); v != "" {
+		cluster.CircuitBreakers.MaxRetries = p.parseUint32(svc, annotations.MaxRetriesKey, v)
+	}
+
+	cluster.HealthCheck = p.healthCheck(svc)
+	if cluster.HealthCheck != nil && cluster.HealthCheck.Protocol == "grpc" {
+		cluster.HTTP2 = true
+	}
+	cluster.OutlierDetection = p.outlierDetection(svc)
+}
+
+func (p *Parser) healthCheck(svc *corev1.Service) *resources.HealthCheck {
+	anns := svc.Annotations
+	protocol := annotations.ExtractHealthCheck(anns)
+	switch protocol {
+	case "":
+		return nil
+	case "http", "grpc", "tcp":
+	default:
+		p.registerTranslationFailure(fmt.Sprintf("invalid health check protocol %q", protocol), svc)
+		return nil
+	}
+
+	hc := &resources.HealthCheck{
+		Protocol: protocol,
+		Path:     annotations.ExtractHealthCheckPath(anns),
+	}
+	if v := annotations.ExtractHealthCheckInterval(anns); v != "" {
+		hc.Interval = p.parseDuration(svc, annotations.HealthCheckIntervalKey, v)
+	}
+	if v := annotations.ExtractHealthCheckTimeout(anns); v != "" {
+		hc.Timeout = p.parseDuration(svc, annotations.HealthCheckTimeoutKey, v)
+	}
+	if v := annotations.ExtractHealthCheckHealthyThreshold(anns); v != "" {
+		hc.HealthyThreshold = p.parseUint32(svc, annotations.HealthCheckHealthyThresholdKey, v)
+	}
+	if v := annotations.ExtractHealthCheckUnhealthyThreshold(anns); v != "" {
+		hc.UnhealthyThreshold = p.parseUint32(svc, annotations.HealthCheckUnhealthyThresholdKey, v)
+	}
+	return hc
+}
+
+func (p *Parser) outlierDetection(svc *corev1.Service) *resources.OutlierDetection {
+	anns := svc.Annotations
+	od := &resources.OutlierDetection{}
+	if v := annotations.ExtractOutlierConsecutive5xx(anns); v != "" {
+		od.Consecutive5xx = p.parseUint32(svc, annotations.OutlierConsecutive5xxKey, v)
+	}
+	if v := annotations.ExtractOutlierInterval(anns); v != "" {
+		od.Interval = p.parseDuration(svc, annotations.OutlierIntervalKey, v)
+	}
+	if v := annotations.ExtractOutlierBaseEjectionTime(anns); v != "" {
+		od.BaseEjectionTime = p.parseDuration(svc, annotations.OutlierBaseEjectionTimeKey, v)
+	}
+	if v := annotations.ExtractOutlierMaxEjectionPercent(anns); v != "" {
+		od.MaxEjectionPercent = p.parseUint32(svc, annotations.OutlierMaxEjectionPercentKey, v)
+		if od.MaxEjectionPercent > 100 {
+			p.registerTranslationFailure("outlier max ejection percent cannot exceed 100", svc)
+			od.MaxEjectionPercent = 100
+		}
+	}
+	if *od == (resources.OutlierDetection{}) {
+		return nil
+	}
+	return od
+}
+
+func (p *Parser) hashPolicy(svc *corev1.Service) *resources.HashPolicy {
+	anns := svc.Annotations
+	switch hashOn := annotations.ExtractHashOn(anns); hashOn {
+	case "header":
+		if header := annotations.ExtractHashOnHeader(anns); header != "" {
+			return &resources.HashPolicy{Header: header}
+		}
+		p.registerTranslationFailure("hashing on header requires the header name", svc)
+	case "cookie":
+		if cookie := annotations.ExtractHashOnCookie(anns); cookie != "" {
+			hp := &resources.HashPolicy{Cookie: cookie}
+			if v := annotations.ExtractHashOnCookieTTL(anns); v != "" {
+				hp.CookieTTL = p.parseDuration(svc, annotations.HashOnCookieTTLKey, v)
+			}
+			return hp
+		}
+		p.registerTranslationFailure("hashing on cookie requires the cookie name", svc)
+	case "", "source_ip":
+		return &resources.HashPolicy{SourceIP: true}
+	default:
+		p.registerTranslationFailure(fmt.Sprintf("invalid hash-on value %q", hashOn), svc)
+	}
+	return nil
+}
+
+func (p *Parser) parseDuration(obj client.Object, key, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		p.registerTranslationFailure(fmt.Sprintf("invalid duration %q in annotation %s%s", value, annotations.AnnotationPrefix, key), obj)
+		return 0
+	}
+	return d
+}
+
+// parseTimeout parses a route timeout, "0s" disabling it.
+func (p *Parser) parseTimeout(obj client.Object, key, value string) time.Duration {
+	if d := p.parseDuration(obj, key, value); d != 0 {
+		return d
+	}
+	if d, err := time.ParseDuration(value); err == nil && d == 0 {
+		return resources.NoTimeout
+	}
+	return 0
+}
+
+func (p *Parser) parseUint32(obj client.Object, key, value string) uint32 {
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("invalid number %q in annotation %s%s", value, annotations.AnnotationPrefix, key), obj)
+		return 0
+	}
+	return uint32(n)
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyServiceAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        resources.Cluster
+		wantFailure string
+	}{
+		{name: "none"},
+		{
+			name: "least request with limits",
+			annotations: map[string]string{
+				"inendless.com/lb-policy":                   "least_request",
+				"inendless.com/connect-timeout":             "2s",
+				"inendless.com/max-requests-per-connection": "100",
+				"inendless.com/max-connections":             "10",
+				"inendless.com/max-pending-requests":        "20",
+				"inendless.com/max-requests":                "30",
+				"inendless.com/max-retries":                 "3",
+			},
+			want: resources.Cluster{
+				LbPolicy:                 "least_request",
+				ConnectTimeout:           2 * time.Second,
+				MaxRequestsPerConnection: 100,
+				CircuitBreakers:          resources.CircuitBreakers{MaxConnections: 10, MaxPendingRequests: 20, MaxRequests: 30, MaxRetries: 3},
+			},
+		},
+		{
+			name:        "ring hash on the source IP by default",
+			annotations: map[string]string{"inendless.com/lb-policy": "ring_hash"},
+			want:        resources.Cluster{LbPolicy: "ring_hash", HashPolicy: &resources.HashPolicy{SourceIP: true}},
+		},
+		{
+			name: "maglev on a cookie",
+			annotations: map[string]string{
+				"inendless.com/lb-policy":          "maglev",
+				"inendless.com/hash-on":            "cookie",
+				"inendless.com/hash-on-cookie":     "session",
+				"inendless.com/hash-on-cookie-ttl": "1h",
+			},
+			want: resources.Cluster{LbPolicy: "maglev", HashPolicy: &resources.HashPolicy{Cookie: "session", CookieTTL: time.Hour}},
+		},
+		{
+			name:        "header hash without a header",
+			annotations: map[string]string{"inendless.com/lb-policy": "ring_hash", "inendless.com/hash-on": "header"},
+			want:        resources.Cluster{LbPolicy: "ring_hash"},
+			wantFailure: "hashing on header requires the header name",
+		},
+		{
+			name: "gRPC health check with outlier detection",
+			annotations: map[string]string{
+				"inendless.com/health-check":                   "grpc",
+				"inendless.com/health-check-interval":          "5s",
+				"inendless.com/health-check-healthy-threshold": "1",
+				"inendless.com/outlier-consecutive-5xx":        "5",
+				"inendless.com/outlier-base-ejection-time":     "30s",
+			},
+			want: resources.Cluster{
+				HealthCheck:      &resources.HealthCheck{Protocol: "grpc", Interval: 5 * time.Second, HealthyThreshold: 1},
+				OutlierDetection: &resources.OutlierDetection{Consecutive5xx: 5, BaseEjectionTime: 30 * time.Second},
+				HTTP2:            true,
+			},
+		},
+		{
+			name:        "unknown health check protocol",
+			annotations: map[string]string{"inendless.com/health-check": "udp"},
+			wantFailure: `invalid health check protocol "udp"`,
+		},
+		{
+			name:        "ejection percent above 100",
+			annotations: map[string]string{"inendless.com/outlier-max-ejection-percent": "150"},
+			want:        resources.Cluster{OutlierDetection: &resources.OutlierDetection{MaxEjectionPercent: 100}},
+			wantFailure: "outlier max ejection percent cannot exceed 100",
+		},
+		{
+			name:        "unknown policy",
+			annotations: map[string]string{"inendless.com/lb-policy": "fastest"},
+			wantFailure: `invalid load balancing policy "fastest"`,
+		},
+		{
+			name:        "invalid connect timeout",
+			annotations: map[string]string{"inendless.com/connect-timeout": "5"},
+			wantFailure: `invalid duration "5" in annotation inendless.com/connect-timeout`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := testService()
+			svc.Annotations = tt.annotations
+			p := newTestParser(t, Config{})
+			var got resources.Cluster
+			p.applyServiceAnnotations(svc, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        time.Duration
+		wantFailure bool
+	}{
+		{value: "1m30s", want: 90 * time.Second},
+		{value: "250ms", want: 250 * time.Millisecond},
+		{value: "0s"},
+		{value: "-1s", wantFailure: true},
+		{value: "30", wantFailure: true},
+		{value: "soon", wantFailure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+			p := newTestParser(t, Config{})
+			if got := p.parseDuration(svc, "timeout", tt.value); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := len(p.TranslationFailures()) > 0; got != tt.wantFailure {
+				t.Errorf("got failures %v, want failure %v", p.TranslationFailures(), tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestParseUint32(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        uint32
+		wantFailure bool
+	}{
+		{value: "0"},
+		{value: "3", want: 3},
+		{value: "4294967295", want: 4294967295},
+		{value: "4294967296", wantFailure: true},
+		{value: "-1", wantFailure: true},
+		{value: "three", wantFailure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+			p := newTestParser(t, Config{})
+			if got := p.parseUint32(svc, "max-retries", tt.value); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+			if got := len(p.TranslationFailures()) > 0; got != tt.wantFailure {
+				t.Errorf("got failures %v, want failure %v", p.TranslationFailures(), tt.wantFailure)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// compressionPreference orders the compressors of a listener. The first one the
+// client accepts compresses the response.
+var compressionPreference = []string{"brotli", "gzip"}
+
+// applyCompressionAnnotations sets the compression algorithms of the route from its
+// annotation, or from the parser Config without one.
+func (p *Parser) applyCompressionAnnotations(obj client.Object, r *resources.Route) {
+	value := annotations.ExtractCompression(obj.GetAnnotations())
+	if value == "off" {
+		return
+	}
+	algorithms := p.cfg.Compression
+	if value != "" {
+		algorithms = splitList(value)
+	}
+	for _, algorithm := range algorithms {
+		if algorithm != "gzip" && algorithm != "brotli" {
+			p.registerTranslationFailure(fmt.Sprintf("invalid compression algorithm %q", algorithm), obj)
+			continue
+		}
+		r.Compression = append(r.Compression, algorithm)
+	}
+}
+
+func compressionAlgorithms(used map[string]bool) []string {
+	var algorithms []string
+	for _, algorithm := range compressionPreference {
+		if used[algorithm] {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
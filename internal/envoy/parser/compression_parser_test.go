@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyCompressionAnnotations(t *testing.T) {
+	tests := []struct {
+		name         string
+		compression  []string
+		annotations  map[string]string
+		want         []string
+		wantFailures []string
+	}{
+		{name: "none"},
+		{name: "from the Config", compression: []string{"gzip"}, want: []string{"gzip"}},
+		{
+			name:        "annotation overrides the Config",
+			compression: []string{"gzip"},
+			annotations: map[string]string{"inendless.com/compression": "brotli, gzip"},
+			want:        []string{"brotli", "gzip"},
+		},
+		{
+			name:        "off",
+			compression: []string{"gzip"},
+			annotations: map[string]string{"inendless.com/compression": "off"},
+		},
+		{
+			name:         "invalid algorithm",
+			annotations:  map[string]string{"inendless.com/compression": "gzip, zstd"},
+			want:         []string{"gzip"},
+			wantFailures: []string{`invalid compression algorithm "zstd"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testIngress("web", "web.example.com", tt.annotations)
+			p := newTestParser(t, Config{Compression: tt.compression}, testService(), ing)
+			cache := p.Build()
+			assertFailures(t, p, tt.wantFailures...)
+			if got := cache.Routes["default/web/0/0"].Compression; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got compression %v, want %v", got, tt.want)
+			}
+			// the listener installs the compressors in order of preference
+			var want []string
+			for _, algorithm := range compressionPreference {
+				for _, a := range tt.want {
+					if a == algorithm {
+						want = append(want, a)
+					}
+				}
+			}
+			if got := cache.Listeners["listener_0"].Compression; !reflect.DeepEqual(got, want) {
+				t.Errorf("got listener compression %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMaxRequestBytes(t *testing.T) {
+	limited := testIngress("limited", "limited.example.com", map[string]string{"inendless.com/max-request-bytes": "1024"})
+	p := newTestParser(t, Config{}, testService(), limited, testIngress("web", "web.example.com", nil))
+	cache := p.Build()
+	assertFailures(t, p)
+	if got := cache.Routes["default/limited/0/0"].MaxRequestBytes; got != 1024 {
+		t.Errorf("got limit %d, want 1024", got)
+	}
+	if got := cache.Routes["default/web/0/0"].MaxRequestBytes; got != 0 {
+		t.Errorf("got limit %d of a route without the annotation, want none", got)
+	}
+	if !cache.Listeners["listener_0"].Buffering {
+		t.Error("the listener does not buffer requests")
+	}
+}
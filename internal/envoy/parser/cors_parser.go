@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+var defaultCorsAllowMethods = []string{"GET", "PUT", "POST", "DELETE", "PATCH", "OPTIONS"}
+
+// applyCorsAnnotations sets the CORS policy of the virtual host of a route. Without
+// explicit origins, or with the * origin, any origin is allowed, but without
+// credentials.
+func (p *Parser) applyCorsAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+	if !annotations.ExtractEnableCors(anns) {
+		return
+	}
+
+	cors := &resources.CorsPolicy{
+		AllowMethods:     splitList(annotations.ExtractCorsAllowMethods(anns)),
+		AllowHeaders:     splitList(annotations.ExtractCorsAllowHeaders(anns)),
+		ExposeHeaders:    splitList(annotations.ExtractCorsExposeHeaders(anns)),
+		AllowCredentials: annotations.ExtractCorsAllowCredentials(anns),
+	}
+	anyOrigin := false
+	for _, origin := range splitList(annotations.ExtractCorsAllowOrigin(anns)) {
+		if origin == "*" {
+			anyOrigin = true
+			continue
+		}
+		cors.AllowOrigins = append(cors.AllowOrigins, origin)
+	}
+	for _, regex := range splitList(annotations.ExtractCorsAllowOriginRegex(anns)) {
+		if _, err := regexp.Compile(regex); err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("invalid CORS origin regex %q: %v", regex, err), obj)
+			continue
+		}
+		cors.AllowOriginRegexes = append(cors.AllowOriginRegexes, regex)
+	}
+	if anyOrigin || (len(cors.AllowOrigins) == 0 && len(cors.AllowOriginRegexes) == 0) {
+		// any origin could then read the responses with the cookies of the user
+		if cors.AllowCredentials {
+			p.registerTranslationFailure(fmt.Sprintf("annotation %s%s requires explicit origins, credentials are not allowed",
+				annotations.AnnotationPrefix, annotations.CorsAllowCredentialsKey), obj)
+			cors.AllowCredentials = false
+		}
+		cors.AllowOrigins = nil
+		cors.AllowOriginRegexes = []string{".*"}
+	}
+	if len(cors.AllowMethods) == 0 {
+		cors.AllowMethods = defaultCorsAllowMethods
+	}
+	if v := annotations.ExtractCorsMaxAge(anns); v != "" {
+		cors.MaxAge = p.parseDuration(obj, annotations.CorsMaxAgeKey, v)
+	}
+	r.VirtualHostCors = cors
+}
+
+// splitList splits a comma separated annotation value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
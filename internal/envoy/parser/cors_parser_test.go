@@ -0,0 +1,98 @@
+package parser
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyCorsAnnotations(t *testing.T) {
+	key := func(k string) string { return annotations.AnnotationPrefix + k }
+	tests := []struct {
+		name     string
+		anns     map[string]string
+		want     *resources.CorsPolicy
+		failures []string
+	}{
+		{name: "disabled", anns: map[string]string{key(annotations.CorsAllowOriginKey): "https://example.com"}},
+		{
+			name: "any origin",
+			anns: map[string]string{key(annotations.EnableCorsKey): "true"},
+			want: &resources.CorsPolicy{AllowOriginRegexes: []string{".*"}, AllowMethods: defaultCorsAllowMethods},
+		},
+		{
+			name: "credentials",
+			anns: map[string]string{
+				key(annotations.EnableCorsKey):           "true",
+				key(annotations.CorsAllowOriginKey):      "https://a.example.com, https://b.example.com",
+				key(annotations.CorsAllowCredentialsKey): "true",
+				key(annotations.CorsAllowMethodsKey):     "GET,POST",
+				key(annotations.CorsMaxAgeKey):           "1h",
+				key(annotations.CorsAllowOriginRegexKey): `https://.*\.example\.org`,
+				key(annotations.CorsExposeHeadersKey):    "X-Request-Id",
+				key(annotations.CorsAllowHeadersKey):     "Authorization",
+			},
+			want: &resources.CorsPolicy{
+				AllowOrigins:       []string{"https://a.example.com", "https://b.example.com"},
+				AllowOriginRegexes: []string{`https://.*\.example\.org`},
+				AllowMethods:       []string{"GET", "POST"},
+				AllowHeaders:       []string{"Authorization"},
+				ExposeHeaders:      []string{"X-Request-Id"},
+				AllowCredentials:   true,
+				MaxAge:             time.Hour,
+			},
+		},
+		{
+			name: "credentials of any origin",
+			anns: map[string]string{
+				key(annotations.EnableCorsKey):           "true",
+				key(annotations.CorsAllowCredentialsKey): "true",
+			},
+			want:     &resources.CorsPolicy{AllowOriginRegexes: []string{".*"}, AllowMethods: defaultCorsAllowMethods},
+			failures: []string{"requires explicit origins"},
+		},
+		{
+			name: "wildcard origin",
+			anns: map[string]string{
+				key(annotations.EnableCorsKey):      "true",
+				key(annotations.CorsAllowOriginKey): "*",
+			},
+			want: &resources.CorsPolicy{AllowOriginRegexes: []string{".*"}, AllowMethods: defaultCorsAllowMethods},
+		},
+		{
+			name: "credentials of the wildcard origin",
+			anns: map[string]string{
+				key(annotations.EnableCorsKey):           "true",
+				key(annotations.CorsAllowOriginKey):      "https://a.example.com, *",
+				key(annotations.CorsAllowCredentialsKey): "true",
+			},
+			want:     &resources.CorsPolicy{AllowOriginRegexes: []string{".*"}, AllowMethods: defaultCorsAllowMethods},
+			failures: []string{"requires explicit origins"},
+		},
+		{
+			name: "invalid regex",
+			anns: map[string]string{
+				key(annotations.EnableCorsKey):           "true",
+				key(annotations.CorsAllowOriginRegexKey): "(",
+			},
+			want:     &resources.CorsPolicy{AllowOriginRegexes: []string{".*"}, AllowMethods: defaultCorsAllowMethods},
+			failures: []string{"invalid CORS origin regex"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, Config{})
+			obj := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: tt.anns}}
+			r := resources.Route{}
+			p.applyCorsAnnotations(obj, &r)
+			if !reflect.DeepEqual(r.VirtualHostCors, tt.want) {
+				t.Errorf("got %+v, want %+v", r.VirtualHostCors, tt.want)
+			}
+			assertFailures(t, p, tt.failures...)
+		})
+	}
+}
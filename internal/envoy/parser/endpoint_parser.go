@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"strings"
+)
+
+// defaultClusterDomain is the DNS domain of the Services of the cluster when the
+// parser Config has none.
+const defaultClusterDomain = "cluster.local"
+
+func (p *Parser) clusterForService(svc *corev1.Service, port *corev1.ServicePort) (resources.Cluster, error) {
+	cluster := resources.Cluster{
+		Name:               fmt.Sprintf("%s/%s/%d", svc.Namespace, svc.Name, port.Port),
+		Hostname:           p.serviceHostname(svc),
+		Origin:             serviceOrigin(svc),
+		DNSLookupFamily:    p.dnsLookupFamily(svc),
+		LocalityWeightedLb: annotations.ExtractLocalityWeightedLb(svc.Annotations),
+	}
+	cluster.Endpoints = p.getEndpoints(svc, port, cluster.DNSLookupFamily)
+	p.applyServiceAnnotations(svc, &cluster)
+
+	var appProtocol string
+	if port.AppProtocol != nil {
+		appProtocol = *port.AppProtocol
+	}
+	tls, err := p.upstreamTLS(svc, port.Name, appProtocol)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	cluster.UpstreamTLS = tls
+	cluster.WebSocket = isWebSocket(appProtocol)
+	return cluster, nil
+}
+
+// localCluster adds the cluster of the proxies, grouped by zone like any other EDS
+// cluster, and turns on zone-aware routing to the EDS clusters that do not balance by
+// locality weights. The proxies then send most requests to the endpoints of their
+// own zone, which they learn from the locality of their node.
+func (p *Parser) localCluster(cache *xdscache.Cache) {
+	if p.cfg.ProxyService == "" {
+		return
+	}
+	namespace, name, ok := strings.Cut(p.cfg.ProxyService, "/")
+	if !ok || namespace == "" || name == "" {
+		p.registerTranslationFailure(fmt.Sprintf("proxy service %q is not of the form namespace/name", p.cfg.ProxyService), nil)
+		return
+	}
+	svc, err := p.storer.GetService(namespace, name)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("proxy service: %v", err), nil)
+		return
+	}
+	if len(svc.Spec.Ports) == 0 {
+		p.registerTranslationFailure(fmt.Sprintf("proxy service %s/%s has no ports", namespace, name), nil)
+		return
+	}
+
+	for name, c := range cache.Clusters {
+		if c.Type == resources.EDSCluster && !c.LocalityWeightedLb {
+			c.ZoneAwareLb = true
+			cache.Clusters[name] = c
+		}
+	}
+	family := p.dnsLookupFamily(svc)
+	cache.Clusters[resources.LocalClusterName] = resources.Cluster{
+		Name:            resources.LocalClusterName,
+		Origin:          serviceOrigin(svc),
+		Endpoints:       p.getEndpoints(svc, &svc.Spec.Ports[0], family),
+		DNSLookupFamily: family,
+	}
+}
+
+// isWebSocket reports whether a Service port speaks WebSocket, over TLS or not.
+func isWebSocket(appProtocol string) bool {
+	switch appProtocol {
+	case "ws", "wss", "kubernetes.io/ws", "kubernetes.io/wss":
+		return true
+	}
+	return false
+}
+
+// serviceHostname returns the DNS name of a Service: the external hostname of an
+// ExternalName Service, and its name in the cluster domain otherwise.
+func (p *Parser) serviceHostname(svc *corev1.Service) string {
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return svc.Spec.ExternalName
+	}
+	domain := p.cfg.ClusterDomain
+	if domain == "" {
+		domain = defaultClusterDomain
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, domain)
+}
+
+func serviceOrigin(svc *corev1.Service) *resources.Origin {
+	return &resources.Origin{Kind: "Service", Namespace: svc.Namespace, Name: svc.Name, UID: string(svc.UID)}
+}
+
+// dnsLookupFamily returns the DNS lookup family of the clusters of a Service, its
+// annotation overriding the family of the parser Config. An invalid annotation is
+// reported and the family of the Config used instead.
+func (p *Parser) dnsLookupFamily(svc *corev1.Service) string {
+	family := annotations.ExtractDNSLookupFamily(svc.Annotations)
+	if family == "" {
+		return p.cfg.DNSLookupFamily
+	}
+	if !resources.IsDNSLookupFamily(family) {
+		reason := fmt.Sprintf("invalid DNS lookup family %q in annotation %s%s", family, annotations.AnnotationPrefix, annotations.DNSLookupFamilyKey)
+		if !p.hasTranslationFailure(svc, reason) {
+			p.registerTranslationFailure(reason, svc)
+		}
+		return p.cfg.DNSLookupFamily
+	}
+	return family
+}
+
+// clusterForExternalName builds a DNS cluster targeting the external hostname of an
+// ExternalName Service. Such Services need not declare ports, in which case the
+// port number of the backend is used as is.
+func (p *Parser) clusterForExternalName(svc *corev1.Service, backendPort netv1.ServiceBackendPort) (resources.Cluster, error) {
+	var port int32
+	var portName, appProtocol string
+	if len(svc.Spec.Ports) > 0 {
+		sp, err := resolveServicePort(svc, backendPort)
+		if err != nil {
+			return resources.Cluster{}, err
+		}
+		port, portName = sp.Port, sp.Name
+		if sp.AppProtocol != nil {
+			appProtocol = *sp.AppProtocol
+		}
+	} else if backendPort.Number != 0 {
+		port = backendPort.Number
+	} else {
+		return resources.Cluster{}, fmt.Errorf("ExternalName Service %s/%s declares no port named %q", svc.Namespace, svc.Name, backendPort.Name)
+	}
+
+	cluster := resources.Cluster{
+		Name:     fmt.Sprintf("%s/%s/%d", svc.Namespace, svc.Name, port),
+		Hostname: p.serviceHostname(svc),
+		Origin:   serviceOrigin(svc),
+		Type:     p.dnsClusterType(svc),
+		Endpoints: []resources.Endpoint{{
+			UpstreamHost: svc.Spec.ExternalName,
+			UpstreamPort: uint32(port),
+		}},
+		DNSLookupFamily: p.dnsLookupFamily(svc),
+	}
+	p.applyServiceAnnotations(svc, &cluster)
+
+	tls, err := p.upstreamTLS(svc, portName, appProtocol)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	cluster.UpstreamTLS = tls
+	cluster.WebSocket = isWebSocket(appProtocol)
+	return cluster, nil
+}
+
+// dnsClusterType returns the type of the DNS cluster of an ExternalName Service:
+// logical when its dns-discovery annotation asks for it, else strict. An invalid
+// annotation is reported and the cluster left strict.
+func (p *Parser) dnsClusterType(svc *corev1.Service) resources.ClusterType {
+	switch discovery := annotations.ExtractDNSDiscovery(svc.Annotations); discovery {
+	case "", "strict":
+		return resources.StrictDNSCluster
+	case "logical":
+		return resources.LogicalDNSCluster
+	default:
+		reason := fmt.Sprintf("invalid DNS discovery %q in annotation %s%s, using strict", discovery, annotations.AnnotationPrefix, annotations.DNSDiscoveryKey)
+		if !p.hasTranslationFailure(svc, reason) {
+			p.registerTranslationFailure(reason, svc)
+		}
+		return resources.StrictDNSCluster
+	}
+}
+
+// resolveServicePort finds the Service port an Ingress backend refers to, either by
+// its name or by its port number. The backing endpoints are then looked up by the
+// name of that port, which already carries the resolved targetPort.
+func resolveServicePort(svc *corev1.Service, port netv1.ServiceBackendPort) (*corev1.ServicePort, error) {
+	for i, sp := range svc.Spec.Ports {
+		if port.Name != "" && sp.Name == port.Name {
+			return &svc.Spec.Ports[i], nil
+		}
+		if port.Name == "" && port.Number != 0 && sp.Port == port.Number {
+			return &svc.Spec.Ports[i], nil
+		}
+	}
+	if port.Name == "" && port.Number == 0 && len(svc.Spec.Ports) == 1 {
+		return &svc.Spec.Ports[0], nil
+	}
+	if port.Name != "" {
+		return nil, fmt.Errorf("Service %s/%s has no port named %q", svc.Namespace, svc.Name, port.Name)
+	}
+	return nil, fmt.Errorf("Service %s/%s has no port %d", svc.Namespace, svc.Name, port.Number)
+}
+
+// getEndpoints returns the ready endpoints backing a Service port. EndpointSlices are
+// preferred since they carry the zone of each endpoint; the older Endpoints object is
+// used as a fallback, with the zone taken from the topology labels of the node.
+func (p *Parser) getEndpoints(svc *corev1.Service, port *corev1.ServicePort, family string) []resources.Endpoint {
+	if slices, err := p.storer.GetEndpointSlicesForService(svc.Namespace, svc.Name); err == nil {
+		return p.endpointsFromSlices(slices, port, addressType(svc, slices, family))
+	}
+	eps, err := p.storer.GetEndpointsForService(svc.Namespace, svc.Name)
+	if err != nil {
+		return nil
+	}
+	return p.endpointsFromEndpoints(eps, port)
+}
+
+// addressType returns the address family of the endpoints of a Service. Dual-stack
+// Services have a slice of each family for the same pods, so only one family is used,
+// picked by the lookup family of the cluster: IPv4 for v4_only and v4_preferred, IPv6
+// for v6_only and auto, and the primary family of the Service for all.
+func addressType(svc *corev1.Service, slices []*discoveryv1.EndpointSlice, family string) discoveryv1.AddressType {
+	available := map[discoveryv1.AddressType]bool{}
+	for _, slice := range slices {
+		available[slice.AddressType] = true
+	}
+	switch {
+	case !available[discoveryv1.AddressTypeIPv6]:
+		return discoveryv1.AddressTypeIPv4
+	case !available[discoveryv1.AddressTypeIPv4]:
+		return discoveryv1.AddressTypeIPv6
+	}
+	switch family {
+	case "v4_only", "v4_preferred":
+		return discoveryv1.AddressTypeIPv4
+	case "v6_only", "auto":
+		return discoveryv1.AddressTypeIPv6
+	}
+	if len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+		return discoveryv1.AddressTypeIPv6
+	}
+	return discoveryv1.AddressTypeIPv4
+}
+
+func (p *Parser) endpointsFromSlices(slices []*discoveryv1.EndpointSlice, port *corev1.ServicePort, addressType discoveryv1.AddressType) []resources.Endpoint {
+	var endpoints []resources.Endpoint
+	for _, slice := range slices {
+		if slice.AddressType != addressType {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			region, zone := p.nodeTopology(ep.NodeName)
+			if ep.Zone != nil {
+				zone = *ep.Zone
+			}
+			for _, epPort := range slice.Ports {
+				if epPort.Port == nil || (epPort.Name != nil && *epPort.Name != port.Name) ||
+					(epPort.Name == nil && port.Name != "") {
+					continue
+				}
+				for _, addr := range ep.Addresses {
+					endpoints = append(endpoints, resources.Endpoint{
+						UpstreamHost: addr,
+						UpstreamPort: uint32(*epPort.Port),
+						Region:       region,
+						Zone:         zone,
+					})
+				}
+			}
+		}
+	}
+	return endpoints
+}
+
+func (p *Parser) endpointsFromEndpoints(eps *corev1.Endpoints, port *corev1.ServicePort) []resources.Endpoint {
+	var endpoints []resources.Endpoint
+	for _, subset := range eps.Subsets {
+		for _, epPort := range subset.Ports {
+			if epPort.Name != port.Name {
+				continue
+			}
+			for _, addr := range subset.Addresses {
+				region, zone := p.nodeTopology(addr.NodeName)
+				endpoints = append(endpoints, resources.Endpoint{
+					UpstreamHost: addr.IP,
+					UpstreamPort: uint32(epPort.Port),
+					Region:       region,
+					Zone:         zone,
+				})
+			}
+		}
+	}
+	return endpoints
+}
+
+func (p *Parser) nodeTopology(nodeName *string) (region string, zone string) {
+	if nodeName == nil {
+		return "", ""
+	}
+	node, err := p.storer.GetNode(*nodeName)
+	if err != nil {
+		return "", ""
+	}
+	region = node.Labels[corev1.LabelTopologyRegion]
+	zone = node.Labels[corev1.LabelTopologyZone]
+	if zone == "" {
+		zone = node.Labels[corev1.LabelFailureDomainBetaZone]
+	}
+	return region, zone
+}
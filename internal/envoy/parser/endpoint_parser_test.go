@@ -0,0 +1,378 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func endpointSlice(name string, addressType discoveryv1.AddressType, addresses ...string) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+		},
+		AddressType: addressType,
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  addresses,
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(true)},
+		}},
+		Ports: []discoveryv1.EndpointPort{{Name: stringPtr("http"), Port: int32Ptr(8080)}},
+	}
+}
+
+func TestLocalCluster(t *testing.T) {
+	proxySlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ingress",
+			Name:      "envoy-abc",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "envoy"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.1.0.1"}, Zone: stringPtr("zone-a")},
+			{Addresses: []string{"10.2.0.1"}, Zone: stringPtr("zone-b")},
+		},
+		Ports: []discoveryv1.EndpointPort{{Name: stringPtr("http"), Port: int32Ptr(8080)}},
+	}
+	proxy := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress", Name: "envoy"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+	weighted := testService()
+	weighted.Name = "weighted"
+	weighted.Annotations = map[string]string{"inendless.com/locality-weighted-lb": "true"}
+	ingWeighted := testIngress("weighted", "weighted.example.com", nil)
+	ingWeighted.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name = "weighted"
+	objects := []runtime.Object{proxySlice, proxy, testService(), weighted,
+		testIngress("web", "web.example.com", nil), ingWeighted}
+
+	tests := []struct {
+		name         string
+		proxyService string
+		wantLocal    []resources.Endpoint
+		wantZone     bool
+		failure      string
+	}{
+		{name: "no proxy service"},
+		{
+			name:         "proxy service",
+			proxyService: "ingress/envoy",
+			wantLocal: []resources.Endpoint{
+				{UpstreamHost: "10.1.0.1", UpstreamPort: 8080, Zone: "zone-a"},
+				{UpstreamHost: "10.2.0.1", UpstreamPort: 8080, Zone: "zone-b"},
+			},
+			wantZone: true,
+		},
+		{name: "malformed", proxyService: "envoy", failure: `proxy service "envoy" is not of the form namespace/name`},
+		{name: "missing", proxyService: "ingress/missing", failure: "proxy service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, Config{ProxyService: tt.proxyService}, objects...)
+			cache := p.Build()
+
+			local, ok := cache.Clusters[resources.LocalClusterName]
+			if ok != (tt.wantLocal != nil) {
+				t.Fatalf("local cluster present %v, want %v", ok, tt.wantLocal != nil)
+			}
+			if !reflect.DeepEqual(local.Endpoints, tt.wantLocal) {
+				t.Errorf("local endpoints %v, want %v", local.Endpoints, tt.wantLocal)
+			}
+			if got := cache.Clusters["default/web/80"].ZoneAwareLb; got != tt.wantZone {
+				t.Errorf("web cluster zone-aware %v, want %v", got, tt.wantZone)
+			}
+			if cache.Clusters["default/weighted/80"].ZoneAwareLb {
+				t.Error("locality-weighted cluster is zone-aware")
+			}
+			if tt.failure == "" {
+				assertFailures(t, p)
+			} else {
+				assertFailures(t, p, tt.failure)
+			}
+		})
+	}
+}
+
+func TestResolveServicePort(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80},
+			{Name: "https", Port: 443},
+		}},
+	}
+	single := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+	tests := []struct {
+		name    string
+		svc     *corev1.Service
+		port    netv1.ServiceBackendPort
+		want    int32
+		wantErr bool
+	}{
+		{name: "by name", svc: svc, port: netv1.ServiceBackendPort{Name: "https"}, want: 443},
+		{name: "by number", svc: svc, port: netv1.ServiceBackendPort{Number: 80}, want: 80},
+		{name: "only port", svc: single, want: 80},
+		{name: "unset port of several", svc: svc, wantErr: true},
+		{name: "unknown name", svc: svc, port: netv1.ServiceBackendPort{Name: "grpc"}, wantErr: true},
+		{name: "unknown number", svc: svc, port: netv1.ServiceBackendPort{Number: 8080}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveServicePort(tt.svc, tt.port)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Port != tt.want {
+				t.Errorf("got port %d, want %d", got.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEndpointsAddressFamily(t *testing.T) {
+	v4 := endpointSlice("web-v4", discoveryv1.AddressTypeIPv4, "10.0.0.1")
+	v6 := endpointSlice("web-v6", discoveryv1.AddressTypeIPv6, "fd00::1")
+	ipv4 := []resources.Endpoint{{UpstreamHost: "10.0.0.1", UpstreamPort: 8080}}
+	ipv6 := []resources.Endpoint{{UpstreamHost: "fd00::1", UpstreamPort: 8080}}
+
+	tests := []struct {
+		name     string
+		families []corev1.IPFamily
+		slices   []runtime.Object
+		family   string
+		want     []resources.Endpoint
+	}{
+		{name: "single-stack IPv4", slices: []runtime.Object{v4}, family: "v6_only", want: ipv4},
+		{name: "single-stack IPv6", slices: []runtime.Object{v6}, family: "v4_only", want: ipv6},
+		{name: "dual-stack v4_only", slices: []runtime.Object{v4, v6}, family: "v4_only", want: ipv4},
+		{name: "dual-stack v4_preferred", slices: []runtime.Object{v4, v6}, family: "v4_preferred", want: ipv4},
+		{name: "dual-stack v6_only", slices: []runtime.Object{v4, v6}, family: "v6_only", want: ipv6},
+		{name: "dual-stack auto", slices: []runtime.Object{v4, v6}, family: "auto", want: ipv6},
+		{
+			name:     "dual-stack all, IPv6 primary",
+			families: []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+			slices:   []runtime.Object{v4, v6},
+			family:   "all",
+			want:     ipv6,
+		},
+		{
+			name:     "dual-stack all, IPv4 primary",
+			families: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol},
+			slices:   []runtime.Object{v4, v6},
+			family:   "all",
+			want:     ipv4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec: corev1.ServiceSpec{
+					IPFamilies: tt.families,
+					Ports:      []corev1.ServicePort{{Name: "http", Port: 80}},
+				},
+			}
+			p := newTestParser(t, Config{}, tt.slices...)
+			got := p.getEndpoints(svc, &svc.Spec.Ports[0], tt.family)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSLookupFamily(t *testing.T) {
+	p := newTestParser(t, Config{DNSLookupFamily: "v4_preferred"})
+	svc := testService()
+	if got := p.dnsLookupFamily(svc); got != "v4_preferred" {
+		t.Errorf("got %q, want the configured v4_preferred", got)
+	}
+	svc.Annotations = map[string]string{"inendless.com/dns-lookup-family": "v6_only"}
+	if got := p.dnsLookupFamily(svc); got != "v6_only" {
+		t.Errorf("got %q, want v6_only of the annotation", got)
+	}
+	assertFailures(t, p)
+
+	svc.Annotations = map[string]string{"inendless.com/dns-lookup-family": "ipv6"}
+	for i := 0; i < 2; i++ {
+		if got := p.dnsLookupFamily(svc); got != "v4_preferred" {
+			t.Errorf("got %q for an invalid annotation, want the configured v4_preferred", got)
+		}
+	}
+	assertFailures(t, p, `invalid DNS lookup family "ipv6" in annotation inendless.com/dns-lookup-family`)
+}
+
+func TestServiceHostname(t *testing.T) {
+	svc := testService()
+	if got := newTestParser(t, Config{}).serviceHostname(svc); got != "web.default.svc.cluster.local" {
+		t.Errorf("got %q in the default cluster domain", got)
+	}
+	if got := newTestParser(t, Config{ClusterDomain: "corp.internal"}).serviceHostname(svc); got != "web.default.svc.corp.internal" {
+		t.Errorf("got %q in the corp.internal cluster domain", got)
+	}
+}
+
+func TestGetEndpoints(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{
+		corev1.LabelTopologyRegion: "eu-west-1",
+		corev1.LabelTopologyZone:   "eu-west-1a",
+	}}}
+	legacyNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b", Labels: map[string]string{
+		corev1.LabelFailureDomainBetaZone: "eu-west-1c",
+	}}}
+	zone := "eu-west-1b"
+	slice := endpointSlice("web-v4", discoveryv1.AddressTypeIPv4, "10.0.0.1")
+	slice.Endpoints = append(slice.Endpoints,
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false)}},
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.3"}, NodeName: stringPtr("node-a")},
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.4"}, NodeName: stringPtr("node-a"), Zone: &zone},
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.5"}, NodeName: stringPtr("node-b")},
+		// hints are where the endpoint should be consumed, not where it runs
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.6"}, NodeName: stringPtr("node-a"), Hints: &discoveryv1.EndpointHints{
+			ForZones: []discoveryv1.ForZone{{Name: "eu-west-1c"}},
+		}},
+	)
+	slice.Ports = append(slice.Ports, discoveryv1.EndpointPort{Name: stringPtr("metrics"), Port: int32Ptr(9090)})
+	otherService := endpointSlice("api-v4", discoveryv1.AddressTypeIPv4, "10.0.2.1")
+	otherService.Labels[discoveryv1.LabelServiceName] = "api"
+	otherNamespace := endpointSlice("web-v4", discoveryv1.AddressTypeIPv4, "10.0.3.1")
+	otherNamespace.Namespace = "team"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.1.1", NodeName: stringPtr("node-a")}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.1.2"}},
+			Ports:             []corev1.EndpointPort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    []resources.Endpoint
+	}{
+		{name: "none"},
+		{
+			name:    "EndpointSlices",
+			objects: []runtime.Object{node, legacyNode, slice, otherService, otherNamespace, endpoints},
+			want: []resources.Endpoint{
+				{UpstreamHost: "10.0.0.1", UpstreamPort: 8080},
+				{UpstreamHost: "10.0.0.3", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1a"},
+				{UpstreamHost: "10.0.0.4", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1b"},
+				{UpstreamHost: "10.0.0.5", UpstreamPort: 8080, Zone: "eu-west-1c"},
+				{UpstreamHost: "10.0.0.6", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1a"},
+			},
+		},
+		{
+			name:    "Endpoints",
+			objects: []runtime.Object{node, endpoints},
+			want:    []resources.Endpoint{{UpstreamHost: "10.0.1.1", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := testService()
+			p := newTestParser(t, Config{}, tt.objects...)
+			got := p.getEndpoints(svc, &svc.Spec.Ports[0], "v4_only")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterForExternalName(t *testing.T) {
+	svc := func(anns map[string]string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ext", Annotations: anns},
+			Spec: corev1.ServiceSpec{
+				Type:         corev1.ServiceTypeExternalName,
+				ExternalName: "api.example.com",
+				Ports:        ports,
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		svc         *corev1.Service
+		port        netv1.ServiceBackendPort
+		want        resources.Cluster
+		wantErr     bool
+		wantFailure string
+	}{
+		{
+			name: "port of the backend",
+			svc:  svc(nil),
+			port: netv1.ServiceBackendPort{Number: 443},
+			want: resources.Cluster{
+				Name:            "default/ext/443",
+				Hostname:        "api.example.com",
+				Origin:          &resources.Origin{Kind: "Service", Namespace: "default", Name: "ext"},
+				Type:            resources.StrictDNSCluster,
+				Endpoints:       []resources.Endpoint{{UpstreamHost: "api.example.com", UpstreamPort: 443}},
+				DNSLookupFamily: "v4_only",
+			},
+		},
+		{
+			name: "named port of the Service",
+			svc: svc(map[string]string{
+				"inendless.com/dns-discovery":     "logical",
+				"inendless.com/dns-lookup-family": "v6_only",
+			}, corev1.ServicePort{Name: "https", Port: 8443}),
+			port: netv1.ServiceBackendPort{Name: "https"},
+			want: resources.Cluster{
+				Name:            "default/ext/8443",
+				Hostname:        "api.example.com",
+				Origin:          &resources.Origin{Kind: "Service", Namespace: "default", Name: "ext"},
+				Type:            resources.LogicalDNSCluster,
+				Endpoints:       []resources.Endpoint{{UpstreamHost: "api.example.com", UpstreamPort: 8443}},
+				DNSLookupFamily: "v6_only",
+			},
+		},
+		{
+			name: "invalid annotations",
+			svc: svc(map[string]string{
+				"inendless.com/dns-discovery":     "eds",
+				"inendless.com/dns-lookup-family": "v5_only",
+			}),
+			port: netv1.ServiceBackendPort{Number: 443},
+			want: resources.Cluster{
+				Name:            "default/ext/443",
+				Hostname:        "api.example.com",
+				Origin:          &resources.Origin{Kind: "Service", Namespace: "default", Name: "ext"},
+				Type:            resources.StrictDNSCluster,
+				Endpoints:       []resources.Endpoint{{UpstreamHost: "api.example.com", UpstreamPort: 443}},
+				DNSLookupFamily: "v4_only",
+			},
+			wantFailure: `invalid DNS discovery "eds" in annotation inendless.com/dns-discovery, using strict`,
+		},
+		{name: "named port without Service ports", svc: svc(nil), port: netv1.ServiceBackendPort{Name: "https"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, Config{DNSLookupFamily: "v4_only"})
+			got, err := p.clusterForExternalName(tt.svc, tt.port)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure, `invalid DNS lookup family "v5_only"`)
+		})
+	}
+}
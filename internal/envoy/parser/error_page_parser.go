@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultErrorPageContentType = "text/html; charset=utf-8"
+
+// errorPages returns the custom error pages of an Ingress, read from its ConfigMap and
+// from the inline entries of the annotation, which win over the ConfigMap. A ConfigMap
+// that cannot be read is reported and the default bodies are kept.
+func (p *Parser) errorPages(ing *netv1.Ingress) []resources.ErrorPage {
+	anns := ing.GetAnnotations()
+	bodies := map[uint32]string{}
+	if name := annotations.ExtractErrorPagesConfigMap(anns); name != "" {
+		configMap, err := p.storer.GetConfigMap(ing.Namespace, name)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("error pages: %v", err), ing)
+		} else {
+			for key, body := range configMap.Data {
+				code, err := parseStatusCode(key, 400)
+				if err != nil {
+					p.registerTranslationFailure(fmt.Sprintf("ConfigMap %s/%s: %v", ing.Namespace, name, err), ing)
+					continue
+				}
+				bodies[code] = body
+			}
+		}
+	}
+	for _, line := range strings.Split(annotations.ExtractErrorPages(anns), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, body, ok := strings.Cut(line, "=")
+		if !ok {
+			p.registerTranslationFailure(fmt.Sprintf("entry %q of annotation %s%s is not of the form code=body", line, annotations.AnnotationPrefix, annotations.ErrorPagesKey), ing)
+			continue
+		}
+		code, err := parseStatusCode(strings.TrimSpace(key), 400)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("annotation %s%s: %v", annotations.AnnotationPrefix, annotations.ErrorPagesKey, err), ing)
+			continue
+		}
+		bodies[code] = body
+	}
+
+	contentType := annotations.ExtractErrorPagesContentType(anns)
+	if contentType == "" {
+		contentType = defaultErrorPageContentType
+	}
+	var pages []resources.ErrorPage
+	for code, body := range bodies {
+		pages = append(pages, resources.ErrorPage{StatusCode: code, Body: body, ContentType: contentType})
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].StatusCode < pages[j].StatusCode })
+	return pages
+}
+
+// applyDirectResponse answers the requests of the route with the fixed response of
+// the Ingress instead of forwarding them, as for maintenance.
+func (p *Parser) applyDirectResponse(ing *netv1.Ingress, r *resources.Route) {
+	anns := ing.GetAnnotations()
+	value := annotations.ExtractDirectResponseStatus(anns)
+	if value == "" {
+		return
+	}
+	status, err := parseStatusCode(value, 200)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("annotation %s%s: %v", annotations.AnnotationPrefix, annotations.DirectResponseStatusKey, err), ing)
+		return
+	}
+	r.DirectResponseStatus = status
+	r.DirectResponseBody = annotations.ExtractDirectResponseBody(anns)
+}
+
+// defaultResponseRoutes answer the requests of the hosts of the rules of an Ingress
+// without a default backend that match none of its paths, when it declares a default
+// response. Hosts of no rule are left to the Ingresses serving them.
+func (p *Parser) defaultResponseRoutes(ing *netv1.Ingress) []resources.Route {
+	anns := ing.GetAnnotations()
+	value := annotations.ExtractDefaultResponseStatus(anns)
+	if value == "" {
+		return nil
+	}
+	status, err := parseStatusCode(value, 200)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("annotation %s%s: %v", annotations.AnnotationPrefix, annotations.DefaultResponseStatusKey, err), ing)
+		return nil
+	}
+	var routes []resources.Route
+	hosts := map[string]bool{}
+	for _, rule := range ing.Spec.Rules {
+		if hosts[rule.Host] {
+			continue
+		}
+		hosts[rule.Host] = true
+		name := fmt.Sprintf("%s/%s/default/%s", ing.Namespace, ing.Name, rule.Host)
+		if rule.Host == "" {
+			name = fmt.Sprintf("%s/%s/default/*", ing.Namespace, ing.Name)
+		}
+		routes = append(routes, resources.Route{
+			Name:                 name,
+			Host:                 rule.Host,
+			Prefix:               "/",
+			Origin:               ingressOrigin(ing),
+			DirectResponseStatus: status,
+			DirectResponseBody:   annotations.ExtractDefaultResponseBody(anns),
+		})
+	}
+	return routes
+}
+
+// parseStatusCode parses an HTTP status code no lower than min.
+func parseStatusCode(value string, min uint64) (uint32, error) {
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || code < min || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q", value)
+	}
+	return uint32(code), nil
+}
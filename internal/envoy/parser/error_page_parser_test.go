@@ -0,0 +1,139 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func TestErrorPages(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pages"},
+		Data:       map[string]string{"404": "not here", "503": "down"},
+	}
+	tests := []struct {
+		name        string
+		anns        map[string]string
+		want        []resources.ErrorPage
+		wantFailure string
+	}{
+		{name: "no error pages"},
+		{
+			name: "inline pages",
+			anns: map[string]string{
+				"inendless.com/error-pages":              "503=maintenance\n\n404=gone",
+				"inendless.com/error-pages-content-type": "text/plain",
+			},
+			want: []resources.ErrorPage{
+				{StatusCode: 404, Body: "gone", ContentType: "text/plain"},
+				{StatusCode: 503, Body: "maintenance", ContentType: "text/plain"},
+			},
+		},
+		{
+			name: "inline pages win over the ConfigMap",
+			anns: map[string]string{
+				"inendless.com/error-pages":           "503=maintenance",
+				"inendless.com/error-pages-configmap": "pages",
+			},
+			want: []resources.ErrorPage{
+				{StatusCode: 404, Body: "not here", ContentType: defaultErrorPageContentType},
+				{StatusCode: 503, Body: "maintenance", ContentType: defaultErrorPageContentType},
+			},
+		},
+		{
+			name:        "missing ConfigMap",
+			anns:        map[string]string{"inendless.com/error-pages-configmap": "missing"},
+			wantFailure: "error pages",
+		},
+		{
+			name:        "entry without a code",
+			anns:        map[string]string{"inendless.com/error-pages": "maintenance"},
+			wantFailure: "is not of the form code=body",
+		},
+		{
+			name:        "successful status code",
+			anns:        map[string]string{"inendless.com/error-pages": "200=ok"},
+			wantFailure: `invalid status code "200"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testIngress("web", "web.example.com", tt.anns)
+			p := newTestParser(t, Config{}, configMap, ing)
+			got := p.errorPages(ing)
+			if tt.wantFailure != "" {
+				assertFailures(t, p, tt.wantFailure)
+			} else {
+				assertFailures(t, p)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got error pages %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectResponse(t *testing.T) {
+	ing := testIngress("web", "web.example.com", map[string]string{
+		"inendless.com/direct-response-status": "503",
+		"inendless.com/direct-response-body":   "maintenance",
+		"inendless.com/error-pages":            "404=gone",
+	})
+	p := newTestParser(t, Config{}, testService(), ing)
+	cache := p.Build()
+	assertFailures(t, p)
+
+	r := cache.Routes["default/web/0/0"]
+	if r.DirectResponseStatus != 503 || r.DirectResponseBody != "maintenance" || r.Cluster != "" {
+		t.Errorf("got route %+v, want a direct response 503 maintenance", r)
+	}
+	if len(cache.Clusters) != 0 {
+		t.Errorf("got clusters %v of an Ingress answering every request itself", cache.Clusters)
+	}
+	if got := cache.Listeners["listener_0"].ErrorPageRoutes; len(got) != 1 || got[0].Name != "default/web/0/0" {
+		t.Errorf("got error page routes %+v, want the route of the Ingress", got)
+	}
+
+	p = newTestParser(t, Config{}, testService(), testIngress("web", "web.example.com", map[string]string{
+		"inendless.com/direct-response-status": "42",
+	}))
+	cache = p.Build()
+	assertFailures(t, p, `invalid status code "42"`)
+	if r := cache.Routes["default/web/0/0"]; r.DirectResponseStatus != 0 || r.Cluster != "default/web/80" {
+		t.Errorf("got route %+v, want the route to the Service", r)
+	}
+}
+
+func TestDefaultResponseRoute(t *testing.T) {
+	ing := testIngress("web", "web.example.com", map[string]string{
+		"inendless.com/default-response-status": "404",
+		"inendless.com/default-response-body":   "unknown",
+	})
+	p := newTestParser(t, Config{}, testService(), ing)
+	cache := p.Build()
+	assertFailures(t, p)
+
+	r, ok := cache.Routes["default/web/default/web.example.com"]
+	if !ok {
+		t.Fatal("no default response route of the Ingress")
+	}
+	if r.Host != "web.example.com" || r.Prefix != "/" || r.DirectResponseStatus != 404 || r.DirectResponseBody != "unknown" {
+		t.Errorf("got route %+v, want a direct response 404 unknown of web.example.com/", r)
+	}
+	for name, r := range cache.Routes {
+		if r.Host == "" {
+			t.Errorf("route %s answers the requests of any host", name)
+		}
+	}
+
+	p = newTestParser(t, Config{}, testService(), testIngress("web", "web.example.com", map[string]string{
+		"inendless.com/default-response-status": "none",
+	}))
+	cache = p.Build()
+	assertFailures(t, p, `invalid status code "none"`)
+	if _, ok := cache.Routes["default/web/default/web.example.com"]; ok {
+		t.Error("got a default response route with an invalid status code")
+	}
+}
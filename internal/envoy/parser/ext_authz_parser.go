@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"hash/fnv"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"strings"
+)
+
+const extAuthzFilterPrefix = "envoy.filters.http.ext_authz."
+
+// applyExtAuthz guards the route with the authorization service of the Ingress,
+// unless path is one of its skipped paths. Routes whose authorization service cannot
+// be resolved are closed with a 503 rather than left unprotected.
+func (p *Parser) applyExtAuthz(ing *netv1.Ingress, r *resources.Route, path string, cache *xdscache.Cache) {
+	anns := ing.GetAnnotations()
+	ref := annotations.ExtractExtAuthzService(anns)
+	if ref == "" {
+		return
+	}
+	for _, skip := range splitList(annotations.ExtractExtAuthzSkipPaths(anns)) {
+		if skip == path {
+			return
+		}
+	}
+
+	authz := resources.ExtAuthz{
+		GRPC:             true,
+		PathPrefix:       annotations.ExtractExtAuthzPathPrefix(anns),
+		FailureModeAllow: annotations.ExtractExtAuthzFailureModeAllow(anns),
+		RequestHeaders:   splitList(annotations.ExtractExtAuthzRequestHeaders(anns)),
+		UpstreamHeaders:  splitList(annotations.ExtractExtAuthzUpstreamHeaders(anns)),
+	}
+	switch protocol := annotations.ExtractExtAuthzProtocol(anns); protocol {
+	case "", "grpc":
+	case "http":
+		authz.GRPC = false
+	default:
+		p.registerTranslationFailure(fmt.Sprintf("invalid authorization protocol %q", protocol), ing)
+	}
+	if v := annotations.ExtractExtAuthzTimeout(anns); v != "" {
+		authz.Timeout = p.parseDuration(ing, annotations.ExtAuthzTimeoutKey, v)
+	}
+
+	cluster, err := p.clusterForServiceRef(ref, ing.Namespace, authz.GRPC, cache)
+	if err != nil {
+		p.closeRoute(ing, r, fmt.Errorf("authorization service: %v", err))
+		return
+	}
+	authz.Cluster = cluster.Name
+	authz.Name = extAuthzFilterName(authz)
+	r.ExtAuthz = &authz
+}
+
+// extAuthzFilterName names the filter after its configuration, so Ingresses sharing
+// an authorization service share one filter.
+func extAuthzFilterName(a resources.ExtAuthz) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s|%t|%s|%s|%t|%s|%s", a.Cluster, a.GRPC, a.PathPrefix, a.Timeout,
+		a.FailureModeAllow, strings.Join(a.RequestHeaders, ","), strings.Join(a.UpstreamHeaders, ","))
+	return fmt.Sprintf("%s%08x", extAuthzFilterPrefix, h.Sum32())
+}
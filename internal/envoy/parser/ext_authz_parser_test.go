@@ -0,0 +1,125 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApplyExtAuthz(t *testing.T) {
+	authz := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "authz"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "grpc", Port: 9000}}},
+	}
+	pathType := netv1.PathTypePrefix
+	path := func(p string) netv1.HTTPIngressPath {
+		return netv1.HTTPIngressPath{
+			Path:     p,
+			PathType: &pathType,
+			Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+				Name: "web", Port: netv1.ServiceBackendPort{Number: 80},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantGRPC    bool
+		wantTimeout time.Duration
+		wantSkipped bool
+		wantStatus  uint32
+		// reported once for each path
+		wantFailure string
+	}{
+		{
+			name:        "gRPC",
+			annotations: map[string]string{"inendless.com/ext-authz-service": "authz:grpc"},
+			wantGRPC:    true,
+		},
+		{
+			name: "HTTP",
+			annotations: map[string]string{
+				"inendless.com/ext-authz-service":  "default/authz:9000",
+				"inendless.com/ext-authz-protocol": "http",
+				"inendless.com/ext-authz-timeout":  "200ms",
+			},
+			wantTimeout: 200 * time.Millisecond,
+		},
+		{
+			name: "skipped path",
+			annotations: map[string]string{
+				"inendless.com/ext-authz-service":    "authz:grpc",
+				"inendless.com/ext-authz-skip-paths": "/healthz, /api",
+			},
+			wantGRPC:    true,
+			wantSkipped: true,
+		},
+		{
+			name: "invalid protocol",
+			annotations: map[string]string{
+				"inendless.com/ext-authz-service":  "authz:grpc",
+				"inendless.com/ext-authz-protocol": "soap",
+			},
+			wantGRPC:    true,
+			wantFailure: `invalid authorization protocol "soap"`,
+		},
+		{
+			name:        "missing service",
+			annotations: map[string]string{"inendless.com/ext-authz-service": "missing:grpc"},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "authorization service: Service default/missing not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ingress", Annotations: tt.annotations},
+				Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+					Host: "web.example.com",
+					IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{
+						Paths: []netv1.HTTPIngressPath{path("/"), path("/api")},
+					}},
+				}}},
+			}
+			p := newTestParser(t, Config{}, testService(), authz, ing)
+			cache := p.Build()
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+			} else {
+				assertFailures(t, p, tt.wantFailure, tt.wantFailure)
+			}
+
+			r := cache.Routes["default/ingress/0/0"]
+			if r.DirectResponseStatus != tt.wantStatus {
+				t.Errorf("route answers %d, want %d", r.DirectResponseStatus, tt.wantStatus)
+			}
+			if tt.wantStatus != 0 {
+				return
+			}
+			a := r.ExtAuthz
+			if a == nil {
+				t.Fatal("the route is not guarded")
+			}
+			wantCluster := "default/authz/9000"
+			if tt.wantGRPC {
+				wantCluster += "/http2"
+			}
+			if a.Cluster != wantCluster || a.GRPC != tt.wantGRPC || a.Timeout != tt.wantTimeout {
+				t.Errorf("got authorization %+v, want cluster %s, gRPC %v and timeout %v", a, wantCluster, tt.wantGRPC, tt.wantTimeout)
+			}
+			if cache.Clusters[a.Cluster].HTTP2 != tt.wantGRPC {
+				t.Errorf("authorization cluster HTTP/2 %v, want %v", cache.Clusters[a.Cluster].HTTP2, tt.wantGRPC)
+			}
+			if skipped := cache.Routes["default/ingress/0/1"].ExtAuthz == nil; skipped != tt.wantSkipped {
+				t.Errorf("/api skipped %v, want %v", skipped, tt.wantSkipped)
+			}
+			if filters := cache.Listeners["listener_0"].ExtAuthz; len(filters) != 1 || filters[0].Name != a.Name {
+				t.Errorf("got listener filters %v, want %s", filters, a.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sort"
+	"strings"
+)
+
+// tlsListenerFromGateway adds a filter chain to the HTTPS listener for the hostname of
+// every HTTPS listener of the Gateways, terminating TLS with the certificate of its
+// first Secret reference. The Gateways share the listeners of the proxies whatever
+// the port of their listeners, and hosts already served by an Ingress or another
+// Gateway are left to it.
+func (p *Parser) tlsListenerFromGateway(cache *xdscache.Cache) {
+	var chains []resources.TLSFilterChain
+	for _, gw := range p.storer.ListGateways() {
+		for _, l := range gw.Spec.Listeners {
+			if l.Protocol != gatewayv1.HTTPSProtocolType {
+				continue
+			}
+			certRef, err := listenerCertificateRef(gw, l)
+			if err != nil {
+				p.registerTranslationFailure(fmt.Sprintf("listener %s: %v", l.Name, err), gw)
+				continue
+			}
+			cert, err := p.tlsSecret(gw.Namespace, string(certRef.Name))
+			if err != nil {
+				p.registerTranslationFailure(fmt.Sprintf("listener %s: %v", l.Name, err), gw)
+				continue
+			}
+			var host string
+			if l.Hostname != nil {
+				host = string(*l.Hostname)
+			}
+			if owner, claimed := p.tlsHosts[host]; claimed {
+				if owner != gatewayKey(gw) {
+					p.registerTranslationFailure(fmt.Sprintf("listener %s: TLS host %q is already served by another Ingress or Gateway", l.Name, host), gw)
+				}
+				continue
+			}
+			p.tlsHosts[host] = gatewayKey(gw)
+			cache.Secrets[cert.Name] = *cert
+
+			chain := resources.TLSFilterChain{Certificate: cert.Name}
+			if host != "" {
+				chain.ServerNames = []string{host}
+			}
+			chains = append(chains, chain)
+		}
+	}
+	p.addTLSFilterChains(cache, chains)
+}
+
+// listenerCertificateRef returns the Secret a listener terminates TLS with.
+// Passthrough and certificates of other namespaces, which need a ReferenceGrant, are
+// not supported.
+func listenerCertificateRef(gw *gatewayv1.Gateway, l gatewayv1.Listener) (gatewayv1.SecretObjectReference, error) {
+	if l.TLS == nil || len(l.TLS.CertificateRefs) == 0 {
+		return gatewayv1.SecretObjectReference{}, fmt.Errorf("no certificate")
+	}
+	if l.TLS.Mode != nil && *l.TLS.Mode != gatewayv1.TLSModeTerminate {
+		return gatewayv1.SecretObjectReference{}, fmt.Errorf("TLS mode %s is not supported", *l.TLS.Mode)
+	}
+	ref := l.TLS.CertificateRefs[0]
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Secret") {
+		return gatewayv1.SecretObjectReference{}, fmt.Errorf("certificate %s is not a Secret", ref.Name)
+	}
+	if ref.Namespace != nil && string(*ref.Namespace) != gw.Namespace {
+		return gatewayv1.SecretObjectReference{}, fmt.Errorf("certificate %s/%s of another namespace is not supported", *ref.Namespace, ref.Name)
+	}
+	return ref, nil
+}
+
+func gatewayKey(gw *gatewayv1.Gateway) string {
+	return "Gateway/" + gw.Namespace + "/" + gw.Name
+}
+
+// httpRouteHost is a host an HTTPRoute is served for, limited to the listeners of
+// Scheme when it is only attached to the HTTP or to the HTTPS listeners of the
+// Gateways.
+type httpRouteHost struct {
+	Host   string
+	Scheme string
+}
+
+// httpRouteHosts returns the hosts an HTTPRoute is served for through the listeners
+// of the Gateways it attaches to, honouring the section name and port of its parent
+// references: the hostnames of the route that match the hostname of the listener, ""
+// standing for any host. It returns none when the route attaches to no listener.
+func (p *Parser) httpRouteHosts(hr *gatewayv1.HTTPRoute, gateways map[string]*gatewayv1.Gateway) []httpRouteHost {
+	schemes := map[string]map[string]bool{}
+	for _, ref := range hr.Spec.ParentRefs {
+		if (ref.Group != nil && *ref.Group != gatewayv1.GroupName) || (ref.Kind != nil && *ref.Kind != "Gateway") {
+			continue
+		}
+		namespace := hr.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+		gw, ok := gateways[namespace+"/"+string(ref.Name)]
+		if !ok {
+			continue
+		}
+		for _, l := range gw.Spec.Listeners {
+			if ref.SectionName != nil && *ref.SectionName != l.Name {
+				continue
+			}
+			if ref.Port != nil && *ref.Port != l.Port {
+				continue
+			}
+			var scheme string
+			switch l.Protocol {
+			case gatewayv1.HTTPProtocolType:
+				scheme = "http"
+			case gatewayv1.HTTPSProtocolType:
+				scheme = "https"
+			default:
+				continue
+			}
+			if !p.allowsHTTPRoute(gw, l, hr) {
+				continue
+			}
+			for _, host := range intersectHostnames(l.Hostname, hr.Spec.Hostnames) {
+				if schemes[host] == nil {
+					schemes[host] = map[string]bool{}
+				}
+				schemes[host][scheme] = true
+			}
+		}
+	}
+	var hosts []httpRouteHost
+	for host, s := range schemes {
+		h := httpRouteHost{Host: host}
+		switch {
+		case !s["https"]:
+			h.Scheme = "http"
+		case !s["http"]:
+			h.Scheme = "https"
+		}
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
+
+// allowsHTTPRoute reports whether a listener accepts an HTTPRoute of its namespace.
+// Namespace selectors are not supported, since the namespaces are not watched.
+func (p *Parser) allowsHTTPRoute(gw *gatewayv1.Gateway, l gatewayv1.Listener, hr *gatewayv1.HTTPRoute) bool {
+	if l.AllowedRoutes == nil {
+		return gw.Namespace == hr.Namespace
+	}
+	if len(l.AllowedRoutes.Kinds) > 0 {
+		allowed := false
+		for _, kind := range l.AllowedRoutes.Kinds {
+			if kind.Kind == "HTTPRoute" && (kind.Group == nil || *kind.Group == gatewayv1.GroupName) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	from := gatewayv1.NamespacesFromSame
+	if l.AllowedRoutes.Namespaces != nil && l.AllowedRoutes.Namespaces.From != nil {
+		from = *l.AllowedRoutes.Namespaces.From
+	}
+	switch from {
+	case gatewayv1.NamespacesFromAll:
+		return true
+	case gatewayv1.NamespacesFromSame:
+		return gw.Namespace == hr.Namespace
+	default:
+		reason := fmt.Sprintf("listener %s: namespaces %s are not supported", l.Name, from)
+		if !p.hasTranslationFailure(gw, reason) {
+			p.registerTranslationFailure(reason, gw)
+		}
+		return false
+	}
+}
+
+// intersectHostnames returns the hostnames of a route that a listener accepts. The
+// hostnames of routes attached to a listener without one are kept as they are, and
+// a wildcard hostname of one side is narrowed to the more specific hostname of the
+// other.
+func intersectHostnames(listener *gatewayv1.Hostname, route []gatewayv1.Hostname) []string {
+	if listener == nil || *listener == "" {
+		if len(route) == 0 {
+			return []string{""}
+		}
+		var hosts []string
+		for _, h := range route {
+			hosts = append(hosts, string(h))
+		}
+		return hosts
+	}
+	l := string(*listener)
+	if len(route) == 0 {
+		return []string{l}
+	}
+	var hosts []string
+	for _, h := range route {
+		switch r := string(h); {
+		case strings.EqualFold(r, l):
+			hosts = append(hosts, r)
+		case matchesWildcard(l, r):
+			hosts = append(hosts, r)
+		case matchesWildcard(r, l):
+			hosts = append(hosts, l)
+		}
+	}
+	return hosts
+}
+
+// matchesWildcard reports whether host, itself possibly a wildcard, is one of the
+// hosts of the wildcard hostname pattern.
+func matchesWildcard(pattern, host string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	suffix := strings.ToLower(pattern[1:])
+	host = strings.ToLower(host)
+	return len(host) > len(suffix) && strings.HasSuffix(host, suffix) && host != "*"+suffix
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// applyHeaderAnnotations configures the headers added, overwritten and removed on the
+// requests and responses of a route and of its virtual host.
+func (p *Parser) applyHeaderAnnotations(obj client.Object, r *resources.Route) {
+	r.RequestHeaders = p.headerPolicy(obj, annotations.RequestHeadersKey)
+	r.ResponseHeaders = p.headerPolicy(obj, annotations.ResponseHeadersKey)
+	r.VirtualHostRequestHeaders = p.headerPolicy(obj, annotations.HostRequestHeadersKey)
+	r.VirtualHostResponseHeaders = p.headerPolicy(obj, annotations.HostResponseHeadersKey)
+}
+
+// claimVirtualHostPolicies gives the route the header and CORS policies of its virtual
+// host. Each policy of a host is set by the first object setting it, in the order the
+// objects are translated; different policies set by other objects are reported and
+// ignored.
+func (p *Parser) claimVirtualHostPolicies(obj client.Object, r *resources.Route) {
+	r.VirtualHostRequestHeaders = p.claimVirtualHostPolicy(obj, r.Host, "request headers",
+		r.VirtualHostRequestHeaders, !r.VirtualHostRequestHeaders.IsEmpty()).(resources.HeaderPolicy)
+	r.VirtualHostResponseHeaders = p.claimVirtualHostPolicy(obj, r.Host, "response headers",
+		r.VirtualHostResponseHeaders, !r.VirtualHostResponseHeaders.IsEmpty()).(resources.HeaderPolicy)
+	r.VirtualHostCors = p.claimVirtualHostPolicy(obj, r.Host, "CORS policy",
+		r.VirtualHostCors, r.VirtualHostCors != nil).(*resources.CorsPolicy)
+}
+
+func (p *Parser) claimVirtualHostPolicy(obj client.Object, host, kind string, policy interface{}, set bool) interface{} {
+	if !set {
+		return policy
+	}
+	if host == "" {
+		host = "*"
+	}
+	key := kind + " of host " + host
+	owner := obj.GetNamespace() + "/" + obj.GetName()
+	c, ok := p.virtualHostPolicies[key]
+	if !ok {
+		p.virtualHostPolicies[key] = virtualHostPolicy{owner: owner, policy: policy}
+		return policy
+	}
+	if c.owner != owner && !reflect.DeepEqual(c.policy, policy) {
+		reason := fmt.Sprintf("%s of host %q ignored: %s sets different ones", kind, host, c.owner)
+		if !p.hasTranslationFailure(obj, reason) {
+			p.registerTranslationFailure(reason, obj)
+		}
+	}
+	return c.policy
+}
+
+func (p *Parser) headerPolicy(obj client.Object, key string) resources.HeaderPolicy {
+	anns := obj.GetAnnotations()
+	policy := resources.HeaderPolicy{
+		Add: p.parseHeaders(obj, key+"-add", annotations.ExtractHeadersToAdd(anns, key)),
+		Set: p.parseHeaders(obj, key+"-set", annotations.ExtractHeadersToSet(anns, key)),
+	}
+	for _, name := range splitList(annotations.ExtractHeadersToRemove(anns, key)) {
+		if !p.validHeaderName(obj, key+"-remove", name) {
+			continue
+		}
+		policy.Remove = append(policy.Remove, name)
+	}
+	return policy
+}
+
+func (p *Parser) parseHeaders(obj client.Object, key, value string) []resources.Header {
+	var headers []resources.Header
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(line, ":")
+		if !ok {
+			p.registerTranslationFailure(fmt.Sprintf("header %q in annotation %s%s is not of the form \"Name: value\"", line, annotations.AnnotationPrefix, key), obj)
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !p.validHeaderName(obj, key, name) {
+			continue
+		}
+		headers = append(headers, resources.Header{Name: name, Value: strings.TrimSpace(val)})
+	}
+	return headers
+}
+
+// validHeaderName rejects names Envoy refuses to modify, such as the Host and pseudo
+// headers, which would otherwise fail the whole route configuration.
+func (p *Parser) validHeaderName(obj client.Object, key, name string) bool {
+	if !modifiableHeader(name) {
+		p.registerTranslationFailure(fmt.Sprintf("header name %q in annotation %s%s cannot be modified", name, annotations.AnnotationPrefix, key), obj)
+		return false
+	}
+	return true
+}
+
+func modifiableHeader(name string) bool {
+	return len(validation.IsHTTPHeaderName(name)) == 0 && !strings.EqualFold(name, "host")
+}
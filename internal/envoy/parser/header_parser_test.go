@@ -0,0 +1,103 @@
+package parser
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func TestApplyHeaderAnnotations(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		want         resources.Route
+		wantFailures []string
+	}{
+		{name: "none"},
+		{
+			name: "route and host headers",
+			annotations: map[string]string{
+				"inendless.com/request-headers-add":          "X-Tenant: a\nX-Client: %DOWNSTREAM_REMOTE_ADDRESS%",
+				"inendless.com/request-headers-remove":       "X-Debug, Cookie",
+				"inendless.com/response-headers-set":         "Cache-Control: no-store",
+				"inendless.com/host-request-headers-set":     "X-Gateway: inendless",
+				"inendless.com/host-response-headers-remove": "Server",
+			},
+			want: resources.Route{
+				RequestHeaders: resources.HeaderPolicy{
+					Add:    []resources.Header{{Name: "X-Tenant", Value: "a"}, {Name: "X-Client", Value: "%DOWNSTREAM_REMOTE_ADDRESS%"}},
+					Remove: []string{"X-Debug", "Cookie"},
+				},
+				ResponseHeaders:            resources.HeaderPolicy{Set: []resources.Header{{Name: "Cache-Control", Value: "no-store"}}},
+				VirtualHostRequestHeaders:  resources.HeaderPolicy{Set: []resources.Header{{Name: "X-Gateway", Value: "inendless"}}},
+				VirtualHostResponseHeaders: resources.HeaderPolicy{Remove: []string{"Server"}},
+			},
+		},
+		{
+			name: "invalid headers",
+			annotations: map[string]string{
+				"inendless.com/request-headers-set":    "Host: example.com\nX-Valid: 1\nmissing colon",
+				"inendless.com/request-headers-remove": ":path",
+			},
+			want: resources.Route{
+				RequestHeaders: resources.HeaderPolicy{Set: []resources.Header{{Name: "X-Valid", Value: "1"}}},
+			},
+			wantFailures: []string{
+				`header name "Host" in annotation inendless.com/request-headers-set cannot be modified`,
+				`header "missing colon" in annotation inendless.com/request-headers-set is not of the form`,
+				`header name ":path" in annotation inendless.com/request-headers-remove cannot be modified`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations}}
+			p := newTestParser(t, Config{})
+			var got resources.Route
+			p.applyHeaderAnnotations(ing, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			assertFailures(t, p, tt.wantFailures...)
+		})
+	}
+}
+
+func TestVirtualHostPolicyConflicts(t *testing.T) {
+	setHeader := annotations.AnnotationPrefix + annotations.HostRequestHeadersKey + "-set"
+	enableCors := annotations.AnnotationPrefix + annotations.EnableCorsKey
+	p := newTestParser(t, Config{}, testService(),
+		testIngress("a", "example.com", map[string]string{setHeader: "X-Team: a"}),
+		testIngress("b", "example.com", map[string]string{setHeader: "X-Team: b", enableCors: "true"}),
+		// the same headers as the first Ingress are no conflict
+		testIngress("c", "example.com", map[string]string{setHeader: "X-Team: a"}),
+		testIngress("d", "example.com", nil),
+		testIngress("e", "other.example.com", map[string]string{setHeader: "X-Team: e"}),
+	)
+	cache := p.Build()
+
+	want := map[string]string{
+		"default/a/0/0": "a",
+		"default/b/0/0": "a",
+		"default/c/0/0": "a",
+		"default/d/0/0": "",
+		"default/e/0/0": "e",
+	}
+	for name, team := range want {
+		var headers []resources.Header
+		if team != "" {
+			headers = []resources.Header{{Name: "X-Team", Value: team}}
+		}
+		if got := cache.Routes[name].VirtualHostRequestHeaders.Set; !reflect.DeepEqual(got, headers) {
+			t.Errorf("route %s sets %v, want %v", name, got, headers)
+		}
+	}
+	// b is the first to enable CORS on the host
+	if cache.Routes["default/b/0/0"].VirtualHostCors == nil {
+		t.Errorf("route default/b/0/0 has no CORS policy")
+	}
+	assertFailures(t, p, `request headers of host "example.com" ignored: default/a sets different ones`)
+}
@@ -0,0 +1,343 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"net/http"
+	"regexp"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"strings"
+	"time"
+)
+
+// httpRoutesFromGateway adds a route for every match of the rules of the HTTPRoutes
+// attached to the Gateways, once per hostname they are served for, on the listeners
+// of the protocols of the Gateway listeners they attach to. Rules that
+// cannot be fully translated answer with a 500, as the Gateway API requires.
+func (p *Parser) httpRoutesFromGateway(cache *xdscache.Cache) {
+	gateways := map[string]*gatewayv1.Gateway{}
+	for _, gw := range p.storer.ListGateways() {
+		gateways[gw.Namespace+"/"+gw.Name] = gw
+	}
+	if len(gateways) == 0 {
+		return
+	}
+	for _, hr := range p.storer.ListHTTPRoutes() {
+		hosts := p.httpRouteHosts(hr, gateways)
+		if len(hosts) == 0 {
+			continue
+		}
+		for i, rule := range hr.Spec.Rules {
+			matches := rule.Matches
+			if len(matches) == 0 {
+				matches = []gatewayv1.HTTPRouteMatch{{}}
+			}
+			for j, match := range matches {
+				name := fmt.Sprintf("httproute/%s/%s/%d/%d", hr.Namespace, hr.Name, i, j)
+				r, err := httpRouteMatch(match)
+				if err != nil {
+					p.registerTranslationFailure(fmt.Sprintf("route %s: %v", name, err), hr)
+					continue
+				}
+				r.Origin = httpRouteOrigin(hr)
+				p.applyTimeoutAnnotations(hr, &r)
+				p.applyHTTPRouteTimeouts(hr, rule.Timeouts, &r)
+				if err := p.applyHTTPRouteFilters(rule.Filters, &r); err != nil {
+					p.closeHTTPRoute(hr, name, &r, err)
+				} else {
+					r = p.routeToBackendRefs(hr, name, r, rule.BackendRefs, cache)
+				}
+				for _, host := range hosts {
+					r.Host = host.Host
+					r.Scheme = host.Scheme
+					r.Name = name + "/" + host.Host
+					if host.Host == "" {
+						r.Name = name + "/*"
+					}
+					cache.Routes[r.Name] = r
+				}
+			}
+		}
+	}
+}
+
+// httpRouteMatch returns a route matching the path, method, headers and query
+// parameters of an HTTPRoute match. Prefixes match whole path segments.
+func httpRouteMatch(match gatewayv1.HTTPRouteMatch) (resources.Route, error) {
+	var r resources.Route
+	pathType, value := gatewayv1.PathMatchPathPrefix, "/"
+	if match.Path != nil {
+		if match.Path.Type != nil {
+			pathType = *match.Path.Type
+		}
+		if match.Path.Value != nil {
+			value = *match.Path.Value
+		}
+	}
+	switch pathType {
+	case gatewayv1.PathMatchExact:
+		r.Path = value
+	case gatewayv1.PathMatchPathPrefix:
+		if value == "/" {
+			r.Prefix = value
+		} else {
+			r.PathSeparatedPrefix = strings.TrimSuffix(value, "/")
+		}
+	case gatewayv1.PathMatchRegularExpression:
+		if _, err := regexp.Compile(value); err != nil {
+			return r, fmt.Errorf("invalid path regex %q: %v", value, err)
+		}
+		r.PathRegex = value
+	default:
+		return r, fmt.Errorf("path match type %s is not supported", pathType)
+	}
+
+	if match.Method != nil {
+		r.Method = string(*match.Method)
+	}
+	for _, h := range match.Headers {
+		m, err := valueMatch(string(h.Name), h.Value, h.Type == nil || *h.Type == gatewayv1.HeaderMatchExact)
+		if err != nil {
+			return r, err
+		}
+		m.Name = strings.ToLower(m.Name)
+		r.HeaderMatches = append(r.HeaderMatches, m)
+	}
+	for _, q := range match.QueryParams {
+		m, err := valueMatch(string(q.Name), q.Value, q.Type == nil || *q.Type == gatewayv1.QueryParamMatchExact)
+		if err != nil {
+			return r, err
+		}
+		r.QueryParamMatches = append(r.QueryParamMatches, m)
+	}
+	return r, nil
+}
+
+func valueMatch(name, value string, exact bool) (resources.ValueMatch, error) {
+	if exact {
+		return resources.ValueMatch{Name: name, Value: value}, nil
+	}
+	if _, err := regexp.Compile(value); err != nil {
+		return resources.ValueMatch{}, fmt.Errorf("invalid regex %q of %s: %v", value, name, err)
+	}
+	return resources.ValueMatch{Name: name, Value: value, Regex: true}, nil
+}
+
+// applyHTTPRouteTimeouts sets the timeouts of a rule, overriding the annotations of the
+// HTTPRoute. A zero timeout disables it. The backend request timeout bounds each try
+// of the retry policy, or the whole request when it is not retried.
+func (p *Parser) applyHTTPRouteTimeouts(hr *gatewayv1.HTTPRoute, timeouts *gatewayv1.HTTPRouteTimeouts, r *resources.Route) {
+	if timeouts == nil {
+		return
+	}
+	if timeouts.Request != nil {
+		r.Timeout = p.parseGatewayTimeout(hr, "request", *timeouts.Request)
+	}
+	if timeouts.BackendRequest == nil {
+		return
+	}
+	backendRequest := p.parseGatewayTimeout(hr, "backend request", *timeouts.BackendRequest)
+	switch {
+	case backendRequest == 0 || backendRequest == resources.NoTimeout:
+	case r.RetryPolicy != nil:
+		r.RetryPolicy.PerTryTimeout = backendRequest
+	case r.Timeout == 0 || r.Timeout == resources.NoTimeout || r.Timeout > backendRequest:
+		r.Timeout = backendRequest
+	}
+}
+
+func (p *Parser) parseGatewayTimeout(hr *gatewayv1.HTTPRoute, kind string, value gatewayv1.Duration) time.Duration {
+	d, err := time.ParseDuration(string(value))
+	if err != nil || d < 0 {
+		p.registerTranslationFailure(fmt.Sprintf("invalid %s timeout %q", kind, value), hr)
+		return 0
+	}
+	if d == 0 {
+		return resources.NoTimeout
+	}
+	return d
+}
+
+// applyHTTPRouteFilters applies the filters of a rule to its route. A rule with a
+// filter that is not supported is not served.
+func (p *Parser) applyHTTPRouteFilters(filters []gatewayv1.HTTPRouteFilter, r *resources.Route) error {
+	for _, f := range filters {
+		switch f.Type {
+		case gatewayv1.HTTPRouteFilterURLRewrite:
+			if f.URLRewrite == nil {
+				return fmt.Errorf("filter %s has no configuration", f.Type)
+			}
+			if err := applyURLRewrite(f.URLRewrite, r); err != nil {
+				return err
+			}
+		case gatewayv1.HTTPRouteFilterRequestHeaderModifier:
+			policy, err := httpHeaderPolicy(f.RequestHeaderModifier)
+			if err != nil {
+				return err
+			}
+			r.RequestHeaders = policy
+		case gatewayv1.HTTPRouteFilterResponseHeaderModifier:
+			policy, err := httpHeaderPolicy(f.ResponseHeaderModifier)
+			if err != nil {
+				return err
+			}
+			r.ResponseHeaders = policy
+		default:
+			return fmt.Errorf("filter %s is not supported", f.Type)
+		}
+	}
+	return nil
+}
+
+// applyURLRewrite rewrites the host and path of the requests of a route. The matched
+// prefix can only be replaced on routes matching a path prefix.
+func applyURLRewrite(rewrite *gatewayv1.HTTPURLRewriteFilter, r *resources.Route) error {
+	if rewrite.Hostname != nil {
+		r.HostRewrite = string(*rewrite.Hostname)
+	}
+	if rewrite.Path == nil {
+		return nil
+	}
+	switch rewrite.Path.Type {
+	case gatewayv1.FullPathHTTPPathModifier:
+		if rewrite.Path.ReplaceFullPath == nil {
+			return fmt.Errorf("URL rewrite has no full path")
+		}
+		r.FullPathRewrite = *rewrite.Path.ReplaceFullPath
+	case gatewayv1.PrefixMatchHTTPPathModifier:
+		if rewrite.Path.ReplacePrefixMatch == nil {
+			return fmt.Errorf("URL rewrite has no prefix")
+		}
+		if r.Prefix == "" && r.PathSeparatedPrefix == "" {
+			return fmt.Errorf("URL rewrite of the prefix of a route not matching a path prefix")
+		}
+		r.PrefixRewrite = *rewrite.Path.ReplacePrefixMatch
+	default:
+		return fmt.Errorf("URL rewrite of type %s is not supported", rewrite.Path.Type)
+	}
+	return nil
+}
+
+// httpHeaderPolicy returns the headers a header modifier filter adds, sets and
+// removes. Its values are literal, so the % of Envoy command operators is escaped.
+func httpHeaderPolicy(filter *gatewayv1.HTTPHeaderFilter) (resources.HeaderPolicy, error) {
+	var policy resources.HeaderPolicy
+	if filter == nil {
+		return policy, fmt.Errorf("header modifier has no configuration")
+	}
+	headers := func(hs []gatewayv1.HTTPHeader) ([]resources.Header, error) {
+		var headers []resources.Header
+		for _, h := range hs {
+			if !modifiableHeader(string(h.Name)) {
+				return nil, fmt.Errorf("header %s cannot be modified", h.Name)
+			}
+			headers = append(headers, resources.Header{Name: string(h.Name), Value: strings.ReplaceAll(h.Value, "%", "%%")})
+		}
+		return headers, nil
+	}
+	var err error
+	if policy.Add, err = headers(filter.Add); err != nil {
+		return policy, err
+	}
+	if policy.Set, err = headers(filter.Set); err != nil {
+		return policy, err
+	}
+	for _, name := range filter.Remove {
+		if !modifiableHeader(name) {
+			return policy, fmt.Errorf("header %s cannot be modified", name)
+		}
+		policy.Remove = append(policy.Remove, name)
+	}
+	return policy, nil
+}
+
+// routeToBackendRefs points the route at the clusters of the backends of its rule,
+// weighted by the backends when there are several. Backends that cannot be resolved
+// are reported and left out, and a rule without any backend left answers with a 500.
+func (p *Parser) routeToBackendRefs(hr *gatewayv1.HTTPRoute, name string, r resources.Route, refs []gatewayv1.HTTPBackendRef, cache *xdscache.Cache) resources.Route {
+	var clusters []resources.Cluster
+	weights := map[string]uint32{}
+	// the share of the requests going to invalid backends is answered with a 500
+	var invalidWeight uint32
+	for _, ref := range refs {
+		weight := uint32(1)
+		if ref.Weight != nil {
+			weight = uint32(*ref.Weight)
+		}
+		if weight == 0 {
+			continue
+		}
+		if len(ref.Filters) > 0 {
+			p.registerTranslationFailure(fmt.Sprintf("route %s: filters of backends are not supported", name), hr)
+			invalidWeight += weight
+			continue
+		}
+		cluster, err := p.clusterForBackendRef(hr.Namespace, ref.BackendObjectReference)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("route %s: %v", name, err), hr)
+			invalidWeight += weight
+			continue
+		}
+		cache.Clusters[cluster.Name] = cluster
+		if cluster.UpstreamTLS != nil && cluster.UpstreamTLS.ClientCertificate != nil {
+			cache.Secrets[cluster.UpstreamTLS.ClientCertificate.Name] = *cluster.UpstreamTLS.ClientCertificate
+		}
+		if _, ok := weights[cluster.Name]; !ok {
+			clusters = append(clusters, cluster)
+		}
+		weights[cluster.Name] += weight
+	}
+
+	switch {
+	case len(clusters) == 0:
+		r.DirectResponseStatus = http.StatusInternalServerError
+	case len(clusters) == 1 && invalidWeight == 0:
+		r.Cluster = clusters[0].Name
+		r.HashPolicy = clusters[0].HashPolicy
+		r.WebSocket = clusters[0].WebSocket
+	default:
+		for _, c := range clusters {
+			r.WeightedClusters = append(r.WeightedClusters, resources.WeightedCluster{Name: c.Name, Weight: weights[c.Name]})
+			r.WebSocket = r.WebSocket || c.WebSocket
+		}
+		if invalidWeight > 0 {
+			r.WeightedClusters = append(r.WeightedClusters, resources.WeightedCluster{
+				Weight:               invalidWeight,
+				DirectResponseStatus: http.StatusInternalServerError,
+			})
+		}
+	}
+	return r
+}
+
+// clusterForBackendRef returns the cluster of a Service backend of an HTTPRoute.
+// Backends of other namespaces, which need a ReferenceGrant, are not supported.
+func (p *Parser) clusterForBackendRef(namespace string, ref gatewayv1.BackendObjectReference) (resources.Cluster, error) {
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+		return resources.Cluster{}, fmt.Errorf("backend %s is not a Service", ref.Name)
+	}
+	if ref.Namespace != nil && string(*ref.Namespace) != namespace {
+		return resources.Cluster{}, fmt.Errorf("backend %s/%s of another namespace is not supported", *ref.Namespace, ref.Name)
+	}
+	if ref.Port == nil {
+		return resources.Cluster{}, fmt.Errorf("backend %s has no port", ref.Name)
+	}
+	return p.clusterForBackend(namespace, netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+		Name: string(ref.Name),
+		Port: netv1.ServiceBackendPort{Number: int32(*ref.Port)},
+	}})
+}
+
+// closeHTTPRoute answers the requests of a rule that could not be fully translated
+// with a 500.
+func (p *Parser) closeHTTPRoute(hr *gatewayv1.HTTPRoute, name string, r *resources.Route, err error) {
+	p.registerTranslationFailure(fmt.Sprintf("route %s: %v", name, err), hr)
+	r.Cluster = ""
+	r.DirectResponseStatus = http.StatusInternalServerError
+}
+
+func httpRouteOrigin(hr *gatewayv1.HTTPRoute) *resources.Origin {
+	return &resources.Origin{Kind: "HTTPRoute", Namespace: hr.Namespace, Name: hr.Name, UID: string(hr.UID)}
+}
@@ -0,0 +1,474 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/store"
+	"net/http"
+	"reflect"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"testing"
+	"time"
+)
+
+// testGateway returns the gateway Gateway of the controller, with an HTTP listener of
+// any host, and its GatewayClass.
+func testGateway(listeners ...gatewayv1.Listener) []runtime.Object {
+	if len(listeners) == 0 {
+		listeners = []gatewayv1.Listener{{Name: "http", Port: 80, Protocol: gatewayv1.HTTPProtocolType}}
+	}
+	return []runtime.Object{
+		&gatewayv1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "inendless"},
+			Spec:       gatewayv1.GatewayClassSpec{ControllerName: store.GatewayClassController},
+		},
+		&gatewayv1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gateway"},
+			Spec:       gatewayv1.GatewaySpec{GatewayClassName: "inendless", Listeners: listeners},
+		},
+	}
+}
+
+// testHTTPRoute returns an HTTPRoute of web.example.com attached to the gateway
+// Gateway, with the rules.
+func testHTTPRoute(name string, rules ...gatewayv1.HTTPRouteRule) *gatewayv1.HTTPRoute {
+	return &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: gatewayv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{{Name: "gateway"}}},
+			Hostnames:       []gatewayv1.Hostname{"web.example.com"},
+			Rules:           rules,
+		},
+	}
+}
+
+func backendRef(name string, port int32, weight *int32) gatewayv1.HTTPBackendRef {
+	p := gatewayv1.PortNumber(port)
+	return gatewayv1.HTTPBackendRef{BackendRef: gatewayv1.BackendRef{
+		BackendObjectReference: gatewayv1.BackendObjectReference{Name: gatewayv1.ObjectName(name), Port: &p},
+		Weight:                 weight,
+	}}
+}
+
+func TestHTTPRouteMatch(t *testing.T) {
+	pathType := func(t gatewayv1.PathMatchType) *gatewayv1.PathMatchType { return &t }
+	regex := gatewayv1.HeaderMatchRegularExpression
+	method := gatewayv1.HTTPMethodPost
+
+	tests := []struct {
+		name    string
+		match   gatewayv1.HTTPRouteMatch
+		want    resources.Route
+		wantErr bool
+	}{
+		{name: "default", want: resources.Route{Prefix: "/"}},
+		{
+			name:  "exact",
+			match: gatewayv1.HTTPRouteMatch{Path: &gatewayv1.HTTPPathMatch{Type: pathType(gatewayv1.PathMatchExact), Value: stringPtr("/login")}},
+			want:  resources.Route{Path: "/login"},
+		},
+		{
+			name:  "prefix",
+			match: gatewayv1.HTTPRouteMatch{Path: &gatewayv1.HTTPPathMatch{Value: stringPtr("/api/")}},
+			want:  resources.Route{PathSeparatedPrefix: "/api"},
+		},
+		{
+			name:  "regex",
+			match: gatewayv1.HTTPRouteMatch{Path: &gatewayv1.HTTPPathMatch{Type: pathType(gatewayv1.PathMatchRegularExpression), Value: stringPtr("/v[0-9]+/.*")}},
+			want:  resources.Route{PathRegex: "/v[0-9]+/.*"},
+		},
+		{
+			name:    "invalid regex",
+			match:   gatewayv1.HTTPRouteMatch{Path: &gatewayv1.HTTPPathMatch{Type: pathType(gatewayv1.PathMatchRegularExpression), Value: stringPtr("/(")}},
+			wantErr: true,
+		},
+		{
+			name: "method, headers and query parameters",
+			match: gatewayv1.HTTPRouteMatch{
+				Method: &method,
+				Headers: []gatewayv1.HTTPHeaderMatch{
+					{Name: "X-Version", Value: "2"},
+					{Name: "X-Tenant", Value: "a|b", Type: &regex},
+				},
+				QueryParams: []gatewayv1.HTTPQueryParamMatch{{Name: "debug", Value: "1"}},
+			},
+			want: resources.Route{
+				Prefix: "/",
+				Method: "POST",
+				HeaderMatches: []resources.ValueMatch{
+					{Name: "x-version", Value: "2"},
+					{Name: "x-tenant", Value: "a|b", Regex: true},
+				},
+				QueryParamMatches: []resources.ValueMatch{{Name: "debug", Value: "1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := httpRouteMatch(tt.match)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectHostnames(t *testing.T) {
+	hostname := func(h string) *gatewayv1.Hostname {
+		hn := gatewayv1.Hostname(h)
+		return &hn
+	}
+	tests := []struct {
+		name     string
+		listener *gatewayv1.Hostname
+		route    []gatewayv1.Hostname
+		want     []string
+	}{
+		{name: "any host", want: []string{""}},
+		{name: "route hostnames", route: []gatewayv1.Hostname{"a.example.com", "b.example.com"}, want: []string{"a.example.com", "b.example.com"}},
+		{name: "listener hostname", listener: hostname("a.example.com"), want: []string{"a.example.com"}},
+		{name: "same", listener: hostname("a.example.com"), route: []gatewayv1.Hostname{"A.example.com"}, want: []string{"A.example.com"}},
+		{name: "other", listener: hostname("a.example.com"), route: []gatewayv1.Hostname{"b.example.com"}},
+		{name: "listener wildcard", listener: hostname("*.example.com"), route: []gatewayv1.Hostname{"a.example.com", "example.com", "a.example.org"}, want: []string{"a.example.com"}},
+		{name: "route wildcard", listener: hostname("a.example.com"), route: []gatewayv1.Hostname{"*.example.com"}, want: []string{"a.example.com"}},
+		{name: "narrower wildcard", listener: hostname("*.example.com"), route: []gatewayv1.Hostname{"*.a.example.com"}, want: []string{"*.a.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersectHostnames(tt.listener, tt.route); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPRoutesFromGateway(t *testing.T) {
+	web := testService()
+	api := testService()
+	api.Name = "api"
+	otherClass := &gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+		Spec: gatewayv1.GatewaySpec{GatewayClassName: "other", Listeners: []gatewayv1.Listener{
+			{Name: "http", Port: 80, Protocol: gatewayv1.HTTPProtocolType},
+		}},
+	}
+	other := testHTTPRoute("other", gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)}})
+	other.Spec.ParentRefs[0].Name = "other"
+	otherNamespace := testHTTPRoute("other-namespace", gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)}})
+	otherNamespace.Namespace = "team"
+
+	request, backendRequest, disabled := gatewayv1.Duration("10s"), gatewayv1.Duration("2s"), gatewayv1.Duration("0s")
+	retried := testHTTPRoute("retried", gatewayv1.HTTPRouteRule{
+		BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)},
+		Timeouts:    &gatewayv1.HTTPRouteTimeouts{Request: &request, BackendRequest: &backendRequest},
+	})
+	retried.Annotations = map[string]string{"inendless.com/num-retries": "3"}
+
+	objects := append(testGateway(), web, api, otherClass, other, otherNamespace, retried,
+		testHTTPRoute("split", gatewayv1.HTTPRouteRule{
+			BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, int32Ptr(90)), backendRef("api", 80, int32Ptr(10)), backendRef("web", 80, int32Ptr(0))},
+		}),
+		testHTTPRoute("timeouts",
+			gatewayv1.HTTPRouteRule{
+				BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)},
+				Timeouts:    &gatewayv1.HTTPRouteTimeouts{Request: &request, BackendRequest: &backendRequest},
+			},
+			gatewayv1.HTTPRouteRule{
+				BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)},
+				Timeouts:    &gatewayv1.HTTPRouteTimeouts{Request: &disabled},
+			},
+		),
+		testHTTPRoute("partly-broken", gatewayv1.HTTPRouteRule{
+			BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, int32Ptr(3)), backendRef("missing", 80, int32Ptr(1))},
+		}),
+		testHTTPRoute("broken",
+			gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("missing", 80, nil)}},
+			gatewayv1.HTTPRouteRule{
+				Filters:     []gatewayv1.HTTPRouteFilter{{Type: gatewayv1.HTTPRouteFilterRequestMirror}},
+				BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)},
+			},
+		),
+	)
+	p := newTestParser(t, Config{}, objects...)
+	cache := p.Build()
+
+	for _, name := range []string{"httproute/default/other/0/0/web.example.com", "httproute/team/other-namespace/0/0/web.example.com"} {
+		if _, ok := cache.Routes[name]; ok {
+			t.Errorf("route %s of a route not attached to the gateway", name)
+		}
+	}
+
+	split := cache.Routes["httproute/default/split/0/0/web.example.com"]
+	wantSplit := []resources.WeightedCluster{{Name: "default/web/80", Weight: 90}, {Name: "default/api/80", Weight: 10}}
+	if split.Host != "web.example.com" || split.Prefix != "/" || !reflect.DeepEqual(split.WeightedClusters, wantSplit) {
+		t.Errorf("split route %+v, want weighted clusters %v", split, wantSplit)
+	}
+	if split.Origin == nil || split.Origin.Kind != "HTTPRoute" {
+		t.Errorf("split route origin %+v", split.Origin)
+	}
+
+	partlyBroken := cache.Routes["httproute/default/partly-broken/0/0/web.example.com"]
+	wantPartlyBroken := []resources.WeightedCluster{{Name: "default/web/80", Weight: 3}, {Weight: 1, DirectResponseStatus: http.StatusInternalServerError}}
+	if !reflect.DeepEqual(partlyBroken.WeightedClusters, wantPartlyBroken) {
+		t.Errorf("partly broken route splits to %v, want %v", partlyBroken.WeightedClusters, wantPartlyBroken)
+	}
+	if l := cache.Listeners["listener_0"]; !l.WeightedDirectResponses {
+		t.Errorf("listener %+v does not answer the share of invalid backends", l)
+	}
+
+	timeouts := []struct {
+		route         string
+		timeout       time.Duration
+		perTryTimeout time.Duration
+	}{
+		{route: "httproute/default/timeouts/0/0/web.example.com", timeout: 2 * time.Second},
+		{route: "httproute/default/timeouts/1/0/web.example.com", timeout: resources.NoTimeout},
+		{route: "httproute/default/retried/0/0/web.example.com", timeout: 10 * time.Second, perTryTimeout: 2 * time.Second},
+	}
+	for _, tt := range timeouts {
+		r := cache.Routes[tt.route]
+		if r.Cluster != "default/web/80" {
+			t.Errorf("route %s targets %q", tt.route, r.Cluster)
+		}
+		if r.Timeout != tt.timeout {
+			t.Errorf("route %s timeout %v, want %v", tt.route, r.Timeout, tt.timeout)
+		}
+		var perTryTimeout time.Duration
+		if r.RetryPolicy != nil {
+			perTryTimeout = r.RetryPolicy.PerTryTimeout
+		}
+		if perTryTimeout != tt.perTryTimeout {
+			t.Errorf("route %s per try timeout %v, want %v", tt.route, perTryTimeout, tt.perTryTimeout)
+		}
+	}
+
+	for _, name := range []string{"httproute/default/broken/0/0/web.example.com", "httproute/default/broken/1/0/web.example.com"} {
+		if r := cache.Routes[name]; r.DirectResponseStatus != http.StatusInternalServerError || r.Cluster != "" {
+			t.Errorf("route %s answers %d through %q, want a 500", name, r.DirectResponseStatus, r.Cluster)
+		}
+	}
+	assertFailures(t, p, "Service default/missing not found", "filter RequestMirror is not supported", "Service default/missing not found")
+}
+
+func TestTLSListenerFromGateway(t *testing.T) {
+	hostname := gatewayv1.Hostname("web.example.com")
+	https := gatewayv1.Listener{
+		Name:     "https",
+		Port:     443,
+		Protocol: gatewayv1.HTTPSProtocolType,
+		Hostname: &hostname,
+		TLS: &gatewayv1.GatewayTLSConfig{CertificateRefs: []gatewayv1.SecretObjectReference{
+			{Name: "cert"},
+		}},
+	}
+	missing := https
+	missing.Name = "missing"
+	missing.Hostname = nil
+	missing.TLS = &gatewayv1.GatewayTLSConfig{CertificateRefs: []gatewayv1.SecretObjectReference{{Name: "missing"}}}
+	cert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cert"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("CERT"), corev1.TLSPrivateKeyKey: []byte("KEY")},
+	}
+	objects := append(testGateway(https, missing), cert, testService(),
+		testHTTPRoute("web", gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)}}))
+	p := newTestParser(t, Config{}, objects...)
+	cache := p.Build()
+
+	l, ok := cache.Listeners["listener_https"]
+	if !ok {
+		t.Fatal("no HTTPS listener")
+	}
+	want := []resources.TLSFilterChain{{ServerNames: []string{"web.example.com"}, Certificate: "default.cert"}}
+	if !reflect.DeepEqual(l.TLSFilterChains, want) {
+		t.Errorf("got chains %+v, want %+v", l.TLSFilterChains, want)
+	}
+	if _, ok := cache.Routes["httproute/default/web/0/0/web.example.com"]; !ok {
+		t.Error("no route of the HTTPRoute")
+	}
+	assertFailures(t, p, "listener missing: Secret default/missing not found")
+}
+
+func TestHTTPRouteListeners(t *testing.T) {
+	http := gatewayv1.Listener{Name: "http", Port: 80, Protocol: gatewayv1.HTTPProtocolType}
+	https := gatewayv1.Listener{
+		Name:     "https",
+		Port:     443,
+		Protocol: gatewayv1.HTTPSProtocolType,
+		TLS:      &gatewayv1.GatewayTLSConfig{CertificateRefs: []gatewayv1.SecretObjectReference{{Name: "cert"}}},
+	}
+	cert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cert"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("CERT"), corev1.TLSPrivateKeyKey: []byte("KEY")},
+	}
+	rule := gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)}}
+	attached := func(name string, sectionName string, port gatewayv1.PortNumber) *gatewayv1.HTTPRoute {
+		hr := testHTTPRoute(name, rule)
+		if sectionName != "" {
+			section := gatewayv1.SectionName(sectionName)
+			hr.Spec.ParentRefs[0].SectionName = &section
+		}
+		if port != 0 {
+			hr.Spec.ParentRefs[0].Port = &port
+		}
+		return hr
+	}
+	objects := append(testGateway(http, https), cert, testService(),
+		attached("both", "", 0),
+		attached("secure", "https", 0),
+		attached("plain", "", 80),
+		attached("nowhere", "http", 443),
+	)
+	p := newTestParser(t, Config{}, objects...)
+	cache := p.Build()
+
+	want := map[string]string{
+		"httproute/default/both/0/0/web.example.com":   "",
+		"httproute/default/secure/0/0/web.example.com": "https",
+		"httproute/default/plain/0/0/web.example.com":  "http",
+	}
+	for name, scheme := range want {
+		r, ok := cache.Routes[name]
+		if !ok {
+			t.Errorf("no route %s", name)
+			continue
+		}
+		if r.Scheme != scheme {
+			t.Errorf("route %s is served on scheme %q, want %q", name, r.Scheme, scheme)
+		}
+	}
+	if _, ok := cache.Routes["httproute/default/nowhere/0/0/web.example.com"]; ok {
+		t.Error("route of an HTTPRoute attached to no listener")
+	}
+	assertFailures(t, p)
+}
+
+func TestApplyURLRewrite(t *testing.T) {
+	hostname := gatewayv1.PreciseHostname("backend.internal")
+	tests := []struct {
+		name    string
+		route   resources.Route
+		rewrite gatewayv1.HTTPURLRewriteFilter
+		want    resources.Route
+		wantErr bool
+	}{
+		{
+			name:    "hostname",
+			route:   resources.Route{Prefix: "/"},
+			rewrite: gatewayv1.HTTPURLRewriteFilter{Hostname: &hostname},
+			want:    resources.Route{Prefix: "/", HostRewrite: "backend.internal"},
+		},
+		{
+			name:  "full path",
+			route: resources.Route{Path: "/login"},
+			rewrite: gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{
+				Type: gatewayv1.FullPathHTTPPathModifier, ReplaceFullPath: stringPtr("/auth/login"),
+			}},
+			want: resources.Route{Path: "/login", FullPathRewrite: "/auth/login"},
+		},
+		{
+			name:  "prefix",
+			route: resources.Route{PathSeparatedPrefix: "/api"},
+			rewrite: gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{
+				Type: gatewayv1.PrefixMatchHTTPPathModifier, ReplacePrefixMatch: stringPtr("/"),
+			}},
+			want: resources.Route{PathSeparatedPrefix: "/api", PrefixRewrite: "/"},
+		},
+		{
+			name:  "prefix of an exact path",
+			route: resources.Route{Path: "/login"},
+			rewrite: gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{
+				Type: gatewayv1.PrefixMatchHTTPPathModifier, ReplacePrefixMatch: stringPtr("/"),
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "no full path",
+			route:   resources.Route{Prefix: "/"},
+			rewrite: gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{Type: gatewayv1.FullPathHTTPPathModifier}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.route
+			err := applyURLRewrite(&tt.rewrite, &r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(r, tt.want) {
+				t.Errorf("got %+v, want %+v", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPHeaderPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *gatewayv1.HTTPHeaderFilter
+		want    resources.HeaderPolicy
+		wantErr bool
+	}{
+		{
+			name: "add, set and remove",
+			filter: &gatewayv1.HTTPHeaderFilter{
+				Add:    []gatewayv1.HTTPHeader{{Name: "X-Tenant", Value: "a"}},
+				Set:    []gatewayv1.HTTPHeader{{Name: "X-Discount", Value: "10%"}},
+				Remove: []string{"X-Debug"},
+			},
+			want: resources.HeaderPolicy{
+				Add:    []resources.Header{{Name: "X-Tenant", Value: "a"}},
+				Set:    []resources.Header{{Name: "X-Discount", Value: "10%%"}},
+				Remove: []string{"X-Debug"},
+			},
+		},
+		{name: "host", filter: &gatewayv1.HTTPHeaderFilter{Set: []gatewayv1.HTTPHeader{{Name: "Host", Value: "a"}}}, wantErr: true},
+		{name: "invalid name", filter: &gatewayv1.HTTPHeaderFilter{Remove: []string{"X Debug"}}, wantErr: true},
+		{name: "no configuration", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := httpHeaderPolicy(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPRouteHeaderFilters(t *testing.T) {
+	objects := append(testGateway(), testService(), testHTTPRoute("web", gatewayv1.HTTPRouteRule{
+		Filters: []gatewayv1.HTTPRouteFilter{
+			{
+				Type:                  gatewayv1.HTTPRouteFilterRequestHeaderModifier,
+				RequestHeaderModifier: &gatewayv1.HTTPHeaderFilter{Set: []gatewayv1.HTTPHeader{{Name: "X-Gateway", Value: "inendless"}}},
+			},
+			{
+				Type:                   gatewayv1.HTTPRouteFilterResponseHeaderModifier,
+				ResponseHeaderModifier: &gatewayv1.HTTPHeaderFilter{Remove: []string{"Server"}},
+			},
+		},
+		BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web", 80, nil)},
+	}))
+	p := newTestParser(t, Config{}, objects...)
+	r := p.Build().Routes["httproute/default/web/0/0/web.example.com"]
+
+	if want := (resources.HeaderPolicy{Set: []resources.Header{{Name: "X-Gateway", Value: "inendless"}}}); !reflect.DeepEqual(r.RequestHeaders, want) {
+		t.Errorf("got request headers %+v, want %+v", r.RequestHeaders, want)
+	}
+	if want := (resources.HeaderPolicy{Remove: []string{"Server"}}); !reflect.DeepEqual(r.ResponseHeaders, want) {
+		t.Errorf("got response headers %+v, want %+v", r.ResponseHeaders, want)
+	}
+	if r.Cluster != "default/web/80" {
+		t.Errorf("route targets %q", r.Cluster)
+	}
+	assertFailures(t, p)
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"net"
+	"strings"
+)
+
+// applyIPFilter restricts the route to the source ranges of the Ingress. A list with
+// an invalid entry closes the route with a 503, since dropping the entry could
+// silently widen or narrow who gets through.
+func (p *Parser) applyIPFilter(ing *netv1.Ingress, r *resources.Route) {
+	anns := ing.GetAnnotations()
+	allow, err := parseCIDRs(annotations.ExtractAllowlistSourceRange(anns))
+	if err != nil {
+		p.closeRoute(ing, r, fmt.Errorf("%s: %v", annotations.AllowlistSourceRangeKey, err))
+		return
+	}
+	deny, err := parseCIDRs(annotations.ExtractDenylistSourceRange(anns))
+	if err != nil {
+		p.closeRoute(ing, r, fmt.Errorf("%s: %v", annotations.DenylistSourceRangeKey, err))
+		return
+	}
+	if len(allow)+len(deny) == 0 {
+		return
+	}
+	r.IPFilter = &resources.IPFilter{Allow: allow, Deny: deny}
+}
+
+// parseCIDRs parses a comma separated list of CIDRs, taking plain addresses as
+// single host ranges.
+func parseCIDRs(value string) ([]resources.CIDR, error) {
+	var cidrs []resources.CIDR
+	for _, entry := range splitList(value) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+			}
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				bits = net.IPv4len * 8
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		ones, _ := ipNet.Mask.Size()
+		cidrs = append(cidrs, resources.CIDR{Address: ipNet.IP.String(), PrefixLen: uint32(ones)})
+	}
+	return cidrs, nil
+}
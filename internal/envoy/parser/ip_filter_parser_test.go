@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func TestApplyIPFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *resources.IPFilter
+	}{
+		{name: "none"},
+		{
+			name:        "allowlist",
+			annotations: map[string]string{"inendless.com/allowlist-source-range": "10.0.0.0/8, 192.168.0.1"},
+			want: &resources.IPFilter{Allow: []resources.CIDR{
+				{Address: "10.0.0.0", PrefixLen: 8},
+				{Address: "192.168.0.1", PrefixLen: 32},
+			}},
+		},
+		{
+			name: "allowlist and denylist",
+			annotations: map[string]string{
+				"inendless.com/allowlist-source-range": "10.0.0.0/8",
+				"inendless.com/denylist-source-range":  "10.0.0.1",
+			},
+			want: &resources.IPFilter{
+				Allow: []resources.CIDR{{Address: "10.0.0.0", PrefixLen: 8}},
+				Deny:  []resources.CIDR{{Address: "10.0.0.1", PrefixLen: 32}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testIngress("ingress", "web.example.com", tt.annotations)
+			p := newTestParser(t, Config{XFFNumTrustedHops: 2}, testService(), ing)
+			cache := p.Build()
+			assertFailures(t, p)
+			if got := cache.Routes["default/ingress/0/0"].IPFilter; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got IP filter %+v, want %+v", got, tt.want)
+			}
+			l := cache.Listeners["listener_0"]
+			if l.IPFiltering != (tt.want != nil) {
+				t.Errorf("listener IP filtering %v, want %v", l.IPFiltering, tt.want != nil)
+			}
+			if l.XFFNumTrustedHops != 2 {
+				t.Errorf("got %d trusted hops, want 2", l.XFFNumTrustedHops)
+			}
+		})
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []resources.CIDR
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "10.0.0.0/8", want: []resources.CIDR{{Address: "10.0.0.0", PrefixLen: 8}}},
+		{value: "10.1.2.3/8", want: []resources.CIDR{{Address: "10.0.0.0", PrefixLen: 8}}},
+		{value: "192.168.0.1", want: []resources.CIDR{{Address: "192.168.0.1", PrefixLen: 32}}},
+		{value: "fd00::1", want: []resources.CIDR{{Address: "fd00::1", PrefixLen: 128}}},
+		{
+			value: "10.0.0.0/8, fd00::/64 ,192.168.0.1",
+			want: []resources.CIDR{
+				{Address: "10.0.0.0", PrefixLen: 8},
+				{Address: "fd00::", PrefixLen: 64},
+				{Address: "192.168.0.1", PrefixLen: 32},
+			},
+		},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "example.com", wantErr: true},
+		{value: "10.0.0.0/8,10.0.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCIDRs(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
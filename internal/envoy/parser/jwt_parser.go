@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	jwksKey = "jwks"
+	// systemCAFile verifies the upstream servers, such as remote JWKS and Services
+	// speaking TLS, that have no CA of their own. It is the CA bundle of the Envoy
+	// image, which must ship one at that path.
+	systemCAFile = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// applyJWT requires a token of the issuer of the Ingress on the route, unless path is
+// one of its skipped paths, and copies claims of the token to request headers. Routes
+// whose JWKS cannot be loaded are closed with a 503 rather than left unprotected.
+func (p *Parser) applyJWT(ing *netv1.Ingress, r *resources.Route, path string, cache *xdscache.Cache) {
+	anns := ing.GetAnnotations()
+	issuer := annotations.ExtractJWTIssuer(anns)
+	if issuer == "" {
+		return
+	}
+	for _, skip := range splitList(annotations.ExtractJWTSkipPaths(anns)) {
+		if skip == path {
+			return
+		}
+	}
+
+	provider, err := p.jwtProvider(ing, cache)
+	if err != nil {
+		p.closeRoute(ing, r, fmt.Errorf("jwt: %v", err))
+		return
+	}
+	r.JWT = provider
+
+	for _, entry := range splitList(annotations.ExtractJWTClaimHeaders(anns)) {
+		claim, header, _ := strings.Cut(entry, ":")
+		if claim == "" || !p.validHeaderName(ing, annotations.JWTClaimHeadersKey, header) {
+			continue
+		}
+		// Clients must not be able to supply the header when the claim is missing.
+		r.RequestHeaders.Remove = append(r.RequestHeaders.Remove, header)
+		r.RequestHeaders.Set = append(r.RequestHeaders.Set, resources.Header{
+			Name:  header,
+			Value: resources.JWTClaimHeaderValue(claim),
+		})
+	}
+}
+
+func (p *Parser) jwtProvider(ing *netv1.Ingress, cache *xdscache.Cache) (*resources.JWTProvider, error) {
+	anns := ing.GetAnnotations()
+	provider := &resources.JWTProvider{
+		Name:      fmt.Sprintf("%s.%s", ing.Namespace, ing.Name),
+		Issuer:    annotations.ExtractJWTIssuer(anns),
+		Audiences: splitList(annotations.ExtractJWTAudiences(anns)),
+		Forward:   annotations.ExtractJWTForward(anns),
+	}
+
+	if uri := annotations.ExtractJWTJWKSURI(anns); uri != "" {
+		cluster, err := p.clusterForURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		cache.Clusters[cluster.Name] = cluster
+		provider.RemoteJWKS = &resources.RemoteJWKS{URI: uri, Cluster: cluster.Name}
+		if v := annotations.ExtractJWTJWKSCacheDuration(anns); v != "" {
+			provider.RemoteJWKS.CacheDuration = p.parseDuration(ing, annotations.JWTJWKSCacheDurationKey, v)
+		}
+		return provider, nil
+	}
+
+	name := annotations.ExtractJWTJWKSSecret(anns)
+	if name == "" {
+		return nil, fmt.Errorf("neither a JWKS URI nor a JWKS Secret is set")
+	}
+	secret, err := p.storer.GetSecret(ing.Namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if provider.LocalJWKS = string(secret.Data[jwksKey]); provider.LocalJWKS == "" {
+		return nil, fmt.Errorf("Secret %s/%s has no %s", ing.Namespace, name, jwksKey)
+	}
+	return provider, nil
+}
+
+// clusterForURI builds a DNS cluster for the host of an http or https URI, verifying
+// https servers against the system CAs.
+func (p *Parser) clusterForURI(uri string) (resources.Cluster, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	port := 80
+	switch u.Scheme {
+	case "http":
+	case "https":
+		port = 443
+	default:
+		return resources.Cluster{}, fmt.Errorf("%q is not an http or https URI", uri)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return resources.Cluster{}, fmt.Errorf("%q has no host", uri)
+	}
+	if v := u.Port(); v != "" {
+		if port, err = strconv.Atoi(v); err != nil {
+			return resources.Cluster{}, fmt.Errorf("%q has an invalid port", uri)
+		}
+	}
+
+	cluster := resources.Cluster{
+		Name: fmt.Sprintf("uri.%s", net.JoinHostPort(host, strconv.Itoa(port))),
+		Type: resources.StrictDNSCluster,
+		Endpoints: []resources.Endpoint{{
+			UpstreamHost: host,
+			UpstreamPort: uint32(port),
+		}},
+		DNSLookupFamily: p.cfg.DNSLookupFamily,
+	}
+	if u.Scheme == "https" {
+		cluster.UpstreamTLS = &resources.UpstreamTLS{SNI: host, CAFile: systemCAFile}
+	}
+	return cluster, nil
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyJWT(t *testing.T) {
+	jwks := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "jwks"},
+		Data:       map[string][]byte{"jwks": []byte(`{"keys":[]}`)},
+	}
+	empty := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "empty"}}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantProvider *resources.JWTProvider
+		wantCluster  *resources.Cluster
+		wantHeaders  resources.HeaderPolicy
+		wantStatus   uint32
+		wantFailure  string
+	}{
+		{name: "none"},
+		{
+			name: "remote JWKS",
+			annotations: map[string]string{
+				"inendless.com/jwt-issuer":              "https://issuer.example.com",
+				"inendless.com/jwt-audiences":           "api, web",
+				"inendless.com/jwt-jwks-uri":            "https://issuer.example.com/.well-known/jwks.json",
+				"inendless.com/jwt-jwks-cache-duration": "10m",
+				"inendless.com/jwt-forward":             "true",
+			},
+			wantProvider: &resources.JWTProvider{
+				Name:      "default.ingress",
+				Issuer:    "https://issuer.example.com",
+				Audiences: []string{"api", "web"},
+				Forward:   true,
+				RemoteJWKS: &resources.RemoteJWKS{
+					URI:           "https://issuer.example.com/.well-known/jwks.json",
+					Cluster:       "uri.issuer.example.com:443",
+					CacheDuration: 10 * time.Minute,
+				},
+			},
+			wantCluster: &resources.Cluster{
+				Name:        "uri.issuer.example.com:443",
+				Type:        resources.StrictDNSCluster,
+				Endpoints:   []resources.Endpoint{{UpstreamHost: "issuer.example.com", UpstreamPort: 443}},
+				UpstreamTLS: &resources.UpstreamTLS{SNI: "issuer.example.com", CAFile: systemCAFile},
+			},
+		},
+		{
+			name: "local JWKS with claim headers",
+			annotations: map[string]string{
+				"inendless.com/jwt-issuer":        "issuer",
+				"inendless.com/jwt-jwks-secret":   "jwks",
+				"inendless.com/jwt-claim-headers": "sub:X-User, email:X-Email",
+			},
+			wantProvider: &resources.JWTProvider{Name: "default.ingress", Issuer: "issuer", LocalJWKS: `{"keys":[]}`},
+			wantHeaders: resources.HeaderPolicy{
+				Set: []resources.Header{
+					{Name: "X-User", Value: resources.JWTClaimHeaderValue("sub")},
+					{Name: "X-Email", Value: resources.JWTClaimHeaderValue("email")},
+				},
+				Remove: []string{"X-User", "X-Email"},
+			},
+		},
+		{
+			name: "skipped path",
+			annotations: map[string]string{
+				"inendless.com/jwt-issuer":      "issuer",
+				"inendless.com/jwt-jwks-secret": "jwks",
+				"inendless.com/jwt-skip-paths":  "/",
+			},
+		},
+		{
+			name:        "no JWKS",
+			annotations: map[string]string{"inendless.com/jwt-issuer": "issuer"},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "neither a JWKS URI nor a JWKS Secret is set",
+		},
+		{
+			name: "JWKS Secret without a JWKS",
+			annotations: map[string]string{
+				"inendless.com/jwt-issuer":      "issuer",
+				"inendless.com/jwt-jwks-secret": "empty",
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "Secret default/empty has no jwks",
+		},
+		{
+			name: "JWKS URI of another scheme",
+			annotations: map[string]string{
+				"inendless.com/jwt-issuer":   "issuer",
+				"inendless.com/jwt-jwks-uri": "ftp://issuer.example.com/jwks",
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: "is not an http or https URI",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testIngress("ingress", "web.example.com", tt.annotations)
+			p := newTestParser(t, Config{}, testService(), jwks, empty, ing)
+			cache := p.Build()
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+			} else {
+				assertFailures(t, p, tt.wantFailure)
+			}
+
+			r := cache.Routes["default/ingress/0/0"]
+			if r.DirectResponseStatus != tt.wantStatus {
+				t.Errorf("route answers %d, want %d", r.DirectResponseStatus, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(r.JWT, tt.wantProvider) {
+				t.Errorf("got provider %+v, want %+v", r.JWT, tt.wantProvider)
+			}
+			if !reflect.DeepEqual(r.RequestHeaders, tt.wantHeaders) {
+				t.Errorf("got request headers %+v, want %+v", r.RequestHeaders, tt.wantHeaders)
+			}
+			if tt.wantCluster != nil {
+				if got := cache.Clusters[tt.wantCluster.Name]; !reflect.DeepEqual(got, *tt.wantCluster) {
+					t.Errorf("got cluster %+v, want %+v", got, *tt.wantCluster)
+				}
+			}
+			var providers []resources.JWTProvider
+			if tt.wantProvider != nil {
+				providers = []resources.JWTProvider{*tt.wantProvider}
+			}
+			if got := cache.Listeners["listener_0"].JWTProviders; !reflect.DeepEqual(got, providers) {
+				t.Errorf("got listener providers %+v, want %+v", got, providers)
+			}
+		})
+	}
+}
+
+func TestClusterForURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		wantName string
+		wantPort uint32
+		wantTLS  bool
+		wantErr  bool
+	}{
+		{uri: "http://jwks.internal/keys", wantName: "uri.jwks.internal:80", wantPort: 80},
+		{uri: "https://issuer.example.com/keys", wantName: "uri.issuer.example.com:443", wantPort: 443, wantTLS: true},
+		{uri: "https://issuer.example.com:8443/keys", wantName: "uri.issuer.example.com:8443", wantPort: 8443, wantTLS: true},
+		{uri: "http://[fd00::1]:8080/keys", wantName: "uri.[fd00::1]:8080", wantPort: 8080},
+		{uri: "file:///etc/jwks.json", wantErr: true},
+		{uri: "https:///keys", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			p := newTestParser(t, Config{})
+			got, err := p.clusterForURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Name != tt.wantName || got.Endpoints[0].UpstreamPort != tt.wantPort {
+				t.Errorf("got cluster %s of port %d, want %s of port %d", got.Name, got.Endpoints[0].UpstreamPort, tt.wantName, tt.wantPort)
+			}
+			if (got.UpstreamTLS != nil) != tt.wantTLS {
+				t.Errorf("got upstream TLS %+v, want TLS %v", got.UpstreamTLS, tt.wantTLS)
+			}
+		})
+	}
+}
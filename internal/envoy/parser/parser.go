@@ -1,15 +1,131 @@
 package parser
 
 import (
+	"kubernetes-controller/internal/envoy/xdscache"
 	"kubernetes-controller/internal/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Parser struct {
-	storer store.Storer
+	storer   store.Storer
+	cfg      Config
+	failures []TranslationFailure
+	// tlsHosts maps the hosts of the built TLS filter chains, "" for the chain
+	// without server names, to the namespace/name of their Ingress, or to the
+	// Gateway/namespace/name of their Gateway.
+	tlsHosts map[string]string
+	// virtualHostPolicies are the policies of the virtual hosts, by kind and host,
+	// and the namespace/name of the object that set them.
+	virtualHostPolicies map[string]virtualHostPolicy
 }
 
-func NewParser(storer store.Storer) *Parser {
+type virtualHostPolicy struct {
+	owner  string
+	policy interface{}
+}
+
+// Config holds the settings that apply to all generated resources.
+type Config struct {
+	// IngressClass is the class of the controller, whose annotations override the
+	// access log settings.
+	IngressClass string
+
+	ListenAddress   string
+	HTTPPort        uint32
+	HTTPSPort       uint32
+	DNSLookupFamily string
+	// ClusterDomain is the DNS domain of the Services, cluster.local when empty.
+	ClusterDomain string
+	// ProxyProtocol is set when Envoy is behind a load balancer sending PROXY protocol.
+	ProxyProtocol bool
+	// UseRemoteAddress trusts the peer address of connections over X-Forwarded-For.
+	UseRemoteAddress bool
+	// XFFNumTrustedHops is the number of proxies, such as cloud load balancers, in
+	// front of Envoy that append to X-Forwarded-For.
+	XFFNumTrustedHops uint32
+
+	// TracingProvider is opentelemetry or zipkin, with the collector at the
+	// namespace/name:port of TracingService. Tracing is off without a provider.
+	TracingProvider string
+	TracingService  string
+	// TracingSampling is the percentage of requests traced.
+	TracingSampling float64
+	// TracingTags are name=value or name=header:<header> entries.
+	TracingTags               []string
+	GenerateRequestID         bool
+	PreserveExternalRequestID bool
+
+	// Compression lists the algorithms, gzip or brotli, responses are compressed
+	// with unless the route disables compression.
+	Compression []string
+
+	// AccessLogPath is the file access logs are written to, such as /dev/stdout.
+	AccessLogPath string
+	// AccessLogFormatType is text or json; AccessLogFormat replaces the default
+	// format of that type.
+	AccessLogFormatType string
+	AccessLogFormat     string
+	// AccessLogService is the namespace/name:port of a gRPC access log service.
+	AccessLogService string
+
+	// RateLimitService is the namespace/name:port of an Envoy RLS gRPC service.
+	RateLimitService         string
+	RateLimitDomain          string
+	RateLimitFailureModeDeny bool
+
+	// ProxyService is the namespace/name of the Service of the Envoy proxies, whose
+	// endpoints make up the local cluster of zone-aware routing.
+	ProxyService string
+}
+
+// TranslationFailure records why an object could not be fully translated into
+// Envoy configuration. Object is nil for failures of the parser Config.
+type TranslationFailure struct {
+	Object client.Object
+	Reason string
+}
+
+func NewParser(storer store.Storer, cfg Config) *Parser {
 	return &Parser{
 		storer: storer,
+		cfg:    cfg,
+	}
+}
+
+// Build translates the objects in the store into the resources served to Envoy.
+func (p *Parser) Build() *xdscache.Cache {
+	p.failures = nil
+	p.tlsHosts = map[string]string{}
+	p.virtualHostPolicies = map[string]virtualHostPolicy{}
+	cache := xdscache.NewCache()
+	cache.AddListener("listener_0", []string{"listener_0"}, p.cfg.ListenAddress, p.cfg.HTTPPort)
+	// the TLS hosts are known before the routes redirecting to them are built
+	p.tlsListenerFromIngress(cache)
+	p.tlsListenerFromGateway(cache)
+	p.ingressRulesFromIngress(cache)
+	p.httpRoutesFromGateway(cache)
+	p.applyListenerPolicies(cache)
+	p.localCluster(cache)
+	return cache
+}
+
+// TranslationFailures returns the failures recorded by the last Build.
+func (p *Parser) TranslationFailures() []TranslationFailure {
+	return p.failures
+}
+
+func (p *Parser) hasTranslationFailure(obj client.Object, reason string) bool {
+	for _, f := range p.failures {
+		if f.Object == obj && f.Reason == reason {
+			return true
+		}
 	}
+	return false
+}
+
+func (p *Parser) registerTranslationFailure(reason string, obj client.Object) {
+	p.failures = append(p.failures, TranslationFailure{
+		Object: obj,
+		Reason: reason,
+	})
 }
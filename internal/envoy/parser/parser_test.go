@@ -0,0 +1,83 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/store"
+	"strings"
+	"testing"
+)
+
+// newTestParser returns a parser reading the objects from a store.
+func newTestParser(t *testing.T, cfg Config, objects ...runtime.Object) *Parser {
+	t.Helper()
+	stores := store.NewCacheStores()
+	for _, obj := range objects {
+		if err := stores.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewParser(store.New(*stores, cfg.IngressClass), cfg)
+}
+
+// assertFailures checks that each of the wanted strings is part of a failure of the
+// last Build, and that there are no others.
+func assertFailures(t *testing.T, p *Parser, want ...string) {
+	t.Helper()
+	failures := p.TranslationFailures()
+	if len(failures) != len(want) {
+		t.Fatalf("got failures %v, want %d", failures, len(want))
+	}
+	for _, w := range want {
+		found := false
+		for _, f := range failures {
+			if strings.Contains(f.Reason, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("no failure contains %q in %v", w, failures)
+		}
+	}
+}
+
+// testIngress returns an Ingress routing / of the host to port 80 of the web Service.
+func testIngress(name, host string, anns map[string]string) *netv1.Ingress {
+	pathType := netv1.PathTypePrefix
+	return &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: anns},
+		Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+			Host: host,
+			IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{{
+				Path:     "/",
+				PathType: &pathType,
+				Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+					Name: "web", Port: netv1.ServiceBackendPort{Number: 80},
+				}},
+			}}}},
+		}}},
+	}
+}
+
+// testService returns the web Service, with a port 80 named http.
+func testService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func int32Ptr(n int32) *int32 {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
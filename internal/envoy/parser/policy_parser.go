@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"fmt"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// applyListenerPolicies applies the settings of the parser Config shared by all
+// listeners, adding the clusters of the services they refer to.
+func (p *Parser) applyListenerPolicies(cache *xdscache.Cache) {
+	var rls *resources.RateLimitService
+	if p.cfg.RateLimitService != "" {
+		cluster, err := p.clusterForServiceRef(p.cfg.RateLimitService, "", true, cache)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("rate limit service: %v", err), nil)
+		} else {
+			rls = &resources.RateLimitService{
+				Cluster:         cluster.Name,
+				Domain:          p.cfg.RateLimitDomain,
+				FailureModeDeny: p.cfg.RateLimitFailureModeDeny,
+			}
+		}
+	}
+
+	authz := map[string]resources.ExtAuthz{}
+	jwt := map[string]resources.JWTProvider{}
+	basicAuth := map[string]resources.BasicAuth{}
+	ipFiltering, buffering, connect, weightedDirectResponses := false, false, false, false
+	var maxResponseBytes uint32
+	compression := map[string]bool{}
+	var errorPageRoutes []resources.Route
+	for _, r := range cache.Routes {
+		if r.ExtAuthz != nil {
+			authz[r.ExtAuthz.Name] = *r.ExtAuthz
+		}
+		if r.JWT != nil {
+			jwt[r.JWT.Name] = *r.JWT
+		}
+		if r.BasicAuth != nil {
+			basicAuth[r.BasicAuth.Name] = *r.BasicAuth
+		}
+		ipFiltering = ipFiltering || r.IPFilter != nil
+		buffering = buffering || r.MaxRequestBytes > 0
+		if r.MaxResponseBytes > maxResponseBytes {
+			maxResponseBytes = r.MaxResponseBytes
+		}
+		connect = connect || r.Connect
+		for _, c := range r.WeightedClusters {
+			weightedDirectResponses = weightedDirectResponses || c.DirectResponseStatus != 0
+		}
+		for _, algorithm := range r.Compression {
+			compression[algorithm] = true
+		}
+		if len(r.ErrorPages) > 0 {
+			errorPageRoutes = append(errorPageRoutes, r)
+		}
+	}
+	sort.Slice(errorPageRoutes, func(i, j int) bool { return errorPageRoutes[i].Name < errorPageRoutes[j].Name })
+	var extAuthz []resources.ExtAuthz
+	for _, a := range authz {
+		extAuthz = append(extAuthz, a)
+	}
+	sort.Slice(extAuthz, func(i, j int) bool { return extAuthz[i].Name < extAuthz[j].Name })
+	var jwtProviders []resources.JWTProvider
+	for _, provider := range jwt {
+		jwtProviders = append(jwtProviders, provider)
+	}
+	sort.Slice(jwtProviders, func(i, j int) bool { return jwtProviders[i].Name < jwtProviders[j].Name })
+	var basicAuths []resources.BasicAuth
+	for _, a := range basicAuth {
+		basicAuths = append(basicAuths, a)
+	}
+	sort.Slice(basicAuths, func(i, j int) bool { return basicAuths[i].Name < basicAuths[j].Name })
+
+	accessLogs := p.accessLogs(cache)
+	tracing := p.tracing(cache)
+	for name, l := range cache.Listeners {
+		l.AccessLogs = accessLogs
+		l.Tracing = tracing
+		l.GenerateRequestID = p.cfg.GenerateRequestID
+		l.PreserveExternalRequestID = p.cfg.PreserveExternalRequestID
+		l.ProxyProtocol = p.cfg.ProxyProtocol
+		l.UseRemoteAddress = p.cfg.UseRemoteAddress
+		l.XFFNumTrustedHops = p.cfg.XFFNumTrustedHops
+		l.IPFiltering = ipFiltering
+		l.Buffering = buffering
+		l.MaxResponseBytes = maxResponseBytes
+		l.Connect = connect
+		l.WeightedDirectResponses = weightedDirectResponses
+		l.Compression = compressionAlgorithms(compression)
+		l.RateLimitService = rls
+		l.ExtAuthz = extAuthz
+		l.JWTProviders = jwtProviders
+		l.BasicAuth = basicAuths
+		l.ErrorPageRoutes = errorPageRoutes
+		cache.Listeners[name] = l
+	}
+}
+
+// clusterForServiceRef adds the cluster of a service referred to as
+// namespace/name:port, where port is a number or the name of a Service port. The
+// namespace may be left out when defaultNamespace is set. gRPC services need http2;
+// unless the Service port already speaks HTTP/2, their cluster is named apart from
+// the cluster of the port, which Ingresses may route HTTP/1.1 requests to.
+func (p *Parser) clusterForServiceRef(ref, defaultNamespace string, http2 bool, cache *xdscache.Cache) (resources.Cluster, error) {
+	namespace, name, port, err := parseServiceRef(ref, defaultNamespace)
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	cluster, err := p.clusterForBackend(namespace, netv1.IngressBackend{
+		Service: &netv1.IngressServiceBackend{Name: name, Port: port},
+	})
+	if err != nil {
+		return resources.Cluster{}, err
+	}
+	if http2 && !cluster.HTTP2 {
+		cluster.Name += "/http2"
+		cluster.HTTP2 = true
+	}
+	cache.Clusters[cluster.Name] = cluster
+	return cluster, nil
+}
+
+func parseServiceRef(ref, defaultNamespace string) (namespace, name string, port netv1.ServiceBackendPort, err error) {
+	namespacedName, portValue, ok := strings.Cut(ref, ":")
+	if ok {
+		namespace, name, ok = strings.Cut(namespacedName, "/")
+		if !ok && defaultNamespace != "" {
+			namespace, name, ok = defaultNamespace, namespacedName, true
+		}
+	}
+	if !ok || namespace == "" || name == "" || portValue == "" {
+		return "", "", port, fmt.Errorf("%q is not of the form namespace/name:port", ref)
+	}
+	if number, err := strconv.ParseInt(portValue, 10, 32); err == nil {
+		port.Number = int32(number)
+	} else {
+		port.Name = portValue
+	}
+	return namespace, name, port, nil
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestParseServiceRef(t *testing.T) {
+	tests := []struct {
+		ref              string
+		defaultNamespace string
+		wantNamespace    string
+		wantName         string
+		wantPort         netv1.ServiceBackendPort
+		wantErr          bool
+	}{
+		{ref: "default/ratelimit:8081", wantNamespace: "default", wantName: "ratelimit", wantPort: netv1.ServiceBackendPort{Number: 8081}},
+		{ref: "auth/authz:grpc", wantNamespace: "auth", wantName: "authz", wantPort: netv1.ServiceBackendPort{Name: "grpc"}},
+		{ref: "ratelimit:8081", wantErr: true},
+		{ref: "default/ratelimit", wantErr: true},
+		{ref: "/ratelimit:8081", wantErr: true},
+		{ref: "authz:9000", defaultNamespace: "web", wantNamespace: "web", wantName: "authz", wantPort: netv1.ServiceBackendPort{Number: 9000}},
+		{ref: "auth/authz:9000", defaultNamespace: "web", wantNamespace: "auth", wantName: "authz", wantPort: netv1.ServiceBackendPort{Number: 9000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			namespace, name, port, err := parseServiceRef(tt.ref, tt.defaultNamespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if namespace != tt.wantNamespace || name != tt.wantName || port != tt.wantPort {
+				t.Errorf("got %s/%s:%v, want %s/%s:%v", namespace, name, port, tt.wantNamespace, tt.wantName, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestRateLimitServiceOfListeners(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ratelimit"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "grpc", Port: 8081}}},
+	}
+	p := newTestParser(t, Config{RateLimitService: "default/ratelimit:grpc", RateLimitDomain: "edge"}, svc)
+	cache := p.Build()
+	assertFailures(t, p)
+	rls := cache.Listeners["listener_0"].RateLimitService
+	if rls == nil || rls.Cluster != "default/ratelimit/8081/http2" || rls.Domain != "edge" {
+		t.Fatalf("got rate limit service %+v, want default/ratelimit/8081/http2 of the edge domain", rls)
+	}
+	if !cache.Clusters[rls.Cluster].HTTP2 {
+		t.Error("the rate limit cluster does not talk HTTP/2")
+	}
+
+	p = newTestParser(t, Config{RateLimitService: "default/missing:grpc"})
+	cache = p.Build()
+	assertFailures(t, p, "rate limit service: Service default/missing not found")
+	if rls := cache.Listeners["listener_0"].RateLimitService; rls != nil {
+		t.Errorf("got rate limit service %+v of a missing Service", rls)
+	}
+}
+
+func TestServiceRefClusterSharedWithIngress(t *testing.T) {
+	p := newTestParser(t, Config{RateLimitService: "default/web:http"}, testService(), testIngress("web", "web.example.com", nil))
+	cache := p.Build()
+	assertFailures(t, p)
+	if c := cache.Clusters["default/web/80"]; c.HTTP2 {
+		t.Error("the cluster of the Ingress backend was switched to HTTP/2")
+	}
+	rls := cache.Listeners["listener_0"].RateLimitService
+	if rls == nil || rls.Cluster != "default/web/80/http2" || !cache.Clusters[rls.Cluster].HTTP2 {
+		t.Errorf("got rate limit service %+v, want the HTTP/2 cluster default/web/80/http2", rls)
+	}
+}
+
+func TestClientAddressOfListeners(t *testing.T) {
+	cfg := Config{ProxyProtocol: true, UseRemoteAddress: true, XFFNumTrustedHops: 1}
+	p := newTestParser(t, cfg, testService(), testIngress("web", "web.example.com", nil))
+	for name, l := range p.Build().Listeners {
+		if !l.ProxyProtocol || !l.UseRemoteAddress || l.XFFNumTrustedHops != 1 {
+			t.Errorf("listener %s expects PROXY protocol %v, uses the remote address %v and trusts %d hops, want true, true and 1",
+				name, l.ProxyProtocol, l.UseRemoteAddress, l.XFFNumTrustedHops)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+// applyRateLimitAnnotations configures the local token bucket of a route and the
+// descriptor it sends to the global rate limit service.
+func (p *Parser) applyRateLimitAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+
+	if v := annotations.ExtractLocalRateLimitRequests(anns); v != "" {
+		requests := p.parseUint32(obj, annotations.LocalRateLimitRequestsKey, v)
+		if requests > 0 {
+			rl := &resources.LocalRateLimit{
+				MaxTokens:     requests,
+				TokensPerFill: requests,
+				FillInterval:  time.Second,
+			}
+			if v := annotations.ExtractLocalRateLimitInterval(anns); v != "" {
+				if interval := p.parseDuration(obj, annotations.LocalRateLimitIntervalKey, v); interval > 0 {
+					rl.FillInterval = interval
+				}
+			}
+			if v := annotations.ExtractLocalRateLimitBurst(anns); v != "" {
+				if burst := p.parseUint32(obj, annotations.LocalRateLimitBurstKey, v); burst > requests {
+					rl.MaxTokens = burst
+				}
+			}
+			r.LocalRateLimit = rl
+		}
+	}
+
+	for _, entry := range splitList(annotations.ExtractGlobalRateLimit(anns)) {
+		switch kind, header, _ := strings.Cut(entry, ":"); {
+		case kind == "remote_address" || kind == "path":
+			r.RateLimitDescriptors = append(r.RateLimitDescriptors, resources.RateLimitDescriptor{Kind: kind})
+		case kind == "header" && header != "":
+			r.RateLimitDescriptors = append(r.RateLimitDescriptors, resources.RateLimitDescriptor{Kind: kind, Header: header})
+		default:
+			p.registerTranslationFailure(fmt.Sprintf("invalid rate limit descriptor %q", entry), obj)
+		}
+	}
+	if len(r.RateLimitDescriptors) > 0 && p.cfg.RateLimitService == "" {
+		p.registerTranslationFailure("global rate limiting requires a rate limit service", obj)
+	}
+}
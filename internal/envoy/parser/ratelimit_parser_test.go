@@ -0,0 +1,75 @@
+package parser
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyRateLimitAnnotations(t *testing.T) {
+	rls := Config{RateLimitService: "default/ratelimit:8081"}
+	tests := []struct {
+		name         string
+		cfg          Config
+		annotations  map[string]string
+		want         resources.Route
+		wantFailures []string
+	}{
+		{name: "none"},
+		{
+			name:        "local",
+			annotations: map[string]string{"inendless.com/local-ratelimit-requests": "10"},
+			want: resources.Route{LocalRateLimit: &resources.LocalRateLimit{
+				MaxTokens: 10, TokensPerFill: 10, FillInterval: time.Second,
+			}},
+		},
+		{
+			name: "local with burst",
+			annotations: map[string]string{
+				"inendless.com/local-ratelimit-requests": "100",
+				"inendless.com/local-ratelimit-interval": "1m",
+				"inendless.com/local-ratelimit-burst":    "150",
+			},
+			want: resources.Route{LocalRateLimit: &resources.LocalRateLimit{
+				MaxTokens: 150, TokensPerFill: 100, FillInterval: time.Minute,
+			}},
+		},
+		{
+			name:        "global",
+			cfg:         rls,
+			annotations: map[string]string{"inendless.com/global-ratelimit-descriptors": "remote_address, header:X-Api-Key, path"},
+			want: resources.Route{RateLimitDescriptors: []resources.RateLimitDescriptor{
+				{Kind: "remote_address"},
+				{Kind: "header", Header: "X-Api-Key"},
+				{Kind: "path"},
+			}},
+		},
+		{
+			name:         "invalid descriptor",
+			cfg:          rls,
+			annotations:  map[string]string{"inendless.com/global-ratelimit-descriptors": "header, cookie:session"},
+			wantFailures: []string{`invalid rate limit descriptor "header"`, `invalid rate limit descriptor "cookie:session"`},
+		},
+		{
+			name:         "global without a service",
+			annotations:  map[string]string{"inendless.com/global-ratelimit-descriptors": "path"},
+			want:         resources.Route{RateLimitDescriptors: []resources.RateLimitDescriptor{{Kind: "path"}}},
+			wantFailures: []string{"global rate limiting requires a rate limit service"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations}}
+			p := newTestParser(t, tt.cfg)
+			var got resources.Route
+			p.applyRateLimitAnnotations(ing, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			assertFailures(t, p, tt.wantFailures...)
+		})
+	}
+}
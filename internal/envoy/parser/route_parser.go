@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyRouteAnnotations configures rewrites, headers, CORS, rate limits, tracing, compression, buffering, timeouts
+// and retries of a route from the annotations of the object it was generated from.
+func (p *Parser) applyRouteAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+
+	p.applyTimeoutAnnotations(obj, r)
+	p.applyRewriteAnnotations(obj, r)
+	p.applyHeaderAnnotations(obj, r)
+	p.applyCorsAnnotations(obj, r)
+	p.claimVirtualHostPolicies(obj, r)
+	p.applyRateLimitAnnotations(obj, r)
+	p.applyTracingAnnotations(obj, r)
+	p.applyCompressionAnnotations(obj, r)
+	if v := annotations.ExtractMaxRequestBytes(anns); v != "" {
+		r.MaxRequestBytes = p.parseUint32(obj, annotations.MaxRequestBytesKey, v)
+	}
+	if v := annotations.ExtractMaxResponseBytes(anns); v != "" {
+		r.MaxResponseBytes = p.parseUint32(obj, annotations.MaxResponseBytesKey, v)
+	}
+}
+
+// applyTimeoutAnnotations sets the timeouts and the retry policy of a route. A zero
+// request or idle timeout disables it, like the timeouts of HTTPRoutes.
+func (p *Parser) applyTimeoutAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+
+	if v := annotations.ExtractRequestTimeout(anns); v != "" {
+		r.Timeout = p.parseTimeout(obj, annotations.RequestTimeoutKey, v)
+	}
+	if v := annotations.ExtractIdleTimeout(anns); v != "" {
+		r.IdleTimeout = p.parseTimeout(obj, annotations.IdleTimeoutKey, v)
+	}
+
+	rp := &resources.RetryPolicy{
+		RetryOn:              annotations.ExtractRetryOn(anns),
+		HedgeOnPerTryTimeout: annotations.ExtractHedgeOnPerTryTimeout(anns),
+	}
+	if v := annotations.ExtractNumRetries(anns); v != "" {
+		rp.NumRetries = p.parseUint32(obj, annotations.NumRetriesKey, v)
+	}
+	if v := annotations.ExtractPerTryTimeout(anns); v != "" {
+		rp.PerTryTimeout = p.parseDuration(obj, annotations.PerTryTimeoutKey, v)
+	}
+	if v := annotations.ExtractRetryBackoffBase(anns); v != "" {
+		rp.BackoffBase = p.parseDuration(obj, annotations.RetryBackoffBaseKey, v)
+	}
+	if v := annotations.ExtractRetryBackoffMax(anns); v != "" {
+		rp.BackoffMax = p.parseDuration(obj, annotations.RetryBackoffMaxKey, v)
+	}
+	if *rp != (resources.RetryPolicy{}) {
+		r.RetryPolicy = rp
+	}
+}
+
+// applyRewriteAnnotations rewrites the path and host of requests before they are
+// forwarded. rewrite-target replaces the matched path prefix, or is the substitution
+// of rewrite-regex when that is set; strip-prefix removes the matched prefix.
+func (p *Parser) applyRewriteAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+	target := annotations.ExtractRewriteTarget(anns)
+
+	switch regex := annotations.ExtractRewriteRegex(anns); {
+	case regex != "":
+		if _, err := regexp.Compile(regex); err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("invalid rewrite regex %q: %v", regex, err), obj)
+			break
+		}
+		r.RegexRewrite = &resources.RegexRewrite{Pattern: regex, Substitution: target}
+	case target != "":
+		r.PrefixRewrite = target
+	case annotations.ExtractStripPrefix(anns):
+		r.PrefixRewrite = "/"
+	}
+
+	r.HostRewrite = annotations.ExtractHostRewrite(anns)
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyRouteAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        resources.Route
+		wantFailure string
+	}{
+		{name: "none"},
+		{
+			name: "timeouts and retries",
+			annotations: map[string]string{
+				"inendless.com/request-timeout":             "30s",
+				"inendless.com/idle-timeout":                "5m",
+				"inendless.com/retry-on":                    "5xx,reset",
+				"inendless.com/num-retries":                 "2",
+				"inendless.com/per-try-timeout":             "10s",
+				"inendless.com/retry-backoff-base-interval": "100ms",
+				"inendless.com/retry-backoff-max-interval":  "1s",
+				"inendless.com/hedge-on-per-try-timeout":    "true",
+			},
+			want: resources.Route{
+				Timeout:     30 * time.Second,
+				IdleTimeout: 5 * time.Minute,
+				RetryPolicy: &resources.RetryPolicy{
+					RetryOn:              "5xx,reset",
+					NumRetries:           2,
+					PerTryTimeout:        10 * time.Second,
+					BackoffBase:          100 * time.Millisecond,
+					BackoffMax:           time.Second,
+					HedgeOnPerTryTimeout: true,
+				},
+			},
+		},
+		{
+			name:        "disabled timeouts",
+			annotations: map[string]string{"inendless.com/request-timeout": "0s", "inendless.com/idle-timeout": "0"},
+			want:        resources.Route{Timeout: resources.NoTimeout, IdleTimeout: resources.NoTimeout},
+		},
+		{
+			name:        "invalid retries",
+			annotations: map[string]string{"inendless.com/num-retries": "many"},
+			wantFailure: `invalid number "many" in annotation inendless.com/num-retries`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations}}
+			p := newTestParser(t, Config{})
+			var got resources.Route
+			p.applyRouteAnnotations(ing, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure)
+		})
+	}
+}
+
+func TestApplyRewriteAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        resources.Route
+		wantFailure string
+	}{
+		{name: "none"},
+		{
+			name:        "rewrite target",
+			annotations: map[string]string{"inendless.com/rewrite-target": "/v2/"},
+			want:        resources.Route{PrefixRewrite: "/v2/"},
+		},
+		{
+			name:        "strip prefix",
+			annotations: map[string]string{"inendless.com/strip-prefix": "true"},
+			want:        resources.Route{PrefixRewrite: "/"},
+		},
+		{
+			name: "regex with host",
+			annotations: map[string]string{
+				"inendless.com/rewrite-regex":  "^/api/(.*)",
+				"inendless.com/rewrite-target": "/\\1",
+				"inendless.com/host-rewrite":   "api.internal",
+			},
+			want: resources.Route{
+				RegexRewrite: &resources.RegexRewrite{Pattern: "^/api/(.*)", Substitution: "/\\1"},
+				HostRewrite:  "api.internal",
+			},
+		},
+		{
+			name:        "invalid regex",
+			annotations: map[string]string{"inendless.com/rewrite-regex": "/("},
+			wantFailure: `invalid rewrite regex "/("`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations}}
+			p := newTestParser(t, Config{})
+			var got resources.Route
+			p.applyRewriteAnnotations(ing, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure)
+		})
+	}
+}
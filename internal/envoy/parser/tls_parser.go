@@ -0,0 +1,331 @@
+package parser
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"net/http"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"strings"
+	"time"
+)
+
+const (
+	caCertKey = "ca.crt"
+	caCRLKey  = "ca.crl"
+
+	defaultHSTSMaxAge = 365 * 24 * time.Hour
+)
+
+// tlsListenerFromIngress adds the HTTPS listener with a filter chain for every TLS
+// host of the Ingresses. The first Ingress claiming a host wins, and hosts whose
+// certificate or client CA cannot be loaded are left out rather than served with
+// weaker settings.
+func (p *Parser) tlsListenerFromIngress(cache *xdscache.Cache) {
+	var chains []resources.TLSFilterChain
+	claimed := map[string]bool{}
+
+	for _, ing := range p.storer.ListIngressesV1() {
+		if len(ing.Spec.TLS) == 0 {
+			continue
+		}
+		validation, err := p.clientValidation(ing)
+		if err != nil {
+			p.registerTranslationFailure(fmt.Sprintf("client certificate authentication: %v", err), ing)
+			continue
+		}
+		for _, t := range ing.Spec.TLS {
+			cert, err := p.tlsSecret(ing.Namespace, t.SecretName)
+			if err != nil {
+				p.registerTranslationFailure(err.Error(), ing)
+				continue
+			}
+			hosts := t.Hosts
+			if len(hosts) == 0 {
+				hosts = []string{""}
+			}
+			for _, host := range hosts {
+				if claimed[host] {
+					p.registerTranslationFailure(fmt.Sprintf("TLS host %q is already served by another Ingress", host), ing)
+					continue
+				}
+				claimed[host] = true
+				p.tlsHosts[host] = ingressKey(ing)
+				cache.Secrets[cert.Name] = *cert
+
+				chain := resources.TLSFilterChain{
+					Certificate:      cert.Name,
+					ClientValidation: validation,
+				}
+				if host != "" {
+					chain.ServerNames = []string{host}
+				}
+				chains = append(chains, chain)
+			}
+		}
+	}
+
+	p.addTLSFilterChains(cache, chains)
+}
+
+// addTLSFilterChains adds filter chains to the HTTPS listener, which is only served
+// when it has some.
+func (p *Parser) addTLSFilterChains(cache *xdscache.Cache, chains []resources.TLSFilterChain) {
+	if len(chains) == 0 {
+		return
+	}
+	l, ok := cache.Listeners["listener_https"]
+	if !ok {
+		l = resources.Listener{
+			Name:       "listener_https",
+			Address:    p.cfg.ListenAddress,
+			Port:       p.cfg.HTTPSPort,
+			RouteNames: []string{"listener_https"},
+		}
+	}
+	l.TLSFilterChains = append(l.TLSFilterChains, chains...)
+	cache.Listeners["listener_https"] = l
+}
+
+// applyHTTPSRedirect redirects the plaintext requests of a route to HTTPS when its
+// host is served by a TLS filter chain built from the Ingress, unless the Ingress
+// opts out. Hosts requiring client certificates cannot opt out, as their plaintext
+// requests would skip the authentication. Hosts whose certificate could not be
+// loaded, or claimed by another Ingress, are left in plaintext rather than
+// redirected to a certificate they do not have.
+func (p *Parser) applyHTTPSRedirect(ing *netv1.Ingress, r *resources.Route) {
+	if !p.servesTLSHost(ing, r.Host) {
+		return
+	}
+	anns := ing.Annotations
+
+	redirect := annotations.ExtractSSLRedirect(anns)
+	if !redirect && annotations.ExtractAuthTLSSecret(anns) != "" {
+		reason := "ssl-redirect is ignored on hosts requiring client certificates"
+		if !p.hasTranslationFailure(ing, reason) {
+			p.registerTranslationFailure(reason, ing)
+		}
+		redirect = true
+	}
+	if redirect {
+		switch code := annotations.ExtractSSLRedirectCode(anns); code {
+		case "", "308":
+			r.HTTPSRedirectCode = http.StatusPermanentRedirect
+		case "301":
+			r.HTTPSRedirectCode = http.StatusMovedPermanently
+		default:
+			p.registerTranslationFailure(fmt.Sprintf("invalid redirect code %q, using 308", code), ing)
+			r.HTTPSRedirectCode = http.StatusPermanentRedirect
+		}
+	}
+
+	if annotations.ExtractHSTS(anns) {
+		hsts := &resources.HSTS{
+			MaxAge:            defaultHSTSMaxAge,
+			IncludeSubDomains: annotations.ExtractHSTSIncludeSubDomains(anns),
+			Preload:           annotations.ExtractHSTSPreload(anns),
+		}
+		if v := annotations.ExtractHSTSMaxAge(anns); v != "" {
+			hsts.MaxAge = time.Duration(p.parseUint32(ing, annotations.HSTSMaxAgeKey, v)) * time.Second
+		}
+		r.HSTS = hsts
+	}
+}
+
+// servesTLSHost reports whether the TLS filter chain of a host of the Ingress, or of
+// its TLS entry without hosts, was built.
+func (p *Parser) servesTLSHost(ing *netv1.Ingress, host string) bool {
+	key := ingressKey(ing)
+	for _, t := range ing.Spec.TLS {
+		if len(t.Hosts) == 0 && p.tlsHosts[""] == key {
+			return true
+		}
+		for _, h := range t.Hosts {
+			if h == host && p.tlsHosts[h] == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ingressKey(ing *netv1.Ingress) string {
+	return ing.Namespace + "/" + ing.Name
+}
+
+// clientValidation returns the client certificate requirements of the TLS hosts of an
+// Ingress, or nil when clients are not authenticated.
+func (p *Parser) clientValidation(ing *netv1.Ingress) (*resources.ClientValidation, error) {
+	anns := ing.Annotations
+	name := annotations.ExtractAuthTLSSecret(anns)
+	if name == "" {
+		return nil, nil
+	}
+	secret, err := p.storer.GetSecret(ing.Namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	cv := &resources.ClientValidation{
+		CACert: secret.Data[caCertKey],
+		CRL:    secret.Data[caCRLKey],
+		Mode:   annotations.ExtractAuthTLSVerifyClient(anns),
+	}
+	if len(cv.CACert) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no %s", ing.Namespace, name, caCertKey)
+	}
+	switch cv.Mode {
+	case "":
+		cv.Mode = "on"
+	case "on", "optional", "optional_no_ca":
+	default:
+		return nil, fmt.Errorf("invalid verify-client value %q", cv.Mode)
+	}
+	if v := annotations.ExtractAuthTLSVerifyDepth(anns); v != "" {
+		cv.VerifyDepth = p.parseUint32(ing, annotations.AuthTLSVerifyDepthKey, v)
+	}
+	return cv, nil
+}
+
+// upstreamTLS returns the TLS settings towards a port of a Service, enabled by a
+// BackendTLSPolicy, annotation or an https or wss appProtocol. The server name
+// defaults to the DNS name of the Service, and servers are verified against the
+// system CA bundle, systemCAFile, unless the policy or the Service names its own CA.
+// Services without a policy may turn verification off with the upstream-tls-verify
+// annotation. A CA or client certificate that cannot be loaded is an error rather
+// than a silent downgrade to unverified TLS.
+func (p *Parser) upstreamTLS(svc *corev1.Service, portName, appProtocol string) (*resources.UpstreamTLS, error) {
+	anns := svc.Annotations
+	policy := p.backendTLSPolicy(svc, portName)
+	if policy == nil && !annotations.ExtractUpstreamTLS(anns) && !isTLSAppProtocol(appProtocol) {
+		return nil, nil
+	}
+
+	t := &resources.UpstreamTLS{SNI: annotations.ExtractUpstreamSNI(anns), CAFile: systemCAFile}
+	if t.SNI == "" {
+		t.SNI = p.serviceHostname(svc)
+	}
+
+	if policy != nil {
+		if err := p.applyBackendTLSPolicy(policy, t); err != nil {
+			return nil, err
+		}
+	} else if !annotations.ExtractUpstreamTLSVerify(anns) {
+		t.CAFile = ""
+	} else if name := annotations.ExtractUpstreamCASecret(anns); name != "" {
+		secret, err := p.storer.GetSecret(svc.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if t.CACert = secret.Data[caCertKey]; len(t.CACert) == 0 {
+			return nil, fmt.Errorf("Secret %s/%s has no %s", svc.Namespace, name, caCertKey)
+		}
+	} else if name := annotations.ExtractUpstreamCAConfigMap(anns); name != "" {
+		configMap, err := p.storer.GetConfigMap(svc.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if t.CACert = []byte(configMap.Data[caCertKey]); len(t.CACert) == 0 {
+			return nil, fmt.Errorf("ConfigMap %s/%s has no %s", svc.Namespace, name, caCertKey)
+		}
+	}
+
+	if name := annotations.ExtractUpstreamClientCertSecret(anns); name != "" {
+		cert, err := p.tlsSecret(svc.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		t.ClientCertificate = cert
+	}
+	return t, nil
+}
+
+// backendTLSPolicy returns the BackendTLSPolicy of a port of a Service. A policy of
+// the port takes precedence over a policy of the whole Service, and the first policy
+// by name wins among equals.
+func (p *Parser) backendTLSPolicy(svc *corev1.Service, portName string) *gatewayv1alpha2.BackendTLSPolicy {
+	var found *gatewayv1alpha2.BackendTLSPolicy
+	for _, policy := range p.storer.ListBackendTLSPolicies() {
+		ref := policy.Spec.TargetRef
+		if policy.Namespace != svc.Namespace || ref.Group != "" || ref.Kind != "Service" || string(ref.Name) != svc.Name {
+			continue
+		}
+		if ref.Namespace != nil && string(*ref.Namespace) != svc.Namespace {
+			continue
+		}
+		if ref.SectionName != nil {
+			if string(*ref.SectionName) == portName {
+				return policy
+			}
+			continue
+		}
+		if found == nil {
+			found = policy
+		}
+	}
+	return found
+}
+
+// applyBackendTLSPolicy verifies the server of a backend by the hostname and the CA
+// certificates of a BackendTLSPolicy: the ca.crt of its ConfigMaps, concatenated, or
+// the system CA bundle.
+func (p *Parser) applyBackendTLSPolicy(policy *gatewayv1alpha2.BackendTLSPolicy, t *resources.UpstreamTLS) error {
+	config := policy.Spec.TLS
+	t.SNI = string(config.Hostname)
+	switch {
+	case len(config.CACertRefs) > 0 && config.WellKnownCACerts != nil:
+		return fmt.Errorf("BackendTLSPolicy %s/%s sets both caCertRefs and wellKnownCACerts", policy.Namespace, policy.Name)
+	case config.WellKnownCACerts != nil:
+		if *config.WellKnownCACerts != gatewayv1alpha2.WellKnownCACertSystem {
+			return fmt.Errorf("BackendTLSPolicy %s/%s: well-known CA certificates %s are not supported", policy.Namespace, policy.Name, *config.WellKnownCACerts)
+		}
+		return nil
+	case len(config.CACertRefs) == 0:
+		return fmt.Errorf("BackendTLSPolicy %s/%s has no CA certificates", policy.Namespace, policy.Name)
+	}
+	for _, ref := range config.CACertRefs {
+		if ref.Group != "" || ref.Kind != "ConfigMap" {
+			return fmt.Errorf("BackendTLSPolicy %s/%s: CA certificate %s is not a ConfigMap", policy.Namespace, policy.Name, ref.Name)
+		}
+		configMap, err := p.storer.GetConfigMap(policy.Namespace, string(ref.Name))
+		if err != nil {
+			return err
+		}
+		ca := configMap.Data[caCertKey]
+		if ca == "" {
+			return fmt.Errorf("ConfigMap %s/%s has no %s", policy.Namespace, ref.Name, caCertKey)
+		}
+		if len(t.CACert) > 0 && !strings.HasSuffix(string(t.CACert), "\n") {
+			t.CACert = append(t.CACert, '\n')
+		}
+		t.CACert = append(t.CACert, ca...)
+	}
+	return nil
+}
+
+func isTLSAppProtocol(appProtocol string) bool {
+	switch appProtocol {
+	case "https", "wss", "kubernetes.io/wss":
+		return true
+	}
+	return false
+}
+
+// tlsSecret loads a kubernetes.io/tls Secret as an SDS secret.
+func (p *Parser) tlsSecret(namespace, name string) (*resources.Secret, error) {
+	secret, err := p.storer.GetSecret(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	cert, key := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if len(cert) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no %s or %s", namespace, name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	return &resources.Secret{
+		Name:        fmt.Sprintf("%s.%s", namespace, name),
+		Certificate: cert,
+		PrivateKey:  key,
+	}, nil
+}
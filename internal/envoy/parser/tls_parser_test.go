@@ -0,0 +1,472 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"net/http"
+	"reflect"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"testing"
+	"time"
+)
+
+func TestUpstreamTLS(t *testing.T) {
+	caConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ca"},
+		Data:       map[string]string{caCertKey: "CA"},
+	}
+	clientCert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "client"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("CERT"), corev1.TLSPrivateKeyKey: []byte("KEY")},
+	}
+	tests := []struct {
+		name        string
+		svc         corev1.Service
+		appProtocol string
+		wantSNI     string
+		wantCA      string
+		wantCAFile  string
+		wantClient  string
+		wantNil     bool
+		wantErr     bool
+	}{
+		{name: "plaintext", wantNil: true},
+		{
+			name:        "https appProtocol",
+			appProtocol: "https",
+			wantSNI:     "web.default.svc.cluster.local",
+			wantCAFile:  systemCAFile,
+		},
+		{
+			name: "ExternalName",
+			svc: corev1.Service{Spec: corev1.ServiceSpec{
+				Type:         corev1.ServiceTypeExternalName,
+				ExternalName: "api.example.com",
+			}},
+			appProtocol: "https",
+			wantSNI:     "api.example.com",
+			wantCAFile:  systemCAFile,
+		},
+		{
+			name: "annotations",
+			svc: corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				annotations.AnnotationPrefix + annotations.UpstreamTLSKey:              "true",
+				annotations.AnnotationPrefix + annotations.UpstreamSNIKey:              "web.internal",
+				annotations.AnnotationPrefix + annotations.UpstreamCAConfigMapKey:      "ca",
+				annotations.AnnotationPrefix + annotations.UpstreamClientCertSecretKey: "client",
+			}}},
+			wantSNI:    "web.internal",
+			wantCA:     "CA",
+			wantClient: "default.client",
+		},
+		{
+			name: "verification off",
+			svc: corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				annotations.AnnotationPrefix + annotations.UpstreamTLSKey:       "true",
+				annotations.AnnotationPrefix + annotations.UpstreamTLSVerifyKey: "false",
+			}}},
+			wantSNI: "web.default.svc.cluster.local",
+		},
+		{
+			name: "missing CA",
+			svc: corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				annotations.AnnotationPrefix + annotations.UpstreamTLSKey:      "true",
+				annotations.AnnotationPrefix + annotations.UpstreamCASecretKey: "missing",
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "missing client certificate",
+			svc: corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				annotations.AnnotationPrefix + annotations.UpstreamTLSKey:              "true",
+				annotations.AnnotationPrefix + annotations.UpstreamClientCertSecretKey: "missing",
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := tt.svc
+			svc.Namespace, svc.Name = "default", "web"
+			p := newTestParser(t, Config{}, caConfigMap, clientCert)
+			got, err := p.upstreamTLS(&svc, "http", tt.appProtocol)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr || tt.wantNil {
+				if got != nil {
+					t.Errorf("got %+v, want nil", got)
+				}
+				return
+			}
+			if got.SNI != tt.wantSNI || string(got.CACert) != tt.wantCA {
+				t.Errorf("got SNI %q and CA %q, want %q and %q", got.SNI, got.CACert, tt.wantSNI, tt.wantCA)
+			}
+			if len(got.CACert) == 0 && got.CAFile != tt.wantCAFile {
+				t.Errorf("got CA file %q, want %q", got.CAFile, tt.wantCAFile)
+			}
+			var client string
+			if got.ClientCertificate != nil {
+				client = got.ClientCertificate.Name
+			}
+			if client != tt.wantClient {
+				t.Errorf("got client certificate %q, want %q", client, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestTLSListenerFromIngress(t *testing.T) {
+	cert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-cert"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("CERT"), corev1.TLSPrivateKeyKey: []byte("KEY")},
+	}
+	ca := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "client-ca"},
+		Data:       map[string][]byte{caCertKey: []byte("CA")},
+	}
+	tlsIngress := func(name string, anns map[string]string, tls ...netv1.IngressTLS) *netv1.Ingress {
+		return &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: anns},
+			Spec:       netv1.IngressSpec{TLS: tls},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		objects      []runtime.Object
+		wantChains   []resources.TLSFilterChain
+		wantFailures []string
+	}{
+		{name: "no TLS", objects: []runtime.Object{tlsIngress("web", nil)}},
+		{
+			name: "hosts and fallback",
+			objects: []runtime.Object{cert, tlsIngress("web", nil,
+				netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"},
+				netv1.IngressTLS{SecretName: "web-cert"},
+			)},
+			wantChains: []resources.TLSFilterChain{
+				{ServerNames: []string{"web.example.com"}, Certificate: "default.web-cert"},
+				{Certificate: "default.web-cert"},
+			},
+		},
+		{
+			name: "host claimed first",
+			objects: []runtime.Object{
+				cert,
+				tlsIngress("a", nil, netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"}),
+				tlsIngress("b", nil, netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"}),
+			},
+			wantChains:   []resources.TLSFilterChain{{ServerNames: []string{"web.example.com"}, Certificate: "default.web-cert"}},
+			wantFailures: []string{`TLS host "web.example.com" is already served by another Ingress`},
+		},
+		{
+			name: "client certificates",
+			objects: []runtime.Object{cert, ca, tlsIngress("web", map[string]string{
+				"inendless.com/auth-tls-secret":        "client-ca",
+				"inendless.com/auth-tls-verify-client": "optional",
+				"inendless.com/auth-tls-verify-depth":  "2",
+			}, netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"})},
+			wantChains: []resources.TLSFilterChain{{
+				ServerNames:      []string{"web.example.com"},
+				Certificate:      "default.web-cert",
+				ClientValidation: &resources.ClientValidation{CACert: []byte("CA"), Mode: "optional", VerifyDepth: 2},
+			}},
+		},
+		{
+			name: "invalid verify-client",
+			objects: []runtime.Object{cert, ca, tlsIngress("web", map[string]string{
+				"inendless.com/auth-tls-secret":        "client-ca",
+				"inendless.com/auth-tls-verify-client": "always",
+			}, netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"})},
+			wantFailures: []string{`invalid verify-client value "always"`},
+		},
+		{
+			name:         "missing certificate",
+			objects:      []runtime.Object{tlsIngress("web", nil, netv1.IngressTLS{Hosts: []string{"web.example.com"}, SecretName: "web-cert"})},
+			wantFailures: []string{"Secret default/web-cert not found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t, Config{HTTPSPort: 8443}, tt.objects...)
+			cache := p.Build()
+			assertFailures(t, p, tt.wantFailures...)
+			l, ok := cache.Listeners["listener_https"]
+			if len(tt.wantChains) == 0 {
+				if ok {
+					t.Errorf("got HTTPS listener %+v, want none", l)
+				}
+				return
+			}
+			if l.Port != 8443 || !reflect.DeepEqual(l.TLSFilterChains, tt.wantChains) {
+				t.Errorf("got listener on %d with chains %+v, want 8443 with %+v", l.Port, l.TLSFilterChains, tt.wantChains)
+			}
+		})
+	}
+}
+
+func TestApplyHTTPSRedirect(t *testing.T) {
+	tls := []netv1.IngressTLS{{Hosts: []string{"web.example.com"}, SecretName: "cert"}}
+	tests := []struct {
+		name        string
+		tls         []netv1.IngressTLS
+		host        string
+		annotations map[string]string
+		wantCode    uint32
+		wantHSTS    *resources.HSTS
+		wantFailure string
+	}{
+		{name: "plaintext host", tls: tls, host: "other.example.com"},
+		{name: "TLS host", tls: tls, host: "web.example.com", wantCode: http.StatusPermanentRedirect},
+		{name: "TLS for every host", tls: []netv1.IngressTLS{{SecretName: "cert"}}, host: "other.example.com", wantCode: http.StatusPermanentRedirect},
+		{
+			name:        "opted out",
+			tls:         tls,
+			host:        "web.example.com",
+			annotations: map[string]string{"inendless.com/ssl-redirect": "false"},
+		},
+		{
+			name: "301 with HSTS",
+			tls:  tls,
+			host: "web.example.com",
+			annotations: map[string]string{
+				"inendless.com/ssl-redirect-code":       "301",
+				"inendless.com/hsts":                    "true",
+				"inendless.com/hsts-max-age":            "3600",
+				"inendless.com/hsts-include-subdomains": "true",
+			},
+			wantCode: http.StatusMovedPermanently,
+			wantHSTS: &resources.HSTS{MaxAge: time.Hour, IncludeSubDomains: true},
+		},
+		{
+			name: "opt out with client certificates",
+			tls:  tls,
+			host: "web.example.com",
+			annotations: map[string]string{
+				"inendless.com/ssl-redirect":    "false",
+				"inendless.com/auth-tls-secret": "client-ca",
+			},
+			wantCode:    http.StatusPermanentRedirect,
+			wantFailure: "ssl-redirect is ignored on hosts requiring client certificates",
+		},
+		{
+			name:        "invalid code",
+			tls:         tls,
+			host:        "web.example.com",
+			annotations: map[string]string{"inendless.com/ssl-redirect-code": "302"},
+			wantCode:    http.StatusPermanentRedirect,
+			wantFailure: `invalid redirect code "302", using 308`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations},
+				Spec:       netv1.IngressSpec{TLS: tt.tls},
+			}
+			p := newTestParser(t, Config{})
+			// the TLS filter chains of the Ingress were built
+			p.tlsHosts = map[string]string{}
+			for _, entry := range tt.tls {
+				if len(entry.Hosts) == 0 {
+					p.tlsHosts[""] = ingressKey(ing)
+				}
+				for _, h := range entry.Hosts {
+					p.tlsHosts[h] = ingressKey(ing)
+				}
+			}
+			r := resources.Route{Host: tt.host}
+			p.applyHTTPSRedirect(ing, &r)
+			if r.HTTPSRedirectCode != tt.wantCode || !reflect.DeepEqual(r.HSTS, tt.wantHSTS) {
+				t.Errorf("got code %d and HSTS %+v, want %d and %+v", r.HTTPSRedirectCode, r.HSTS, tt.wantCode, tt.wantHSTS)
+			}
+			if tt.wantFailure == "" {
+				assertFailures(t, p)
+				return
+			}
+			assertFailures(t, p, tt.wantFailure)
+		})
+	}
+}
+
+func TestBackendTLSPolicy(t *testing.T) {
+	system := gatewayv1alpha2.WellKnownCACertSystem
+	policy := func(name string, sectionName string, config gatewayv1alpha2.BackendTLSPolicyConfig) *gatewayv1alpha2.BackendTLSPolicy {
+		policy := &gatewayv1alpha2.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: gatewayv1alpha2.BackendTLSPolicySpec{
+				TargetRef: gatewayv1alpha2.PolicyTargetReferenceWithSectionName{
+					PolicyTargetReference: gatewayv1alpha2.PolicyTargetReference{Kind: "Service", Name: "web"},
+				},
+				TLS: config,
+			},
+		}
+		if sectionName != "" {
+			section := gatewayv1alpha2.SectionName(sectionName)
+			policy.Spec.TargetRef.SectionName = &section
+		}
+		return policy
+	}
+	caRefs := func(names ...string) []gatewayv1alpha2.LocalObjectReference {
+		var refs []gatewayv1alpha2.LocalObjectReference
+		for _, name := range names {
+			refs = append(refs, gatewayv1alpha2.LocalObjectReference{Kind: "ConfigMap", Name: gatewayv1alpha2.ObjectName(name)})
+		}
+		return refs
+	}
+	configMap := func(name, ca string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Data:       map[string]string{caCertKey: ca},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		policies   []*gatewayv1alpha2.BackendTLSPolicy
+		portName   string
+		wantSNI    string
+		wantCA     string
+		wantCAFile string
+		wantNil    bool
+		wantErr    bool
+	}{
+		{name: "no policy", portName: "http", wantNil: true},
+		{
+			name:       "system CA",
+			policies:   []*gatewayv1alpha2.BackendTLSPolicy{policy("web", "", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal", WellKnownCACerts: &system})},
+			portName:   "http",
+			wantSNI:    "web.internal",
+			wantCAFile: systemCAFile,
+		},
+		{
+			name:     "CA ConfigMaps",
+			policies: []*gatewayv1alpha2.BackendTLSPolicy{policy("web", "", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal", CACertRefs: caRefs("ca", "ca2")})},
+			portName: "http",
+			wantSNI:  "web.internal",
+			wantCA:   "CA\nCA2",
+		},
+		{
+			name: "policy of the port",
+			policies: []*gatewayv1alpha2.BackendTLSPolicy{
+				policy("a", "", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal", WellKnownCACerts: &system}),
+				policy("b", "https", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "secure.internal", CACertRefs: caRefs("ca")}),
+			},
+			portName: "https",
+			wantSNI:  "secure.internal",
+			wantCA:   "CA",
+		},
+		{
+			name:     "policy of another port",
+			policies: []*gatewayv1alpha2.BackendTLSPolicy{policy("web", "https", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal", WellKnownCACerts: &system})},
+			portName: "http",
+			wantNil:  true,
+		},
+		{
+			name:     "missing CA",
+			policies: []*gatewayv1alpha2.BackendTLSPolicy{policy("web", "", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal", CACertRefs: caRefs("missing")})},
+			portName: "http",
+			wantErr:  true,
+		},
+		{
+			name:     "no CA",
+			policies: []*gatewayv1alpha2.BackendTLSPolicy{policy("web", "", gatewayv1alpha2.BackendTLSPolicyConfig{Hostname: "web.internal"})},
+			portName: "http",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{configMap("ca", "CA"), configMap("ca2", "CA2")}
+			for _, policy := range tt.policies {
+				objects = append(objects, policy)
+			}
+			p := newTestParser(t, Config{}, objects...)
+			got, err := p.upstreamTLS(testService(), tt.portName, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr || tt.wantNil {
+				if got != nil {
+					t.Errorf("got %+v, want nil", got)
+				}
+				return
+			}
+			if got.SNI != tt.wantSNI || string(got.CACert) != tt.wantCA {
+				t.Errorf("got SNI %q and CA %q, want %q and %q", got.SNI, got.CACert, tt.wantSNI, tt.wantCA)
+			}
+			if len(got.CACert) == 0 && got.CAFile != tt.wantCAFile {
+				t.Errorf("got CA file %q, want %q", got.CAFile, tt.wantCAFile)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	pathType := netv1.PathTypePrefix
+	ingress := func(name string, tls []netv1.IngressTLS, hosts ...string) *netv1.Ingress {
+		ing := &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       netv1.IngressSpec{TLS: tls},
+		}
+		for _, host := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, netv1.IngressRule{
+				Host: host,
+				IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{{
+					Path:     "/",
+					PathType: &pathType,
+					Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+						Name: "web", Port: netv1.ServiceBackendPort{Number: 80},
+					}},
+				}}}},
+			})
+		}
+		return ing
+	}
+	cert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cert"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("CERT"), corev1.TLSPrivateKeyKey: []byte("KEY")},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+	p := newTestParser(t, Config{}, cert, svc,
+		// a claims a.example.com first
+		ingress("a", []netv1.IngressTLS{{Hosts: []string{"a.example.com"}, SecretName: "cert"}}, "a.example.com"),
+		ingress("b", []netv1.IngressTLS{
+			{Hosts: []string{"a.example.com"}, SecretName: "cert"},
+			{Hosts: []string{"b.example.com"}, SecretName: "missing"},
+		}, "a.example.com", "b.example.com", "c.example.com"),
+		ingress("c", []netv1.IngressTLS{{SecretName: "cert"}}, "c.example.com"),
+	)
+	cache := p.Build()
+
+	want := map[string]uint32{
+		"default/a/0/0": http.StatusPermanentRedirect,
+		// the host is served with the chain of another Ingress
+		"default/b/0/0": 0,
+		// the certificate could not be loaded
+		"default/b/1/0": 0,
+		// the Ingress has no TLS for the host
+		"default/b/2/0": 0,
+		// the chain without server names serves every host
+		"default/c/0/0": http.StatusPermanentRedirect,
+	}
+	for name, code := range want {
+		r, ok := cache.Routes[name]
+		if !ok {
+			t.Errorf("no route %s", name)
+			continue
+		}
+		if r.HTTPSRedirectCode != code {
+			t.Errorf("route %s redirects with %d, want %d", name, r.HTTPSRedirectCode, code)
+		}
+	}
+	assertFailures(t, p, `TLS host "a.example.com" is already served by another Ingress`, "missing")
+}
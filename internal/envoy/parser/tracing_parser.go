@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"fmt"
+	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/resources"
+	"kubernetes-controller/internal/envoy/xdscache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+// tracing returns the tracing of the listeners from the parser Config, adding the
+// cluster of the collector.
+func (p *Parser) tracing(cache *xdscache.Cache) *resources.Tracing {
+	if p.cfg.TracingProvider == "" {
+		return nil
+	}
+	if p.cfg.TracingProvider != "opentelemetry" && p.cfg.TracingProvider != "zipkin" {
+		p.registerTranslationFailure(fmt.Sprintf("invalid tracing provider %q", p.cfg.TracingProvider), nil)
+		return nil
+	}
+	tags, err := parseTracingTags(p.cfg.TracingTags)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("tracing tags: %v", err), nil)
+	}
+	// the OpenTelemetry collector is reached over gRPC, Zipkin over HTTP/1.1
+	cluster, err := p.clusterForServiceRef(p.cfg.TracingService, "", p.cfg.TracingProvider == "opentelemetry", cache)
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("tracing service: %v", err), nil)
+		return nil
+	}
+	return &resources.Tracing{
+		Provider: p.cfg.TracingProvider,
+		Cluster:  cluster.Name,
+		Hostname: cluster.Hostname,
+		Sampling: p.cfg.TracingSampling,
+		Tags:     tags,
+	}
+}
+
+// applyTracingAnnotations overrides the sampling of the route and adds tags to its
+// spans.
+func (p *Parser) applyTracingAnnotations(obj client.Object, r *resources.Route) {
+	anns := obj.GetAnnotations()
+	t := &resources.RouteTracing{}
+	if v := annotations.ExtractTracingSampling(anns); v != "" {
+		sampling, err := strconv.ParseFloat(v, 64)
+		if err != nil || sampling < 0 || sampling > 100 {
+			p.registerTranslationFailure(fmt.Sprintf("invalid percentage %q in annotation %s%s", v, annotations.AnnotationPrefix, annotations.TracingSamplingKey), obj)
+		} else {
+			t.Sampling = &sampling
+		}
+	}
+	tags, err := parseTracingTags(splitList(annotations.ExtractTracingTags(anns)))
+	if err != nil {
+		p.registerTranslationFailure(fmt.Sprintf("invalid annotation %s%s: %v", annotations.AnnotationPrefix, annotations.TracingTagsKey, err), obj)
+	}
+	t.Tags = tags
+	if t.Sampling != nil || len(t.Tags) > 0 {
+		r.Tracing = t
+	}
+}
+
+func parseTracingTags(entries []string) ([]resources.TracingTag, error) {
+	var tags []resources.TracingTag
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return tags, fmt.Errorf("%q is not of the form name=value", entry)
+		}
+		tag := resources.TracingTag{Name: name, Literal: value}
+		if strings.HasPrefix(value, "header:") {
+			tag = resources.TracingTag{Name: name, Header: strings.TrimPrefix(value, "header:")}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubernetes-controller/internal/envoy/resources"
+	"reflect"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	collector := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tracing", Name: "collector"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "otlp", Port: 4317},
+			{Name: "zipkin", Port: 9411},
+		}},
+	}
+	tests := []struct {
+		name         string
+		cfg          Config
+		want         *resources.Tracing
+		wantHTTP2    bool
+		wantFailures []string
+	}{
+		{name: "off"},
+		{
+			name: "OpenTelemetry",
+			cfg:  Config{TracingProvider: "opentelemetry", TracingService: "tracing/collector:otlp", TracingSampling: 10, TracingTags: []string{"cluster=eu", "tenant=header:X-Tenant"}},
+			want: &resources.Tracing{
+				Provider: "opentelemetry",
+				Cluster:  "tracing/collector/4317/http2",
+				Hostname: "collector.tracing.svc.cluster.local",
+				Sampling: 10,
+				Tags: []resources.TracingTag{
+					{Name: "cluster", Literal: "eu"},
+					{Name: "tenant", Header: "X-Tenant"},
+				},
+			},
+			wantHTTP2: true,
+		},
+		{
+			name: "Zipkin",
+			cfg:  Config{TracingProvider: "zipkin", TracingService: "tracing/collector:zipkin", TracingSampling: 100},
+			want: &resources.Tracing{Provider: "zipkin", Cluster: "tracing/collector/9411", Hostname: "collector.tracing.svc.cluster.local", Sampling: 100},
+		},
+		{
+			name:         "invalid provider",
+			cfg:          Config{TracingProvider: "jaeger", TracingService: "tracing/collector:otlp"},
+			wantFailures: []string{`invalid tracing provider "jaeger"`},
+		},
+		{
+			name:         "invalid tag",
+			cfg:          Config{TracingProvider: "zipkin", TracingService: "tracing/collector:zipkin", TracingTags: []string{"cluster=eu", "tenant"}},
+			want:         &resources.Tracing{Provider: "zipkin", Cluster: "tracing/collector/9411", Hostname: "collector.tracing.svc.cluster.local", Tags: []resources.TracingTag{{Name: "cluster", Literal: "eu"}}},
+			wantFailures: []string{`tracing tags: "tenant" is not of the form name=value`},
+		},
+		{
+			name:         "missing service",
+			cfg:          Config{TracingProvider: "zipkin", TracingService: "tracing/missing:9411"},
+			wantFailures: []string{"tracing service: Service tracing/missing not found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.GenerateRequestID = true
+			p := newTestParser(t, tt.cfg, collector)
+			cache := p.Build()
+			assertFailures(t, p, tt.wantFailures...)
+			l := cache.Listeners["listener_0"]
+			if !reflect.DeepEqual(l.Tracing, tt.want) {
+				t.Errorf("got tracing %+v, want %+v", l.Tracing, tt.want)
+			}
+			if !l.GenerateRequestID || l.PreserveExternalRequestID {
+				t.Errorf("listener generates request IDs %v and preserves external ones %v, want true and false", l.GenerateRequestID, l.PreserveExternalRequestID)
+			}
+			if tt.want != nil && cache.Clusters[tt.want.Cluster].HTTP2 != tt.wantHTTP2 {
+				t.Errorf("collector cluster HTTP/2 %v, want %v", cache.Clusters[tt.want.Cluster].HTTP2, tt.wantHTTP2)
+			}
+		})
+	}
+}
+
+func TestApplyTracingAnnotations(t *testing.T) {
+	half := 50.0
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		want         *resources.RouteTracing
+		wantFailures []string
+	}{
+		{name: "none"},
+		{
+			name:        "sampling",
+			annotations: map[string]string{"inendless.com/tracing-sampling": "50"},
+			want:        &resources.RouteTracing{Sampling: &half},
+		},
+		{
+			name:        "tags",
+			annotations: map[string]string{"inendless.com/tracing-tags": "team=payments, user=header:X-User"},
+			want: &resources.RouteTracing{Tags: []resources.TracingTag{
+				{Name: "team", Literal: "payments"},
+				{Name: "user", Header: "X-User"},
+			}},
+		},
+		{
+			name:         "invalid sampling",
+			annotations:  map[string]string{"inendless.com/tracing-sampling": "150"},
+			wantFailures: []string{`invalid percentage "150" in annotation inendless.com/tracing-sampling`},
+		},
+		{
+			name:         "invalid tag",
+			annotations:  map[string]string{"inendless.com/tracing-tags": "=payments"},
+			wantFailures: []string{"invalid annotation inendless.com/tracing-tags"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tt.annotations}}
+			p := newTestParser(t, Config{})
+			var r resources.Route
+			p.applyTracingAnnotations(ing, &r)
+			if !reflect.DeepEqual(r.Tracing, tt.want) {
+				t.Errorf("got tracing %+v, want %+v", r.Tracing, tt.want)
+			}
+			assertFailures(t, p, tt.wantFailures...)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	"testing"
+)
+
+func TestWebSocket(t *testing.T) {
+	tests := []struct {
+		name        string
+		appProtocol string
+		annotation  string
+		want        bool
+		wantTLS     bool
+	}{
+		{name: "http port"},
+		{name: "ws port", appProtocol: "kubernetes.io/ws", want: true},
+		{name: "wss port", appProtocol: "wss", want: true, wantTLS: true},
+		{name: "allowed by annotation", annotation: "true", want: true},
+		{name: "forbidden by annotation", appProtocol: "ws", annotation: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := testService()
+			if tt.appProtocol != "" {
+				svc.Spec.Ports[0].AppProtocol = stringPtr(tt.appProtocol)
+			}
+			var anns map[string]string
+			if tt.annotation != "" {
+				anns = map[string]string{"inendless.com/websocket": tt.annotation}
+			}
+			p := newTestParser(t, Config{}, svc, testIngress("web", "web.example.com", anns))
+			cache := p.Build()
+			assertFailures(t, p)
+			if got := cache.Routes["default/web/0/0"].WebSocket; got != tt.want {
+				t.Errorf("route allows WebSocket %v, want %v", got, tt.want)
+			}
+			if got := cache.Clusters["default/web/80"].UpstreamTLS != nil; got != tt.wantTLS {
+				t.Errorf("cluster originates TLS %v, want %v", got, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestAllowConnect(t *testing.T) {
+	ing := testIngress("web", "web.example.com", map[string]string{"inendless.com/allow-connect": "true"})
+	ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, netv1.HTTPIngressPath{
+		Path:     "/api",
+		PathType: ing.Spec.Rules[0].HTTP.Paths[0].PathType,
+		Backend:  ing.Spec.Rules[0].HTTP.Paths[0].Backend,
+	})
+	p := newTestParser(t, Config{}, testService(), ing, testIngress("other", "other.example.com", nil))
+	cache := p.Build()
+	assertFailures(t, p)
+
+	r, ok := cache.Routes["default/web/0/connect"]
+	if !ok {
+		t.Fatal("no CONNECT route of the Ingress")
+	}
+	if !r.Connect || r.Host != "web.example.com" || r.Cluster != "default/web/80" {
+		t.Errorf("got route %+v, want a CONNECT route of web.example.com to default/web/80", r)
+	}
+	if _, ok := cache.Routes["default/other/0/connect"]; ok {
+		t.Error("got a CONNECT route of an Ingress without the annotation")
+	}
+	if !cache.Listeners["listener_0"].Connect {
+		t.Error("the listener does not allow CONNECT")
+	}
+
+	ing.Spec.Rules[0].HTTP.Paths[1].Backend = netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+		Name: "api", Port: netv1.ServiceBackendPort{Number: 80},
+	}}
+	p = newTestParser(t, Config{}, testService(), ing)
+	cache = p.Build()
+	assertFailures(t, p, "rule 0: CONNECT needs the paths of the rule to share one backend", "Service default/api not found")
+	if _, ok := cache.Routes["default/web/0/connect"]; ok {
+		t.Error("got a CONNECT route of a rule with several backends")
+	}
+}
@@ -0,0 +1,168 @@
+package resources
+
+import (
+	"fmt"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"strings"
+)
+
+// basicAuthScript checks the Authorization header of the request against the users
+// and realm declared before it. LuaJIT has no hash functions of its own, so SHA-1 is
+// implemented with the bit library. Hashes are compared in constant time, and the
+// password of an unknown user is hashed all the same, so the time of the check does
+// not tell how much of a hash matched or which users exist.
+const basicAuthScript = `
+local bit = require("bit")
+local band, bor, bxor, bnot, lshift, rshift, rol, tobit =
+  bit.band, bit.bor, bit.bxor, bit.bnot, bit.lshift, bit.rshift, bit.rol, bit.tobit
+
+local function sha1(msg)
+  local h0, h1, h2, h3, h4 = 0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476, 0xC3D2E1F0
+  local bits = #msg * 8
+  msg = msg .. "\128" .. string.rep("\0", (55 - #msg) % 64) .. "\0\0\0\0" ..
+    string.char(band(rshift(bits, 24), 255), band(rshift(bits, 16), 255),
+      band(rshift(bits, 8), 255), band(bits, 255))
+  for chunk = 1, #msg, 64 do
+    local w = {}
+    for i = 0, 15 do
+      local a, b, c, d = msg:byte(chunk + i * 4, chunk + i * 4 + 3)
+      w[i] = bor(lshift(a, 24), lshift(b, 16), lshift(c, 8), d)
+    end
+    for i = 16, 79 do
+      w[i] = rol(bxor(w[i - 3], w[i - 8], w[i - 14], w[i - 16]), 1)
+    end
+    local a, b, c, d, e = h0, h1, h2, h3, h4
+    for i = 0, 79 do
+      local f, k
+      if i < 20 then
+        f, k = bor(band(b, c), band(bnot(b), d)), 0x5A827999
+      elseif i < 40 then
+        f, k = bxor(b, c, d), 0x6ED9EBA1
+      elseif i < 60 then
+        f, k = bor(band(b, c), band(b, d), band(c, d)), 0x8F1BBCDC
+      else
+        f, k = bxor(b, c, d), 0xCA62C1D6
+      end
+      a, b, c, d, e = tobit(rol(a, 5) + f + e + k + w[i]), a, rol(b, 30), c, d
+    end
+    h0, h1, h2, h3, h4 = tobit(h0 + a), tobit(h1 + b), tobit(h2 + c), tobit(h3 + d), tobit(h4 + e)
+  end
+  local digest = ""
+  for _, h in ipairs({h0, h1, h2, h3, h4}) do
+    digest = digest .. string.char(band(rshift(h, 24), 255), band(rshift(h, 16), 255),
+      band(rshift(h, 8), 255), band(h, 255))
+  end
+  return digest
+end
+
+local function equal(a, b)
+  if #a ~= #b then
+    return false
+  end
+  local diff = 0
+  for i = 1, #a do
+    diff = bor(diff, bxor(a:byte(i), b:byte(i)))
+  end
+  return diff == 0
+end
+
+local alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+local function base64decode(s)
+  local out, buffer, n = {}, 0, 0
+  for i = 1, #s do
+    local c = s:sub(i, i)
+    if c == "=" then
+      break
+    end
+    local v = alphabet:find(c, 1, true)
+    if v == nil then
+      return nil
+    end
+    buffer, n = bor(lshift(buffer, 6), v - 1), n + 6
+    if n >= 8 then
+      n = n - 8
+      out[#out + 1] = string.char(band(rshift(buffer, n), 255))
+    end
+  end
+  return table.concat(out)
+end
+
+function envoy_on_request(handle)
+  local authorization = handle:headers():get("authorization")
+  if authorization ~= nil then
+    local scheme, encoded = authorization:match("^(%S+)%s+(%S+)$")
+    if scheme ~= nil and scheme:lower() == "basic" then
+      local credentials = base64decode(encoded)
+      local user, password
+      if credentials ~= nil then
+        user, password = credentials:match("^([^:]*):(.*)$")
+      end
+      if user ~= nil then
+        local hash = handle:base64Escape(sha1(password))
+        local known = users[user] ~= nil
+        if equal(users[user] or hash, hash) and known then
+          return
+        end
+      end
+    end
+  end
+  handle:respond({
+    [":status"] = "401",
+    ["www-authenticate"] = "Basic realm=\"" .. realm .. "\"",
+  }, "Unauthorized")
+end
+`
+
+// makeBasicAuth holds the script of every user list, selected by name through
+// makeBasicAuthPerRoute. The default script does nothing.
+func makeBasicAuth(auths []BasicAuth) *lua.Lua {
+	config := &lua.Lua{
+		InlineCode:  "function envoy_on_request(handle) end",
+		SourceCodes: map[string]*core.DataSource{},
+	}
+	for _, a := range auths {
+		var code strings.Builder
+		code.WriteString("local realm = " + luaString(a.Realm) + "\nlocal users = {\n")
+		for _, u := range a.Users {
+			code.WriteString("  [" + luaString(u.Name) + "] = " + luaString(u.PasswordSHA1) + ",\n")
+		}
+		code.WriteString("}\n" + basicAuthScript)
+		config.SourceCodes[a.Name] = &core.DataSource{
+			Specifier: &core.DataSource_InlineString{InlineString: code.String()},
+		}
+	}
+	return config
+}
+
+func makeBasicAuthPerRoute(a *BasicAuth) *any.Any {
+	config := &lua.LuaPerRoute{Override: &lua.LuaPerRoute_Disabled{Disabled: true}}
+	if a != nil {
+		config.Override = &lua.LuaPerRoute_Name{Name: a.Name}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+// luaString quotes s as a Lua string literal, escaping every byte outside printable
+// ASCII as a decimal escape.
+func luaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			fmt.Fprintf(&b, "\\%03d", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
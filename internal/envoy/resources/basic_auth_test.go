@@ -0,0 +1,63 @@
+package resources
+
+import (
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"strings"
+	"testing"
+)
+
+func TestLuaString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "alice", want: `"alice"`},
+		{in: `say "hi"`, want: `"say \034hi\034"`},
+		{in: `a\b`, want: `"a\092b"`},
+		{in: "line\nbreak", want: `"line\010break"`},
+		{in: "café", want: `"caf\195\169"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := luaString(tt.in); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeBasicAuth(t *testing.T) {
+	staff := BasicAuth{
+		Name:  "default.staff",
+		Realm: "Staff",
+		Users: []BasicAuthUser{{Name: "alice", PasswordSHA1: "W6ph5Mm5Pz8GgiULbPgzG37mj9g="}},
+	}
+	config := makeBasicAuth([]BasicAuth{staff})
+	code := config.SourceCodes["default.staff"].GetInlineString()
+	for _, want := range []string{
+		`local realm = "Staff"`,
+		`["alice"] = "W6ph5Mm5Pz8GgiULbPgzG37mj9g="`,
+		"function envoy_on_request(handle)",
+		// hashes are compared in constant time
+		"equal(users[user] or hash, hash)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("script of default.staff has no %s", want)
+		}
+	}
+
+	l := Listener{Name: "listener_0", BasicAuth: []BasicAuth{staff}}
+	perRoute := &lua.LuaPerRoute{}
+	if err := makeRoute(l, Route{Prefix: "/", BasicAuth: &staff}).TypedPerFilterConfig["envoy.filters.http.lua"].UnmarshalTo(perRoute); err != nil {
+		t.Fatal(err)
+	}
+	if perRoute.GetName() != "default.staff" {
+		t.Errorf("got per-route config %v, want the default.staff script", perRoute)
+	}
+	if err := makeRoute(l, Route{Prefix: "/"}).TypedPerFilterConfig["envoy.filters.http.lua"].UnmarshalTo(perRoute); err != nil {
+		t.Fatal(err)
+	}
+	if !perRoute.GetDisabled() {
+		t.Errorf("got per-route config %v of an open route, want disabled", perRoute)
+	}
+}
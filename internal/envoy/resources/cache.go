@@ -1,21 +1,471 @@
 package resources
 
+import (
+	"fmt"
+	"time"
+)
+
 type Listener struct {
+	Name            string
+	Address         string
+	Port            uint32
+	RouteNames      []string
+	TLSFilterChains []TLSFilterChain
+	// ProxyProtocol expects connections to start with a PROXY protocol header, whose
+	// source address becomes the peer address of the connection.
+	ProxyProtocol bool
+	// UseRemoteAddress takes the peer address of the connection as the client address
+	// rather than the last X-Forwarded-For entry.
+	UseRemoteAddress bool
+	// Tracing reports spans of the requests of the listener to a collector.
+	Tracing *Tracing
+	// GenerateRequestID sets x-request-id on requests without one, while
+	// PreserveExternalRequestID keeps the x-request-id of external clients.
+	GenerateRequestID         bool
+	PreserveExternalRequestID bool
+	// Connect lets routes terminate CONNECT tunnels, including the extended CONNECT of
+	// HTTP/2.
+	Connect bool
+	// Compression lists the algorithms, gzip or brotli, of the compressors installed
+	// for the routes that compress their responses.
+	Compression []string
+	// Buffering adds the buffer filter enforcing the request limits of the routes.
+	Buffering bool
+	// MaxResponseBytes is the largest response limit of the routes, up to which the
+	// connections of the listener buffer. Responses are not buffered when zero.
+	MaxResponseBytes uint32
+	// AccessLogs are written for every request of the listener.
+	AccessLogs []AccessLog
+	// XFFNumTrustedHops is the number of proxies in front of Envoy whose
+	// X-Forwarded-For entries are trusted when finding the client address.
+	XFFNumTrustedHops uint32
+	// IPFiltering adds the RBAC filter enforcing the IP filters of the routes.
+	IPFiltering bool
+	// RateLimitService enables global rate limiting of the routes with descriptors.
+	RateLimitService *RateLimitService
+	// ExtAuthz has an ext_authz filter for every authorization service used by the
+	// routes; each filter is disabled on the routes it does not guard.
+	ExtAuthz []ExtAuthz
+	// JWTProviders are the token issuers the routes may require.
+	JWTProviders []JWTProvider
+	// BasicAuth has the user lists the routes may check credentials against.
+	BasicAuth []BasicAuth
+	// WeightedDirectResponses adds the filter answering the shares of the weighted
+	// clusters of the routes that have no backend.
+	WeightedDirectResponses bool
+	// ErrorPageRoutes are the routes with custom error pages, which replace the
+	// bodies of the local replies Envoy sends for their requests, and of the
+	// responses of their backends.
+	ErrorPageRoutes []Route
+}
+
+// Origin identifies the Kubernetes object a resource was generated from.
+type Origin struct {
+	Kind      string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// Tracing reports spans to the collector of Cluster, speaking the protocol of
+// Provider, opentelemetry or zipkin. Sampling is the percentage of requests traced.
+type Tracing struct {
+	Provider string
+	Cluster  string
+	// Hostname is the Host header of the spans sent to a Zipkin collector.
+	Hostname string
+	Sampling float64
+	Tags     []TracingTag
+}
+
+// TracingTag adds a tag to spans, with a Literal value or the value of the request
+// Header.
+type TracingTag struct {
+	Name    string
+	Literal string
+	Header  string
+}
+
+// RouteTracing overrides the sampling of the listener when Sampling is set, and adds
+// Tags to the spans of the route.
+type RouteTracing struct {
+	Sampling *float64
+	Tags     []TracingTag
+}
+
+// AccessLog writes a line per request to the file at Path, such as /dev/stdout, or
+// sends it to the gRPC access log service of GRPCCluster. File logs are formatted
+// with TextFormat, or JSONFormat when it is set.
+type AccessLog struct {
+	Path        string
+	TextFormat  string
+	JSONFormat  map[string]string
+	GRPCCluster string
+}
+
+// IPFilter restricts a route to clients whose address is in one of the Allow ranges,
+// when there are any, and in none of the Deny ranges.
+type IPFilter struct {
+	Allow []CIDR
+	Deny  []CIDR
+}
+
+type CIDR struct {
+	Address   string
+	PrefixLen uint32
+}
+
+// BasicAuth checks the credentials of requests against a list of users.
+type BasicAuth struct {
+	Name  string
+	Realm string
+	Users []BasicAuthUser
+}
+
+// BasicAuthUser is a user with the base64 encoded SHA-1 digest of its password, as
+// stored by htpasswd -s.
+type BasicAuthUser struct {
+	Name         string
+	PasswordSHA1 string
+}
+
+// JWTProvider validates the JSON Web Tokens of one issuer against a JWKS fetched from
+// RemoteJWKS or given inline as LocalJWKS.
+type JWTProvider struct {
 	Name       string
-	Address    string
-	Port       uint32
-	RouteNames []string
+	Issuer     string
+	Audiences  []string
+	RemoteJWKS *RemoteJWKS
+	LocalJWKS  string
+	// Forward keeps the token in the request sent to the backend.
+	Forward bool
+}
+
+type RemoteJWKS struct {
+	URI           string
+	Cluster       string
+	CacheDuration time.Duration
 }
+
+// ExtAuthz checks requests against an external authorization service over gRPC or,
+// when PathPrefix is set or GRPC is false, HTTP.
+type ExtAuthz struct {
+	// Name of the HTTP filter, unique per listener.
+	Name             string
+	Cluster          string
+	GRPC             bool
+	PathPrefix       string
+	Timeout          time.Duration
+	FailureModeAllow bool
+	// RequestHeaders are the client headers sent to an HTTP authorization service.
+	RequestHeaders []string
+	// UpstreamHeaders are the headers of the authorization response added to the
+	// request forwarded to the backend.
+	UpstreamHeaders []string
+}
+
+// RateLimitService is an external service speaking the Envoy RLS gRPC protocol.
+type RateLimitService struct {
+	Cluster         string
+	Domain          string
+	FailureModeDeny bool
+}
+
+// TLSFilterChain terminates TLS for the server names with the certificate of the
+// named SDS secret. A chain without server names matches any other name.
+type TLSFilterChain struct {
+	ServerNames      []string
+	Certificate      string
+	ClientValidation *ClientValidation
+}
+
+// ClientValidation requires clients to present a certificate signed by the CA. Mode
+// is one of on, optional or optional_no_ca.
+type ClientValidation struct {
+	CACert      []byte
+	CRL         []byte
+	Mode        string
+	VerifyDepth uint32
+}
+type HashPolicy struct {
+	Header string
+	Cookie string
+	// CookieTTL makes Envoy generate the cookie when the request has none.
+	CookieTTL time.Duration
+	SourceIP  bool
+}
+
+type RetryPolicy struct {
+	// RetryOn is a comma separated list of Envoy retry conditions, such as 5xx or reset.
+	RetryOn              string
+	NumRetries           uint32
+	PerTryTimeout        time.Duration
+	BackoffBase          time.Duration
+	BackoffMax           time.Duration
+	HedgeOnPerTryTimeout bool
+}
+
+type HSTS struct {
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// String returns the value of the Strict-Transport-Security header.
+func (h HSTS) String() string {
+	value := fmt.Sprintf("max-age=%d", int64(h.MaxAge.Seconds()))
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+type Header struct {
+	Name string
+	// Value may contain Envoy command operators such as %DOWNSTREAM_REMOTE_ADDRESS%.
+	Value string
+}
+
+// HeaderPolicy appends the Add headers, overwrites the Set headers and removes the
+// Remove headers.
+type HeaderPolicy struct {
+	Add    []Header
+	Set    []Header
+	Remove []string
+}
+
+func (h HeaderPolicy) IsEmpty() bool {
+	return len(h.Add) == 0 && len(h.Set) == 0 && len(h.Remove) == 0
+}
+
+// LocalRateLimit is a token bucket shared by the requests of a route on each Envoy.
+type LocalRateLimit struct {
+	MaxTokens     uint32
+	TokensPerFill uint32
+	FillInterval  time.Duration
+}
+
+// RateLimitDescriptor is an entry of the descriptor sent to the rate limit service.
+// Kind is one of remote_address, header or path.
+type RateLimitDescriptor struct {
+	Kind   string
+	Header string
+}
+
+type CorsPolicy struct {
+	AllowOrigins       []string
+	AllowOriginRegexes []string
+	AllowMethods       []string
+	AllowHeaders       []string
+	ExposeHeaders      []string
+	MaxAge             time.Duration
+	AllowCredentials   bool
+}
+
+// RegexRewrite replaces the parts of the path matching the RE2 Pattern with the
+// Substitution, which may refer to capture groups as \1.
+type RegexRewrite struct {
+	Pattern      string
+	Substitution string
+}
+
+// NoTimeout disables a timeout of a route, rather than keeping Envoy's default.
+const NoTimeout time.Duration = -1
+
 type Route struct {
-	Name    string
-	Prefix  string
-	Cluster string
+	Name string
+	Host string
+	// Origin is the object the route was generated from, kept in the route metadata
+	// under MetadataNamespace.
+	Origin *Origin
+	// Connect matches CONNECT requests instead of a path, tunneling their payload to
+	// the cluster.
+	Connect bool
+	// Only one of Path, PathSeparatedPrefix, PathRegex and Prefix is matched, in that
+	// order. PathRegex is an RE2 pattern matching the whole path.
+	Path                string
+	PathSeparatedPrefix string
+	PathRegex           string
+	Prefix              string
+	// Method, HeaderMatches and QueryParamMatches must all match besides the path.
+	Method            string
+	HeaderMatches     []ValueMatch
+	QueryParamMatches []ValueMatch
+	Cluster           string
+	// WeightedClusters split the requests of the route across several clusters, in
+	// place of Cluster.
+	WeightedClusters []WeightedCluster
+	HashPolicy       *HashPolicy
+	// Timeout and IdleTimeout keep Envoy's defaults when zero, and are disabled when
+	// NoTimeout.
+	Timeout     time.Duration
+	IdleTimeout time.Duration
+	RetryPolicy *RetryPolicy
+	// Only one of RegexRewrite, FullPathRewrite and PrefixRewrite is applied, in that
+	// order. PrefixRewrite replaces the matched prefix like HTTPRoute's ReplacePrefixMatch.
+	RegexRewrite    *RegexRewrite
+	FullPathRewrite string
+	PrefixRewrite   string
+	HostRewrite     string
+	RequestHeaders  HeaderPolicy
+	ResponseHeaders HeaderPolicy
+	// VirtualHostRequestHeaders and VirtualHostResponseHeaders apply to every route of
+	// the host; the first route of the host that sets them wins.
+	VirtualHostRequestHeaders  HeaderPolicy
+	VirtualHostResponseHeaders HeaderPolicy
+	LocalRateLimit             *LocalRateLimit
+	ExtAuthz                   *ExtAuthz
+	// JWT is the provider whose token the route requires.
+	JWT       *JWTProvider
+	BasicAuth *BasicAuth
+	IPFilter  *IPFilter
+	Tracing   *RouteTracing
+	// WebSocket allows requests of the route to upgrade to WebSocket.
+	WebSocket bool
+	// Compression lists the algorithms the responses of the route may be compressed
+	// with; the responses of routes without any are left uncompressed.
+	Compression []string
+	// MaxRequestBytes buffers whole requests up to the limit, rejecting larger ones
+	// with a 413.
+	MaxRequestBytes uint32
+	// MaxResponseBytes buffers whole responses up to the limit, replacing larger ones
+	// with a 502.
+	MaxResponseBytes uint32
+	// RateLimitDescriptors are sent to the rate limit service of the listener.
+	RateLimitDescriptors []RateLimitDescriptor
+	// VirtualHostCors applies to the host like the virtual host headers.
+	VirtualHostCors *CorsPolicy
+	// HTTPSRedirectCode, 301 or 308, redirects plaintext requests to HTTPS.
+	HTTPSRedirectCode uint32
+	HSTS              *HSTS
+	// Scheme, http or https, limits the route to the plaintext or to the TLS
+	// listeners. Routes without one are served by both.
+	Scheme string
+	// DirectResponseStatus answers the request without a backend when set.
+	DirectResponseStatus uint32
+	DirectResponseBody   string
+	// ErrorPages replace the body of the local replies and of the backend responses
+	// with their status code.
+	ErrorPages []ErrorPage
+}
+
+// ValueMatch matches a header or query parameter whose value is Value, or matches
+// the RE2 pattern Value when Regex is set.
+type ValueMatch struct {
+	Name  string
+	Value string
+	Regex bool
+}
+
+// WeightedCluster receives Weight shares of the requests of a route. Its share is
+// answered without a backend when DirectResponseStatus is set, in place of Name.
+type WeightedCluster struct {
+	Name                 string
+	Weight               uint32
+	DirectResponseStatus uint32
+}
+
+// ErrorPage is the Body of the responses with StatusCode, sent as ContentType.
+type ErrorPage struct {
+	StatusCode  uint32
+	Body        string
+	ContentType string
 }
+type ClusterType string
+
+const (
+	EDSCluster        ClusterType = ""
+	StrictDNSCluster  ClusterType = "strict_dns"
+	LogicalDNSCluster ClusterType = "logical_dns"
+)
+
 type Cluster struct {
-	Name      string
+	Name string
+	// Hostname is the DNS name of the Service, sent as the Host header of HTTP health
+	// checks since the cluster name is not a valid one.
+	Hostname string
+	// Origin is the Service the cluster was generated from, kept in the cluster
+	// metadata under MetadataNamespace.
+	Origin    *Origin
+	Type      ClusterType
 	Endpoints []Endpoint
+	// DNSLookupFamily is one of auto, v4_only, v6_only, v4_preferred or all.
+	DNSLookupFamily string
+	// LbPolicy is one of round_robin, least_request, random, ring_hash or maglev.
+	LbPolicy                 string
+	ConnectTimeout           time.Duration
+	MaxRequestsPerConnection uint32
+	CircuitBreakers          CircuitBreakers
+	HealthCheck              *HealthCheck
+	OutlierDetection         *OutlierDetection
+	UpstreamTLS              *UpstreamTLS
+	// HTTP2 talks HTTP/2 to the endpoints, as gRPC backends require.
+	HTTP2 bool
+	// HashPolicy is applied to the routes targeting a ring_hash or maglev cluster.
+	HashPolicy *HashPolicy
+	// WebSocket is set for ws and wss Service ports, whose routes allow WebSocket
+	// upgrades.
+	WebSocket bool
+	// LocalityWeightedLb balances across localities by their weights instead of
+	// Envoy's default zone-aware routing.
+	LocalityWeightedLb bool
+	// ZoneAwareLb keeps requests in the zone of the proxy, as far as the endpoints of
+	// that zone can take the share of the proxies in it, counted from the endpoints
+	// of the LocalClusterName cluster.
+	ZoneAwareLb bool
+}
+
+// CircuitBreakers holds the thresholds of the default priority, zero values keep
+// Envoy's defaults.
+type CircuitBreakers struct {
+	MaxConnections     uint32
+	MaxPendingRequests uint32
+	MaxRequests        uint32
+	MaxRetries         uint32
 }
+
+// HealthCheck actively probes the endpoints; Protocol is one of http, grpc or tcp.
+type HealthCheck struct {
+	Protocol           string
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	HealthyThreshold   uint32
+	UnhealthyThreshold uint32
+}
+
+// OutlierDetection ejects endpoints returning consecutive 5xx responses.
+type OutlierDetection struct {
+	Consecutive5xx     uint32
+	Interval           time.Duration
+	BaseEjectionTime   time.Duration
+	MaxEjectionPercent uint32
+}
+
+// UpstreamTLS originates TLS to the endpoints. The server certificate is only
+// verified when a CA is given, and its name must then match SNI.
+type UpstreamTLS struct {
+	SNI    string
+	CACert []byte
+	// CAFile is a CA bundle on the Envoy filesystem, used when CACert is empty.
+	CAFile string
+	// ClientCertificate is presented to the endpoints for mTLS, delivered over SDS.
+	ClientCertificate *Secret
+}
+
+// Secret is a TLS certificate served to Envoy through SDS.
+type Secret struct {
+	Name        string
+	Certificate []byte
+	PrivateKey  []byte
+}
+
 type Endpoint struct {
 	UpstreamHost string
 	UpstreamPort uint32
+	Region       string
+	Zone         string
 }
@@ -0,0 +1,154 @@
+package resources
+
+import (
+	"fmt"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	brotli "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/brotli/compressor/v3"
+	gzip "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	compressor "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"strings"
+)
+
+const (
+	compressorFilterPrefix = "envoy.filters.http.compressor."
+	// compressionGuardFilter hides from the compressors the encodings of
+	// Accept-Encoding the route does not compress with, as this version of the
+	// compressor filter takes no per-route config. The header is kept in the dynamic
+	// metadata of the filter and put back on the request by the route.
+	compressionGuardFilter = "envoy.filters.http.lua.compression"
+	// compressionGuardScriptPrefix names the guard script of each set of algorithms a
+	// route compresses with, followed by the algorithms.
+	compressionGuardScriptPrefix = "compress:"
+)
+
+var compressionLibraries = map[string]struct {
+	name string
+	// encoding is the content coding of the algorithm in Accept-Encoding.
+	encoding string
+	config   proto.Message
+}{
+	"gzip":   {name: "envoy.compression.gzip.compressor", encoding: "gzip", config: &gzip.Gzip{}},
+	"brotli": {name: "envoy.compression.brotli.compressor", encoding: "br", config: &brotli.Brotli{}},
+}
+
+// compressionGuardCode keeps the codings of Accept-Encoding found in the allowed
+// table, formatted in along with the name of the filter, and identity.
+const compressionGuardCode = `
+local allowed = {%s}
+
+function envoy_on_request(handle)
+  local headers = handle:headers()
+  local encoding = headers:get("accept-encoding")
+  if encoding == nil then
+    return
+  end
+  handle:streamInfo():dynamicMetadata():set(%q, "accept-encoding", encoding)
+  local kept = {}
+  for coding in string.gmatch(encoding, "[^,]+") do
+    local name = string.lower(string.match(coding, "^%%s*([^;%%s]*)"))
+    if allowed[name] or name == "identity" then
+      table.insert(kept, coding)
+    end
+  end
+  if #kept == 0 then
+    headers:remove("accept-encoding")
+  else
+    headers:replace("accept-encoding", table.concat(kept, ","))
+  end
+end
+`
+
+// makeCompressors returns the compressor filters of the algorithms, preceded by the
+// guard restricting the routes that compress with only some of them, or none.
+func makeCompressors(algorithms []string) []*hcm.HttpFilter {
+	guard := &lua.Lua{
+		InlineCode:  "function envoy_on_request(handle) end",
+		SourceCodes: map[string]*core.DataSource{},
+	}
+	// a script for every subset of the algorithms but the whole set, which needs
+	// no guard
+	for set := 0; set < 1<<len(algorithms)-1; set++ {
+		var subset, encodings []string
+		for i, algorithm := range algorithms {
+			if set&(1<<i) != 0 {
+				subset = append(subset, algorithm)
+				encodings = append(encodings, fmt.Sprintf("[%q] = true", compressionLibraries[algorithm].encoding))
+			}
+		}
+		guard.SourceCodes[compressionGuardScript(subset)] = &core.DataSource{
+			Specifier: &core.DataSource_InlineString{
+				InlineString: fmt.Sprintf(compressionGuardCode, strings.Join(encodings, ", "), compressionGuardFilter),
+			},
+		}
+	}
+
+	filters := []*hcm.HttpFilter{makeHTTPFilter(compressionGuardFilter, guard)}
+	for _, algorithm := range algorithms {
+		library, ok := compressionLibraries[algorithm]
+		if !ok {
+			continue
+		}
+		pbst, err := ptypes.MarshalAny(library.config)
+		if err != nil {
+			panic(err)
+		}
+		filters = append(filters, makeHTTPFilter(compressorFilterPrefix+algorithm, &compressor.Compressor{
+			CompressorLibrary: &core.TypedExtensionConfig{
+				Name:        library.name,
+				TypedConfig: pbst,
+			},
+			ResponseDirectionConfig: &compressor.Compressor_ResponseDirectionConfig{},
+		}))
+	}
+	return filters
+}
+
+func compressionGuardScript(algorithms []string) string {
+	return compressionGuardScriptPrefix + strings.Join(algorithms, ",")
+}
+
+// routeCompression returns the algorithms of the listener the route compresses with,
+// in the order of the listener, and whether these are all of them.
+func routeCompression(l Listener, r Route) ([]string, bool) {
+	var algorithms []string
+	for _, algorithm := range l.Compression {
+		for _, a := range r.Compression {
+			if a == algorithm {
+				algorithms = append(algorithms, algorithm)
+				break
+			}
+		}
+	}
+	return algorithms, len(algorithms) == len(l.Compression)
+}
+
+// makeCompressionGuardPerRoute runs the guard of the algorithms of the route, unless
+// it compresses with all the algorithms of the listener.
+func makeCompressionGuardPerRoute(l Listener, r Route) *any.Any {
+	config := &lua.LuaPerRoute{Override: &lua.LuaPerRoute_Disabled{Disabled: true}}
+	if algorithms, all := routeCompression(l, r); !all {
+		config.Override = &lua.LuaPerRoute_Name{Name: compressionGuardScript(algorithms)}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+// restoreAcceptEncoding puts the Accept-Encoding header restricted by the compression
+// guard back on the request sent to the backend.
+func restoreAcceptEncoding() *core.HeaderValueOption {
+	return &core.HeaderValueOption{
+		Header: &core.HeaderValue{
+			Key:   "accept-encoding",
+			Value: fmt.Sprintf(`%%DYNAMIC_METADATA(["%s", "accept-encoding"])%%`, compressionGuardFilter),
+		},
+		AppendAction: core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+	}
+}
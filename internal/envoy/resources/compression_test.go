@@ -0,0 +1,67 @@
+package resources
+
+import (
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCompressionGuard(t *testing.T) {
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, Compression: []string{"brotli", "gzip"}}
+
+	var names []string
+	for _, f := range makeCompressors(l.Compression) {
+		names = append(names, f.Name)
+	}
+	want := []string{compressionGuardFilter, "envoy.filters.http.compressor.brotli", "envoy.filters.http.compressor.gzip"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got filters %v, want %v", names, want)
+	}
+
+	guard := &lua.Lua{}
+	if err := makeCompressors(l.Compression)[0].GetTypedConfig().UnmarshalTo(guard); err != nil {
+		t.Fatal(err)
+	}
+	var scripts []string
+	for name := range guard.SourceCodes {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	if want := []string{"compress:", "compress:brotli", "compress:gzip"}; !reflect.DeepEqual(scripts, want) {
+		t.Errorf("got guard scripts %v, want %v", scripts, want)
+	}
+
+	tests := []struct {
+		name        string
+		compression []string
+		script      string
+	}{
+		{name: "all", compression: []string{"gzip", "brotli"}},
+		{name: "gzip only", compression: []string{"gzip"}, script: "compress:gzip"},
+		{name: "brotli only", compression: []string{"brotli"}, script: "compress:brotli"},
+		{name: "off", script: "compress:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := makeRoute(l, Route{Name: "r", Prefix: "/", Cluster: "default/web/80", Compression: tt.compression})
+			config := &lua.LuaPerRoute{}
+			if err := rt.TypedPerFilterConfig[compressionGuardFilter].UnmarshalTo(config); err != nil {
+				t.Fatal(err)
+			}
+			restored := false
+			for _, h := range rt.RequestHeadersToAdd {
+				restored = restored || h.Header.Key == "accept-encoding"
+			}
+			if tt.script == "" {
+				if !config.GetDisabled() || restored {
+					t.Errorf("the guard runs on a route compressing with every algorithm: %v", config)
+				}
+				return
+			}
+			if config.GetName() != tt.script || !restored {
+				t.Errorf("got guard %v, restoring Accept-Encoding %v, want script %s", config, restored, tt.script)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package resources
+
+import (
+	"fmt"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+const (
+	// directResponseFilter answers the share of the weighted clusters that have no
+	// backend, as Envoy can only answer whole routes directly.
+	directResponseFilter = "envoy.filters.http.lua.direct_response"
+	// directResponseCluster is the cluster named by those weighted clusters. It is
+	// never defined, requests picking it are answered before reaching the router.
+	directResponseCluster = "direct_response"
+)
+
+// directResponseCode answers the request with the status formatted in.
+const directResponseCode = `
+function envoy_on_request(handle)
+  handle:respond({[":status"] = "%d"}, "")
+end
+`
+
+func makeDirectResponse() *lua.Lua {
+	return &lua.Lua{InlineCode: "function envoy_on_request(handle) end"}
+}
+
+func makeDirectResponsePerCluster(status uint32) *any.Any {
+	config := &lua.LuaPerRoute{Override: &lua.LuaPerRoute_SourceCode{SourceCode: &core.DataSource{
+		Specifier: &core.DataSource_InlineString{InlineString: fmt.Sprintf(directResponseCode, status)},
+	}}}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
@@ -0,0 +1,199 @@
+package resources
+
+import (
+	"fmt"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// originalPathHeader holds the path of the request before the route rewrote it.
+	originalPathHeader = "x-envoy-original-path"
+	// errorPagesFilter replaces the bodies of the backend responses of the routes
+	// with error pages, which local reply mappers do not see.
+	errorPagesFilter = "envoy.filters.http.lua.error_pages"
+)
+
+// errorPagesScript replaces the body and content type of the responses whose status
+// has a page in the pages table, prepended to the script.
+const errorPagesScript = `
+function envoy_on_response(handle)
+  local headers = handle:headers()
+  local page = pages[tonumber(headers:get(":status"))]
+  if page == nil then
+    return
+  end
+  headers:replace("content-type", page.content_type)
+  headers:remove("content-encoding")
+  headers:replace("content-length", tostring(#page.body))
+  handle:body(true):setBytes(page.body)
+end
+`
+
+func makeErrorPages() *lua.Lua {
+	return &lua.Lua{InlineCode: "function envoy_on_response(handle) end"}
+}
+
+// makeErrorPagesPerRoute replaces the backend responses of the route by its error
+// pages.
+func makeErrorPagesPerRoute(pages []ErrorPage) *any.Any {
+	config := &lua.LuaPerRoute{Override: &lua.LuaPerRoute_Disabled{Disabled: true}}
+	if len(pages) > 0 {
+		pages = append([]ErrorPage(nil), pages...)
+		sort.Slice(pages, func(i, j int) bool { return pages[i].StatusCode < pages[j].StatusCode })
+		var code strings.Builder
+		code.WriteString("local pages = {\n")
+		for _, page := range pages {
+			fmt.Fprintf(&code, "  [%d] = {body = %s, content_type = %s},\n", page.StatusCode, luaString(page.Body), luaString(page.ContentType))
+		}
+		code.WriteString("}\n" + errorPagesScript)
+		config.Override = &lua.LuaPerRoute_SourceCode{SourceCode: &core.DataSource{
+			Specifier: &core.DataSource_InlineString{InlineString: code.String()},
+		}}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+// makeLocalReplyConfig maps the local replies of the routes with error pages to their
+// bodies. Local reply mappers apply to the whole listener, so each mapper matches the
+// host and path of its route as well as the status code; like routes, the first
+// mapper that matches wins. Responses sent by the backends are replaced by the error
+// pages filter instead.
+func makeLocalReplyConfig(routes []Route) *hcm.LocalReplyConfig {
+	if len(routes) == 0 {
+		return nil
+	}
+	routes = append([]Route(nil), routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if (routes[i].Host == "") != (routes[j].Host == "") {
+			return routes[i].Host != ""
+		}
+		si, sj := routeSpecificity(routes[i]), routeSpecificity(routes[j])
+		if si != sj {
+			return si > sj
+		}
+		return routes[i].Name < routes[j].Name
+	})
+
+	config := &hcm.LocalReplyConfig{}
+	for _, r := range routes {
+		var matchers []*accesslog.AccessLogFilter
+		if r.Host != "" {
+			matchers = append(matchers, makeHeaderFilter(":authority", makeHostMatcher(r.Host)))
+		}
+		if path := makePathMatcher(r); path != nil {
+			pathFilter := makeHeaderFilter(":path", path)
+			if r.RegexRewrite != nil || r.FullPathRewrite != "" || r.PrefixRewrite != "" {
+				// the replies of the router see the rewritten path
+				pathFilter = &accesslog.AccessLogFilter{
+					FilterSpecifier: &accesslog.AccessLogFilter_OrFilter{OrFilter: &accesslog.OrFilter{
+						Filters: []*accesslog.AccessLogFilter{pathFilter, makeHeaderFilter(originalPathHeader, path)},
+					}},
+				}
+			}
+			matchers = append(matchers, pathFilter)
+		}
+
+		pages := append([]ErrorPage(nil), r.ErrorPages...)
+		sort.Slice(pages, func(i, j int) bool { return pages[i].StatusCode < pages[j].StatusCode })
+		for _, page := range pages {
+			filter := makeStatusCodeFilter(page.StatusCode)
+			if len(matchers) > 0 {
+				filter = &accesslog.AccessLogFilter{
+					FilterSpecifier: &accesslog.AccessLogFilter_AndFilter{AndFilter: &accesslog.AndFilter{
+						Filters: append([]*accesslog.AccessLogFilter{filter}, matchers...),
+					}},
+				}
+			}
+			config.Mappers = append(config.Mappers, &hcm.ResponseMapper{
+				Filter: filter,
+				Body: &core.DataSource{
+					Specifier: &core.DataSource_InlineString{InlineString: page.Body},
+				},
+				// the body is sent as is rather than parsed as a format string
+				BodyFormatOverride: &core.SubstitutionFormatString{
+					Format:      &core.SubstitutionFormatString_TextFormat{TextFormat: "%LOCAL_REPLY_BODY%"},
+					ContentType: page.ContentType,
+				},
+			})
+		}
+	}
+	return config
+}
+
+func makeStatusCodeFilter(code uint32) *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_StatusCodeFilter{StatusCodeFilter: &accesslog.StatusCodeFilter{
+			Comparison: &accesslog.ComparisonFilter{
+				Op: accesslog.ComparisonFilter_EQ,
+				Value: &core.RuntimeUInt32{
+					DefaultValue: code,
+					RuntimeKey:   fmt.Sprintf("error_pages.%d", code),
+				},
+			},
+		}},
+	}
+}
+
+func makeHeaderFilter(name string, m *matcher.StringMatcher) *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_HeaderFilter{HeaderFilter: &accesslog.HeaderFilter{
+			Header: &route.HeaderMatcher{
+				Name:                 name,
+				HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: m},
+			},
+		}},
+	}
+}
+
+// makeHostMatcher matches the Host header of the virtual host of a route, with or
+// without a port. A leading wildcard stands for a single label.
+func makeHostMatcher(host string) *matcher.StringMatcher {
+	pattern := regexp.QuoteMeta(host)
+	if strings.HasPrefix(host, "*.") {
+		pattern = `[^.]+` + regexp.QuoteMeta(host[1:])
+	}
+	return makeRegexMatcher(`(?i)` + pattern + `(:[0-9]+)?`)
+}
+
+// makePathMatcher matches the path of the requests of a route, query included. It
+// returns nil for the routes matching every path.
+func makePathMatcher(r Route) *matcher.StringMatcher {
+	switch {
+	case r.Connect:
+		return nil
+	case r.Path != "":
+		return makeRegexMatcher(regexp.QuoteMeta(r.Path) + `(\?.*)?`)
+	case r.PathSeparatedPrefix != "":
+		return makeRegexMatcher(regexp.QuoteMeta(r.PathSeparatedPrefix) + `([/?].*)?`)
+	case r.PathRegex != "":
+		return makeRegexMatcher(`(` + r.PathRegex + `)(\?.*)?`)
+	case r.Prefix == "" || r.Prefix == "/":
+		return nil
+	default:
+		return &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Prefix{Prefix: r.Prefix}}
+	}
+}
+
+// makeRegexMatcher matches whole values against the RE2 pattern.
+func makeRegexMatcher(pattern string) *matcher.StringMatcher {
+	return &matcher.StringMatcher{
+		MatchPattern: &matcher.StringMatcher_SafeRegex{SafeRegex: &matcher.RegexMatcher{
+			EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+			Regex:      pattern,
+		}},
+	}
+}
@@ -0,0 +1,121 @@
+package resources
+
+import (
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"strings"
+	"testing"
+)
+
+func TestMakeLocalReplyConfig(t *testing.T) {
+	if got := makeLocalReplyConfig(nil); got != nil {
+		t.Errorf("got local reply config %v without error pages, want none", got)
+	}
+
+	pages := []ErrorPage{{StatusCode: 503, Body: "down", ContentType: "text/plain"}, {StatusCode: 404, Body: "gone", ContentType: "text/plain"}}
+	config := makeLocalReplyConfig([]Route{
+		{Name: "default/catch-all/default", Prefix: "/", ErrorPages: pages[:1]},
+		{Name: "default/web/0/0", Host: "web.example.com", Prefix: "/api", ErrorPages: pages},
+	})
+	if len(config.Mappers) != 3 {
+		t.Fatalf("got %d mappers, want 3", len(config.Mappers))
+	}
+	// the mappers of the route with a host come first, ordered by status code
+	want := []struct {
+		code    uint32
+		body    string
+		filters int
+	}{{404, "gone", 3}, {503, "down", 3}, {503, "down", 0}}
+	for i, w := range want {
+		m := config.Mappers[i]
+		if got := m.Body.GetInlineString(); got != w.body {
+			t.Errorf("mapper %d has body %q, want %q", i, got, w.body)
+		}
+		if got := m.BodyFormatOverride.ContentType; got != "text/plain" {
+			t.Errorf("mapper %d has content type %q, want text/plain", i, got)
+		}
+		status := m.Filter.GetStatusCodeFilter()
+		if and := m.Filter.GetAndFilter(); and != nil {
+			if len(and.Filters) != w.filters {
+				t.Errorf("mapper %d has %d filters, want %d", i, len(and.Filters), w.filters)
+			}
+			status = and.Filters[0].GetStatusCodeFilter()
+		} else if w.filters != 0 {
+			t.Errorf("mapper %d matches every host and path", i)
+		}
+		if got := status.GetComparison().GetValue().GetDefaultValue(); got != w.code {
+			t.Errorf("mapper %d matches status code %d, want %d", i, got, w.code)
+		}
+	}
+}
+
+func TestMakePathMatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		want  string
+	}{
+		{name: "root prefix", route: Route{Prefix: "/"}},
+		{name: "CONNECT", route: Route{Connect: true}},
+		{name: "exact path", route: Route{Path: "/a.b"}, want: `/a\.b(\?.*)?`},
+		{name: "path separated prefix", route: Route{PathSeparatedPrefix: "/api"}, want: `/api([/?].*)?`},
+		{name: "prefix", route: Route{Prefix: "/api"}, want: "/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := makePathMatcher(tt.route)
+			var got string
+			if m != nil {
+				got = m.GetSafeRegex().GetRegex() + m.GetPrefix()
+			}
+			if got != tt.want {
+				t.Errorf("got path matcher %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeHostMatcher(t *testing.T) {
+	if got := makeHostMatcher("*.example.com").GetSafeRegex().GetRegex(); got != `(?i)[^.]+\.example\.com(:[0-9]+)?` {
+		t.Errorf("got host regex %q", got)
+	}
+}
+
+func TestErrorPagesOfBackendResponses(t *testing.T) {
+	pages := []ErrorPage{
+		{StatusCode: 503, Body: "<h1>Unavailable</h1>", ContentType: "text/html"},
+		{StatusCode: 404, Body: "{\"error\": \"not found\"}\n", ContentType: "application/json"},
+	}
+	withPages := Route{Name: "pages", Prefix: "/", Cluster: "default/web/80", ErrorPages: pages}
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, ErrorPageRoutes: []Route{withPages}}
+
+	installed := false
+	for _, f := range makeHTTPFilters(l) {
+		installed = installed || f.Name == errorPagesFilter
+	}
+	if !installed {
+		t.Fatalf("the listener has no %s filter", errorPagesFilter)
+	}
+
+	config := &lua.LuaPerRoute{}
+	if err := makeRoute(l, withPages).TypedPerFilterConfig[errorPagesFilter].UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	code := config.GetSourceCode().GetInlineString()
+	for _, want := range []string{
+		`[404] = {body = "{\034error\034: \034not found\034}\010", content_type = "application/json"},`,
+		`[503] = {body = "<h1>Unavailable</h1>", content_type = "text/html"},`,
+		"function envoy_on_response(handle)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("the error pages script has no %s:\n%s", want, code)
+		}
+	}
+
+	without := makeRoute(l, Route{Name: "plain", Prefix: "/api", Cluster: "default/api/80"})
+	if err := without.TypedPerFilterConfig[errorPagesFilter].UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if !config.GetDisabled() {
+		t.Errorf("the backend responses of a route without error pages are replaced")
+	}
+}
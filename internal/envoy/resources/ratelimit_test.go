@@ -0,0 +1,226 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	commonratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRateLimitService allows limit requests per descriptor, or fails every request.
+type fakeRateLimitService struct {
+	rls.UnimplementedRateLimitServiceServer
+	limit uint32
+	fail  bool
+
+	mu       sync.Mutex
+	requests []*rls.RateLimitRequest
+	hits     map[string]uint32
+}
+
+func (s *fakeRateLimitService) ShouldRateLimit(_ context.Context, req *rls.RateLimitRequest) (*rls.RateLimitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if s.fail {
+		return nil, errors.New("rate limit service unavailable")
+	}
+	resp := &rls.RateLimitResponse{OverallCode: rls.RateLimitResponse_OK}
+	for _, d := range req.Descriptors {
+		var key []string
+		for _, e := range d.Entries {
+			key = append(key, e.Key+"="+e.Value)
+		}
+		s.hits[req.Domain+"/"+strings.Join(key, ",")] += req.HitsAddend
+		if s.hits[req.Domain+"/"+strings.Join(key, ",")] > s.limit {
+			resp.OverallCode = rls.RateLimitResponse_OVER_LIMIT
+		}
+	}
+	return resp, nil
+}
+
+// startRateLimitService serves the fake service in process and returns a client of it.
+func startRateLimitService(t *testing.T, s *fakeRateLimitService) rls.RateLimitServiceClient {
+	t.Helper()
+	s.hits = map[string]uint32{}
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	rls.RegisterRateLimitServiceServer(server, s)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rls.NewRateLimitServiceClient(conn)
+}
+
+type rateLimitedRequest struct {
+	remoteAddress string
+	path          string
+	headers       map[string]string
+}
+
+// rateLimitStatus does what the rate limit filter of Envoy does with the generated
+// configuration: it builds the descriptor of the actions of the route, skipping
+// rate limiting when a header is missing, and answers with a 429 when over the
+// limit, or with a 500 when the service fails in failure_mode_deny.
+func rateLimitStatus(t *testing.T, client rls.RateLimitServiceClient, filter *ratelimit.RateLimit, limit *route.RateLimit, req rateLimitedRequest) int {
+	t.Helper()
+	descriptor := &commonratelimit.RateLimitDescriptor{}
+	for _, action := range limit.Actions {
+		switch a := action.ActionSpecifier.(type) {
+		case *route.RateLimit_Action_RemoteAddress_:
+			descriptor.Entries = append(descriptor.Entries, &commonratelimit.RateLimitDescriptor_Entry{Key: "remote_address", Value: req.remoteAddress})
+		case *route.RateLimit_Action_RequestHeaders_:
+			value, ok := req.headers[strings.ToLower(a.RequestHeaders.HeaderName)]
+			if a.RequestHeaders.HeaderName == ":path" {
+				value, ok = req.path, true
+			}
+			if !ok {
+				return http.StatusOK
+			}
+			descriptor.Entries = append(descriptor.Entries, &commonratelimit.RateLimitDescriptor_Entry{Key: a.RequestHeaders.DescriptorKey, Value: value})
+		default:
+			t.Fatalf("unexpected rate limit action %v", action)
+		}
+	}
+	resp, err := client.ShouldRateLimit(context.Background(), &rls.RateLimitRequest{
+		Domain:      filter.Domain,
+		Descriptors: []*commonratelimit.RateLimitDescriptor{descriptor},
+		HitsAddend:  1,
+	})
+	switch {
+	case err != nil && filter.FailureModeDeny:
+		return http.StatusInternalServerError
+	case err != nil:
+		return http.StatusOK
+	case resp.OverallCode == rls.RateLimitResponse_OVER_LIMIT:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusOK
+}
+
+// rateLimitConfig returns the rate limit filter of the listener and the rate limit of
+// the route.
+func rateLimitConfig(t *testing.T, l Listener, r Route) (*ratelimit.RateLimit, *route.RateLimit) {
+	t.Helper()
+	filter := &ratelimit.RateLimit{}
+	found := false
+	for _, f := range makeHTTPFilters(l) {
+		if f.Name == wellknown.HTTPRateLimit {
+			if err := f.GetTypedConfig().UnmarshalTo(filter); err != nil {
+				t.Fatal(err)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("the listener has no rate limit filter")
+	}
+	limits := makeRoute(l, r).GetRoute().RateLimits
+	if len(limits) != 1 {
+		t.Fatalf("got %d rate limits, want 1", len(limits))
+	}
+	if got := filter.RateLimitService.GrpcService.GetEnvoyGrpc().ClusterName; got != l.RateLimitService.Cluster {
+		t.Errorf("the filter calls cluster %s, want %s", got, l.RateLimitService.Cluster)
+	}
+	return filter, limits[0]
+}
+
+func TestRateLimitService(t *testing.T) {
+	r := Route{
+		Name:    "default/api/0/0",
+		Prefix:  "/api",
+		Cluster: "default/api/80",
+		RateLimitDescriptors: []RateLimitDescriptor{
+			{Kind: "remote_address"},
+			{Kind: "header", Header: "X-API-Key"},
+			{Kind: "path"},
+		},
+	}
+	listener := func(failureModeDeny bool) Listener {
+		return Listener{
+			Name:       "listener_0",
+			RouteNames: []string{"listener_0"},
+			RateLimitService: &RateLimitService{
+				Cluster:         "ratelimit/ratelimit/8081",
+				Domain:          "ingress",
+				FailureModeDeny: failureModeDeny,
+			},
+		}
+	}
+	alice := rateLimitedRequest{remoteAddress: "10.0.0.1", path: "/api/users", headers: map[string]string{"x-api-key": "alice"}}
+	bob := rateLimitedRequest{remoteAddress: "10.0.0.1", path: "/api/users", headers: map[string]string{"x-api-key": "bob"}}
+	anonymous := rateLimitedRequest{remoteAddress: "10.0.0.1", path: "/api/users"}
+
+	t.Run("descriptors", func(t *testing.T) {
+		s := &fakeRateLimitService{limit: 10}
+		filter, limit := rateLimitConfig(t, listener(false), r)
+		rateLimitStatus(t, startRateLimitService(t, s), filter, limit, alice)
+		if len(s.requests) != 1 {
+			t.Fatalf("the service got %d requests, want 1", len(s.requests))
+		}
+		if s.requests[0].Domain != "ingress" {
+			t.Errorf("got domain %q, want ingress", s.requests[0].Domain)
+		}
+		var entries []string
+		for _, e := range s.requests[0].Descriptors[0].Entries {
+			entries = append(entries, e.Key+"="+e.Value)
+		}
+		if want := []string{"remote_address=10.0.0.1", "x-api-key=alice", "path=/api/users"}; !reflect.DeepEqual(entries, want) {
+			t.Errorf("got descriptor %v, want %v", entries, want)
+		}
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		s := &fakeRateLimitService{limit: 2}
+		filter, limit := rateLimitConfig(t, listener(false), r)
+		client := startRateLimitService(t, s)
+		var got []int
+		for _, req := range []rateLimitedRequest{alice, alice, alice, bob, anonymous} {
+			got = append(got, rateLimitStatus(t, client, filter, limit, req))
+		}
+		// each key has its own limit, and requests without the header are not limited
+		want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got statuses %v, want %v", got, want)
+		}
+		if len(s.requests) != 4 {
+			t.Errorf("the service got %d requests, want 4", len(s.requests))
+		}
+	})
+
+	for _, tt := range []struct {
+		name            string
+		failureModeDeny bool
+		want            int
+	}{
+		{name: "fail open", want: http.StatusOK},
+		{name: "fail closed", failureModeDeny: true, want: http.StatusInternalServerError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeRateLimitService{fail: true}
+			filter, limit := rateLimitConfig(t, listener(tt.failureModeDeny), r)
+			if got := rateLimitStatus(t, startRateLimitService(t, s), filter, limit, alice); got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,33 +1,354 @@
 package resources
 
 import (
+	"fmt"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	ratelimitconfig "github.com/envoyproxy/go-control-plane/envoy/config/ratelimit/v3"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	trace "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	grpcaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	corsfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cors/v3"
+	extauthz "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	jwtauthn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	rbacfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	router "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	proxyprotocol "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
+	tlsinspector "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/tls_inspector/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	upstreamhttp "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	tracingtype "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func MakeCluster(clusterName string) *cluster.Cluster {
-	return &cluster.Cluster{
-		Name:                 clusterName,
-		ConnectTimeout:       ptypes.DurationProto(5 * time.Second),
+var dnsLookupFamilies = map[string]cluster.Cluster_DnsLookupFamily{
+	"auto":         cluster.Cluster_AUTO,
+	"v4_only":      cluster.Cluster_V4_ONLY,
+	"v6_only":      cluster.Cluster_V6_ONLY,
+	"v4_preferred": cluster.Cluster_V4_PREFERRED,
+	"all":          cluster.Cluster_ALL,
+}
+
+// IsDNSLookupFamily reports whether family is a DNS lookup family of clusters.
+func IsDNSLookupFamily(family string) bool {
+	_, ok := dnsLookupFamilies[family]
+	return ok
+}
+
+var lbPolicies = map[string]cluster.Cluster_LbPolicy{
+	"round_robin":   cluster.Cluster_ROUND_ROBIN,
+	"least_request": cluster.Cluster_LEAST_REQUEST,
+	"random":        cluster.Cluster_RANDOM,
+	"ring_hash":     cluster.Cluster_RING_HASH,
+	"maglev":        cluster.Cluster_MAGLEV,
+}
+
+const (
+	localRateLimitFilter     = "envoy.filters.http.local_ratelimit"
+	localRateLimitStatPrefix = "http_local_rate_limiter"
+	jwtAuthnFilter           = "envoy.filters.http.jwt_authn"
+	openTelemetryTracer      = "envoy.tracers.opentelemetry"
+	defaultMaxRequestBytes   = 1 << 20
+	webSocketUpgrade         = "websocket"
+	connectUpgrade           = "CONNECT"
+	// LocalClusterName is the cluster of the proxies themselves, by zone, that the
+	// Envoy bootstrap names in cluster_manager.local_cluster_name for zone-aware
+	// routing.
+	LocalClusterName = "local_cluster"
+	// MetadataNamespace holds the origin of generated routes and clusters in their
+	// metadata.
+	MetadataNamespace = "inendless.com"
+	// JWTPayloadMetadataKey holds the payload of validated tokens in the dynamic
+	// metadata of the jwt_authn filter.
+	JWTPayloadMetadataKey = "jwt_payload"
+)
+
+// JWTClaimHeaderValue is a header value formatter expanding to a claim of the
+// validated token of the request.
+func JWTClaimHeaderValue(claim string) string {
+	return fmt.Sprintf(`%%DYNAMIC_METADATA(["%s", "%s", "%s"])%%`, jwtAuthnFilter, JWTPayloadMetadataKey, claim)
+}
+
+var extAuthzDisabled = func() *any.Any {
+	pbst, err := ptypes.MarshalAny(&extauthz.ExtAuthzPerRoute{
+		Override: &extauthz.ExtAuthzPerRoute_Disabled{Disabled: true},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}()
+
+func MakeCluster(c Cluster) *cluster.Cluster {
+	family, ok := dnsLookupFamilies[c.DNSLookupFamily]
+	if !ok {
+		family = cluster.Cluster_V4_ONLY
+	}
+	lbPolicy, ok := lbPolicies[c.LbPolicy]
+	if !ok {
+		lbPolicy = cluster.Cluster_ROUND_ROBIN
+	}
+	connectTimeout := c.ConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = 5 * time.Second
+	}
+	cls := &cluster.Cluster{
+		Name:                 c.Name,
+		ConnectTimeout:       ptypes.DurationProto(connectTimeout),
 		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
-		LbPolicy:             cluster.Cluster_ROUND_ROBIN,
-		DnsLookupFamily:      cluster.Cluster_V4_ONLY,
+		LbPolicy:             lbPolicy,
+		DnsLookupFamily:      family,
+		CircuitBreakers:      makeCircuitBreakers(c.CircuitBreakers),
+		Metadata:             makeOriginMetadata(c.Origin),
+	}
+	if c.HealthCheck != nil {
+		cls.HealthChecks = []*core.HealthCheck{makeHealthCheck(c.HealthCheck, c.Hostname)}
+	}
+	if c.OutlierDetection != nil {
+		cls.OutlierDetection = makeOutlierDetection(c.OutlierDetection)
+	}
+	if c.UpstreamTLS != nil {
+		cls.TransportSocket = makeUpstreamTLSTransportSocket(c.UpstreamTLS)
+	}
+	if c.MaxRequestsPerConnection != 0 || c.HTTP2 {
+		cls.TypedExtensionProtocolOptions = map[string]*any.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": makeHTTPProtocolOptions(c),
+		}
+	}
+	switch c.Type {
+	case StrictDNSCluster:
+		cls.ClusterDiscoveryType = &cluster.Cluster_Type{Type: cluster.Cluster_STRICT_DNS}
+		cls.LoadAssignment = MakeEndpoint(c.Name, c.Endpoints)
+	case LogicalDNSCluster:
+		cls.ClusterDiscoveryType = &cluster.Cluster_Type{Type: cluster.Cluster_LOGICAL_DNS}
+		cls.LoadAssignment = MakeEndpoint(c.Name, c.Endpoints)
+	}
+	switch {
+	case c.LocalityWeightedLb:
+		cls.CommonLbConfig = &cluster.Cluster_CommonLbConfig{
+			LocalityConfigSpecifier: &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig_{
+				LocalityWeightedLbConfig: &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig{},
+			},
+		}
+	case c.ZoneAwareLb:
+		cls.CommonLbConfig = &cluster.Cluster_CommonLbConfig{
+			LocalityConfigSpecifier: &cluster.Cluster_CommonLbConfig_ZoneAwareLbConfig_{
+				ZoneAwareLbConfig: &cluster.Cluster_CommonLbConfig_ZoneAwareLbConfig{},
+			},
+		}
+	}
+	return cls
+}
+
+func makeCircuitBreakers(cb CircuitBreakers) *cluster.CircuitBreakers {
+	if cb == (CircuitBreakers{}) {
+		return nil
+	}
+	thresholds := &cluster.CircuitBreakers_Thresholds{
+		Priority: core.RoutingPriority_DEFAULT,
+	}
+	if cb.MaxConnections != 0 {
+		thresholds.MaxConnections = &wrappers.UInt32Value{Value: cb.MaxConnections}
+	}
+	if cb.MaxPendingRequests != 0 {
+		thresholds.MaxPendingRequests = &wrappers.UInt32Value{Value: cb.MaxPendingRequests}
+	}
+	if cb.MaxRequests != 0 {
+		thresholds.MaxRequests = &wrappers.UInt32Value{Value: cb.MaxRequests}
+	}
+	if cb.MaxRetries != 0 {
+		thresholds.MaxRetries = &wrappers.UInt32Value{Value: cb.MaxRetries}
+	}
+	return &cluster.CircuitBreakers{
+		Thresholds: []*cluster.CircuitBreakers_Thresholds{thresholds},
 	}
 }
 
+func makeHTTPProtocolOptions(c Cluster) *any.Any {
+	explicit := &upstreamhttp.HttpProtocolOptions_ExplicitHttpConfig{
+		ProtocolConfig: &upstreamhttp.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{},
+	}
+	if c.HTTP2 {
+		explicit.ProtocolConfig = &upstreamhttp.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{}
+	}
+	options := &upstreamhttp.HttpProtocolOptions{
+		UpstreamProtocolOptions: &upstreamhttp.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: explicit,
+		},
+	}
+	if c.MaxRequestsPerConnection != 0 {
+		options.CommonHttpProtocolOptions = &core.HttpProtocolOptions{
+			MaxRequestsPerConnection: &wrappers.UInt32Value{Value: c.MaxRequestsPerConnection},
+		}
+	}
+	pbst, err := ptypes.MarshalAny(options)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+func makeUpstreamTLSTransportSocket(t *UpstreamTLS) *core.TransportSocket {
+	tlsContext := &tls.UpstreamTlsContext{
+		Sni:              t.SNI,
+		CommonTlsContext: &tls.CommonTlsContext{},
+	}
+	if len(t.CACert) > 0 || t.CAFile != "" {
+		validation := &tls.CertificateValidationContext{
+			TrustedCa: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: t.CACert},
+			},
+		}
+		if len(t.CACert) == 0 {
+			validation.TrustedCa.Specifier = &core.DataSource_Filename{Filename: t.CAFile}
+		}
+		if t.SNI != "" {
+			validation.MatchTypedSubjectAltNames = []*tls.SubjectAltNameMatcher{{
+				SanType: tls.SubjectAltNameMatcher_DNS,
+				Matcher: &matcher.StringMatcher{
+					MatchPattern: &matcher.StringMatcher_Exact{Exact: t.SNI},
+				},
+			}}
+		}
+		tlsContext.CommonTlsContext.ValidationContextType = &tls.CommonTlsContext_ValidationContext{
+			ValidationContext: validation,
+		}
+	}
+	if t.ClientCertificate != nil {
+		tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*tls.SdsSecretConfig{{
+			Name:      t.ClientCertificate.Name,
+			SdsConfig: makeConfigSource(),
+		}}
+	}
+	pbst, err := ptypes.MarshalAny(tlsContext)
+	if err != nil {
+		panic(err)
+	}
+	return &core.TransportSocket{
+		Name: wellknown.TransportSocketTLS,
+		ConfigType: &core.TransportSocket_TypedConfig{
+			TypedConfig: pbst,
+		},
+	}
+}
+
+func MakeSecret(s Secret) *tls.Secret {
+	return &tls.Secret{
+		Name: s.Name,
+		Type: &tls.Secret_TlsCertificate{
+			TlsCertificate: &tls.TlsCertificate{
+				CertificateChain: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{InlineBytes: s.Certificate},
+				},
+				PrivateKey: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{InlineBytes: s.PrivateKey},
+				},
+			},
+		},
+	}
+}
+
+func makeHealthCheck(hc *HealthCheck, host string) *core.HealthCheck {
+	interval, timeout := hc.Interval, hc.Timeout
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	healthy, unhealthy := hc.HealthyThreshold, hc.UnhealthyThreshold
+	if healthy == 0 {
+		healthy = 2
+	}
+	if unhealthy == 0 {
+		unhealthy = 3
+	}
+	check := &core.HealthCheck{
+		Interval:           ptypes.DurationProto(interval),
+		Timeout:            ptypes.DurationProto(timeout),
+		HealthyThreshold:   &wrappers.UInt32Value{Value: healthy},
+		UnhealthyThreshold: &wrappers.UInt32Value{Value: unhealthy},
+	}
+	switch hc.Protocol {
+	case "grpc":
+		check.HealthChecker = &core.HealthCheck_GrpcHealthCheck_{
+			GrpcHealthCheck: &core.HealthCheck_GrpcHealthCheck{},
+		}
+	case "tcp":
+		check.HealthChecker = &core.HealthCheck_TcpHealthCheck_{
+			TcpHealthCheck: &core.HealthCheck_TcpHealthCheck{},
+		}
+	default:
+		path := hc.Path
+		if path == "" {
+			path = "/"
+		}
+		check.HealthChecker = &core.HealthCheck_HttpHealthCheck_{
+			HttpHealthCheck: &core.HealthCheck_HttpHealthCheck{Path: path, Host: host},
+		}
+	}
+	return check
+}
+
+func makeOutlierDetection(od *OutlierDetection) *cluster.OutlierDetection {
+	detection := &cluster.OutlierDetection{}
+	if od.Consecutive5xx != 0 {
+		detection.Consecutive_5Xx = &wrappers.UInt32Value{Value: od.Consecutive5xx}
+	}
+	if od.Interval != 0 {
+		detection.Interval = ptypes.DurationProto(od.Interval)
+	}
+	if od.BaseEjectionTime != 0 {
+		detection.BaseEjectionTime = ptypes.DurationProto(od.BaseEjectionTime)
+	}
+	if od.MaxEjectionPercent != 0 {
+		detection.MaxEjectionPercent = &wrappers.UInt32Value{Value: od.MaxEjectionPercent}
+	}
+	return detection
+}
+
+// MakeEndpoint groups the endpoints by region and zone into one LocalityLbEndpoints
+// each, weighted by the number of endpoints in that locality.
 func MakeEndpoint(clusterName string, eps []Endpoint) *endpoint.ClusterLoadAssignment {
-	var endpoints []*endpoint.LbEndpoint
+	var localities []*endpoint.LocalityLbEndpoints
+	byLocality := map[string]*endpoint.LocalityLbEndpoints{}
 
 	for _, e := range eps {
-		endpoints = append(endpoints, &endpoint.LbEndpoint{
+		key := e.Region + "/" + e.Zone
+		locality, ok := byLocality[key]
+		if !ok {
+			locality = &endpoint.LocalityLbEndpoints{}
+			if e.Region != "" || e.Zone != "" {
+				locality.Locality = &core.Locality{Region: e.Region, Zone: e.Zone}
+			}
+			byLocality[key] = locality
+			localities = append(localities, locality)
+		}
+		locality.LbEndpoints = append(locality.LbEndpoints, &endpoint.LbEndpoint{
 			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
 				Endpoint: &endpoint.Endpoint{
 					Address: &core.Address{
@@ -46,88 +367,1106 @@ func MakeEndpoint(clusterName string, eps []Endpoint) *endpoint.ClusterLoadAssig
 		})
 	}
 
+	sort.SliceStable(localities, func(i, j int) bool {
+		return localities[i].GetLocality().GetRegion()+"/"+localities[i].GetLocality().GetZone() <
+			localities[j].GetLocality().GetRegion()+"/"+localities[j].GetLocality().GetZone()
+	})
+	for _, locality := range localities {
+		locality.LoadBalancingWeight = &wrappers.UInt32Value{Value: uint32(len(locality.LbEndpoints))}
+	}
+
 	return &endpoint.ClusterLoadAssignment{
 		ClusterName: clusterName,
-		Endpoints: []*endpoint.LocalityLbEndpoints{{
-			LbEndpoints: endpoints,
-		}},
+		Endpoints:   localities,
+	}
+}
+
+// MakeRoutes builds the route configurations of a listener: a single one for plaintext
+// listeners, and one per filter chain for TLS listeners. Each TLS filter chain only
+// routes the hosts whose server name selects that chain, so a request cannot reach
+// the hosts of another chain, which may require client certificates, by sending a
+// Host header that differs from its SNI.
+func MakeRoutes(l Listener, routes []Route) []*route.RouteConfiguration {
+	routes = listenerRoutes(l, routes)
+	if len(l.TLSFilterChains) == 0 {
+		return []*route.RouteConfiguration{MakeRoute(l, l.RouteNames[0], routes)}
+	}
+	byChain := map[int][]Route{}
+	for _, r := range routes {
+		if i := tlsFilterChainForHost(l.TLSFilterChains, r.Host); i >= 0 {
+			byChain[i] = append(byChain[i], r)
+		}
+	}
+	var configs []*route.RouteConfiguration
+	for i, chain := range l.TLSFilterChains {
+		configs = append(configs, MakeRoute(l, tlsRouteName(l, chain), byChain[i]))
+	}
+	return configs
+}
+
+// listenerRoutes returns the routes served by a listener, leaving out those limited
+// to the scheme of the other listeners.
+func listenerRoutes(l Listener, routes []Route) []Route {
+	scheme := "http"
+	if len(l.TLSFilterChains) > 0 {
+		scheme = "https"
+	}
+	var served []Route
+	for _, r := range routes {
+		if r.Scheme == "" || r.Scheme == scheme {
+			served = append(served, r)
+		}
 	}
+	return served
 }
 
-func MakeRoute(routes []Route) *route.RouteConfiguration {
-	var rts []*route.Route
+// tlsFilterChainForHost returns the index of the filter chain Envoy selects for a
+// server name: the chain of the exact name, else of the longest matching wildcard,
+// else the chain without server names. Routes without a host are only served by the
+// chain without server names. It returns -1 when no chain matches.
+func tlsFilterChainForHost(chains []TLSFilterChain, host string) int {
+	match, matchLen := -1, -1
+	for i, chain := range chains {
+		if len(chain.ServerNames) == 0 {
+			if matchLen < 0 {
+				match, matchLen = i, 0
+			}
+			continue
+		}
+		if host == "" {
+			continue
+		}
+		for _, name := range chain.ServerNames {
+			switch {
+			case strings.EqualFold(name, host):
+				return i
+			case strings.HasPrefix(name, "*.") && len(name)-1 > matchLen &&
+				len(host) > len(name)-1 && strings.HasSuffix(strings.ToLower(host), strings.ToLower(name[1:])):
+				match, matchLen = i, len(name)-1
+			}
+		}
+	}
+	return match
+}
+
+// tlsRouteName is the name of the route configuration of a TLS filter chain.
+func tlsRouteName(l Listener, chain TLSFilterChain) string {
+	if len(chain.ServerNames) == 0 {
+		return l.RouteNames[0]
+	}
+	return l.RouteNames[0] + "/" + chain.ServerNames[0]
+}
+
+// MakeRoute builds a route configuration of a listener. Plaintext listeners redirect
+// the routes of TLS hosts to HTTPS, while TLS listeners add the HSTS header.
+func MakeRoute(l Listener, name string, routes []Route) *route.RouteConfiguration {
+	var vhosts []*route.VirtualHost
+	byHost := map[string]*route.VirtualHost{}
 
+	sort.SliceStable(routes, func(i, j int) bool {
+		si, sj := routeSpecificity(routes[i]), routeSpecificity(routes[j])
+		if si != sj {
+			return si > sj
+		}
+		return routes[i].Name < routes[j].Name
+	})
 	for _, r := range routes {
-		rts = append(rts, &route.Route{
-			//Name: r.Name,
-			Match: &route.RouteMatch{
-				PathSpecifier: &route.RouteMatch_Prefix{
-					Prefix: r.Prefix,
+		host := r.Host
+		if host == "" {
+			host = "*"
+		}
+		vhost, ok := byHost[host]
+		if !ok {
+			vhost = &route.VirtualHost{
+				Name:    host,
+				Domains: []string{host},
+			}
+			byHost[host] = vhost
+			vhosts = append(vhosts, vhost)
+		}
+		if vhost.Cors == nil && r.VirtualHostCors != nil {
+			vhost.Cors = makeCorsPolicy(r.VirtualHostCors)
+		}
+		if len(vhost.RequestHeadersToAdd) == 0 && len(vhost.RequestHeadersToRemove) == 0 {
+			vhost.RequestHeadersToAdd, vhost.RequestHeadersToRemove = makeHeaderPolicy(r.VirtualHostRequestHeaders)
+		}
+		if len(vhost.ResponseHeadersToAdd) == 0 && len(vhost.ResponseHeadersToRemove) == 0 {
+			vhost.ResponseHeadersToAdd, vhost.ResponseHeadersToRemove = makeHeaderPolicy(r.VirtualHostResponseHeaders)
+		}
+		vhost.Routes = append(vhost.Routes, makeRoute(l, r))
+	}
+
+	// the catch-all virtual host only matches requests no other host claimed
+	sort.SliceStable(vhosts, func(i, j int) bool {
+		if vhosts[i].Name == "*" || vhosts[j].Name == "*" {
+			return vhosts[j].Name == "*" && vhosts[i].Name != "*"
+		}
+		return vhosts[i].Name < vhosts[j].Name
+	})
+
+	return &route.RouteConfiguration{
+		Name:         name,
+		VirtualHosts: vhosts,
+	}
+}
+
+func makeRoute(l Listener, r Route) *route.Route {
+	tls := len(l.TLSFilterChains) > 0
+	rt := &route.Route{
+		Name:     r.Name,
+		Match:    &route.RouteMatch{},
+		Metadata: makeOriginMetadata(r.Origin),
+		Tracing:  makeRouteTracing(r.Tracing),
+	}
+	switch {
+	case r.Connect:
+		rt.Match.PathSpecifier = &route.RouteMatch_ConnectMatcher_{ConnectMatcher: &route.RouteMatch_ConnectMatcher{}}
+	case r.Path != "":
+		rt.Match.PathSpecifier = &route.RouteMatch_Path{Path: r.Path}
+	case r.PathSeparatedPrefix != "":
+		rt.Match.PathSpecifier = &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: r.PathSeparatedPrefix}
+	case r.PathRegex != "":
+		rt.Match.PathSpecifier = &route.RouteMatch_SafeRegex{SafeRegex: makeRegexMatcher(r.PathRegex).GetSafeRegex()}
+	default:
+		rt.Match.PathSpecifier = &route.RouteMatch_Prefix{Prefix: r.Prefix}
+	}
+	if r.Method != "" {
+		rt.Match.Headers = append(rt.Match.Headers, makeHeaderMatcher(ValueMatch{Name: ":method", Value: r.Method}))
+	}
+	for _, m := range r.HeaderMatches {
+		rt.Match.Headers = append(rt.Match.Headers, makeHeaderMatcher(m))
+	}
+	for _, m := range r.QueryParamMatches {
+		rt.Match.QueryParameters = append(rt.Match.QueryParameters, &route.QueryParameterMatcher{
+			Name: m.Name,
+			QueryParameterMatchSpecifier: &route.QueryParameterMatcher_StringMatch{
+				StringMatch: makeValueMatcher(m),
+			},
+		})
+	}
+
+	if !tls && r.HTTPSRedirectCode != 0 {
+		code := route.RedirectAction_PERMANENT_REDIRECT
+		if r.HTTPSRedirectCode == 301 {
+			code = route.RedirectAction_MOVED_PERMANENTLY
+		}
+		rt.Action = &route.Route_Redirect{
+			Redirect: &route.RedirectAction{
+				SchemeRewriteSpecifier: &route.RedirectAction_HttpsRedirect{HttpsRedirect: true},
+				ResponseCode:           code,
+			},
+		}
+		if l.Buffering {
+			rt.TypedPerFilterConfig = map[string]*any.Any{wellknown.Buffer: makeBufferPerRoute(0)}
+		}
+		return rt
+	}
+	rt.RequestHeadersToAdd, rt.RequestHeadersToRemove = makeHeaderPolicy(r.RequestHeaders)
+	if _, all := routeCompression(l, r); !all {
+		rt.RequestHeadersToAdd = append(rt.RequestHeadersToAdd, restoreAcceptEncoding())
+	}
+	rt.ResponseHeadersToAdd, rt.ResponseHeadersToRemove = makeHeaderPolicy(r.ResponseHeaders)
+	if tls && r.HSTS != nil {
+		rt.ResponseHeadersToAdd = append(rt.ResponseHeadersToAdd, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   "Strict-Transport-Security",
+				Value: r.HSTS.String(),
+			},
+			Append: &wrappers.BoolValue{Value: false},
+		})
+	}
+
+	rt.TypedPerFilterConfig = map[string]*any.Any{}
+	if len(l.Compression) > 0 {
+		rt.TypedPerFilterConfig[compressionGuardFilter] = makeCompressionGuardPerRoute(l, r)
+	}
+	if l.Buffering {
+		rt.TypedPerFilterConfig[wellknown.Buffer] = makeBufferPerRoute(r.MaxRequestBytes)
+	}
+	if l.MaxResponseBytes > 0 {
+		rt.TypedPerFilterConfig[responseBufferFilter] = makeResponseBufferPerRoute(r.MaxResponseBytes)
+	}
+	if len(l.ErrorPageRoutes) > 0 {
+		rt.TypedPerFilterConfig[errorPagesFilter] = makeErrorPagesPerRoute(r.ErrorPages)
+	}
+	if r.LocalRateLimit != nil {
+		rt.TypedPerFilterConfig[localRateLimitFilter] = makeLocalRateLimit(r.LocalRateLimit)
+	}
+	if r.IPFilter != nil {
+		rt.TypedPerFilterConfig[wellknown.HTTPRoleBasedAccessControl] = makeIPFilter(r.IPFilter)
+	}
+	if len(l.BasicAuth) > 0 {
+		rt.TypedPerFilterConfig[wellknown.Lua] = makeBasicAuthPerRoute(r.BasicAuth)
+	}
+	if len(l.JWTProviders) > 0 {
+		rt.TypedPerFilterConfig[jwtAuthnFilter] = makeJWTPerRoute(r.JWT)
+	}
+	for _, authz := range l.ExtAuthz {
+		if r.ExtAuthz == nil || r.ExtAuthz.Name != authz.Name {
+			rt.TypedPerFilterConfig[authz.Name] = extAuthzDisabled
+		}
+	}
+
+	if r.DirectResponseStatus != 0 {
+		action := &route.DirectResponseAction{Status: r.DirectResponseStatus}
+		if r.DirectResponseBody != "" {
+			action.Body = &core.DataSource{
+				Specifier: &core.DataSource_InlineString{InlineString: r.DirectResponseBody},
+			}
+		}
+		rt.Action = &route.Route_DirectResponse{DirectResponse: action}
+		return rt
+	}
+	action := &route.RouteAction{
+		ClusterSpecifier: &route.RouteAction_Cluster{
+			Cluster: r.Cluster,
+		},
+		HashPolicy: makeHashPolicy(r.HashPolicy),
+	}
+	if len(r.WeightedClusters) > 0 {
+		weighted := &route.WeightedCluster{}
+		for _, c := range r.WeightedClusters {
+			cw := &route.WeightedCluster_ClusterWeight{
+				Name:   c.Name,
+				Weight: &wrappers.UInt32Value{Value: c.Weight},
+			}
+			if c.DirectResponseStatus != 0 {
+				cw.Name = directResponseCluster
+				cw.TypedPerFilterConfig = map[string]*any.Any{
+					directResponseFilter: makeDirectResponsePerCluster(c.DirectResponseStatus),
+				}
+			}
+			weighted.Clusters = append(weighted.Clusters, cw)
+		}
+		action.ClusterSpecifier = &route.RouteAction_WeightedClusters{WeightedClusters: weighted}
+	}
+	applyRewrite(action, r)
+	if r.HostRewrite != "" {
+		action.HostRewriteSpecifier = &route.RouteAction_HostRewriteLiteral{HostRewriteLiteral: r.HostRewrite}
+	}
+	if r.Timeout != 0 {
+		action.Timeout = makeTimeout(r.Timeout)
+	}
+	if r.IdleTimeout != 0 {
+		action.IdleTimeout = makeTimeout(r.IdleTimeout)
+	}
+	if len(r.RateLimitDescriptors) > 0 {
+		action.RateLimits = []*route.RateLimit{makeRateLimit(r.RateLimitDescriptors)}
+	}
+	if r.RetryPolicy != nil {
+		action.RetryPolicy = makeRetryPolicy(r.RetryPolicy)
+		if r.RetryPolicy.HedgeOnPerTryTimeout {
+			action.HedgePolicy = &route.HedgePolicy{HedgeOnPerTryTimeout: true}
+		}
+	}
+	if r.WebSocket {
+		action.UpgradeConfigs = append(action.UpgradeConfigs, &route.RouteAction_UpgradeConfig{
+			UpgradeType: webSocketUpgrade,
+			Enabled:     &wrappers.BoolValue{Value: true},
+		})
+	}
+	if r.Connect && l.Connect {
+		action.UpgradeConfigs = append(action.UpgradeConfigs, &route.RouteAction_UpgradeConfig{
+			UpgradeType:   connectUpgrade,
+			Enabled:       &wrappers.BoolValue{Value: true},
+			ConnectConfig: &route.RouteAction_UpgradeConfig_ConnectConfig{},
+		})
+	}
+	rt.Action = &route.Route_Route{Route: action}
+	return rt
+}
+
+// makeTimeout returns a route timeout, zero disabling it in Envoy.
+func makeTimeout(d time.Duration) *duration.Duration {
+	if d == NoTimeout {
+		d = 0
+	}
+	return ptypes.DurationProto(d)
+}
+
+func applyRewrite(action *route.RouteAction, r Route) {
+	switch {
+	case r.RegexRewrite != nil:
+		action.RegexRewrite = makeRegexRewrite(r.RegexRewrite.Pattern, r.RegexRewrite.Substitution)
+	case r.FullPathRewrite != "":
+		action.RegexRewrite = makeRegexRewrite("^/.*$", r.FullPathRewrite)
+	case r.PrefixRewrite == "":
+	case r.Path != "":
+		action.RegexRewrite = makeRegexRewrite("^/.*$", r.PrefixRewrite)
+	default:
+		// prefix_rewrite would leave a double slash behind when replacing a prefix
+		// without a trailing slash, such as /api of /api/users, with "/"
+		prefix := r.PathSeparatedPrefix
+		if prefix == "" {
+			prefix = r.Prefix
+		}
+		pattern := "^" + regexp.QuoteMeta(prefix)
+		if strings.HasSuffix(r.PrefixRewrite, "/") {
+			pattern += "/*"
+		}
+		action.RegexRewrite = makeRegexRewrite(pattern, r.PrefixRewrite)
+	}
+}
+
+func makeRegexRewrite(pattern, substitution string) *matcher.RegexMatchAndSubstitute {
+	return &matcher.RegexMatchAndSubstitute{
+		Pattern: &matcher.RegexMatcher{
+			EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+			Regex:      pattern,
+		},
+		Substitution: substitution,
+	}
+}
+
+func makeLocalRateLimit(l *LocalRateLimit) *any.Any {
+	enabled := &core.RuntimeFractionalPercent{
+		DefaultValue: &typev3.FractionalPercent{Numerator: 100, Denominator: typev3.FractionalPercent_HUNDRED},
+	}
+	config := &localratelimit.LocalRateLimit{
+		StatPrefix: localRateLimitStatPrefix,
+		TokenBucket: &typev3.TokenBucket{
+			MaxTokens:     l.MaxTokens,
+			TokensPerFill: &wrappers.UInt32Value{Value: l.TokensPerFill},
+			FillInterval:  ptypes.DurationProto(l.FillInterval),
+		},
+		FilterEnabled:  enabled,
+		FilterEnforced: enabled,
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+func makeRateLimit(descriptors []RateLimitDescriptor) *route.RateLimit {
+	rl := &route.RateLimit{}
+	for _, d := range descriptors {
+		switch d.Kind {
+		case "remote_address":
+			rl.Actions = append(rl.Actions, &route.RateLimit_Action{
+				ActionSpecifier: &route.RateLimit_Action_RemoteAddress_{
+					RemoteAddress: &route.RateLimit_Action_RemoteAddress{},
+				},
+			})
+		case "header":
+			rl.Actions = append(rl.Actions, &route.RateLimit_Action{
+				ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+					RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+						HeaderName:    d.Header,
+						DescriptorKey: strings.ToLower(d.Header),
+					},
+				},
+			})
+		case "path":
+			rl.Actions = append(rl.Actions, &route.RateLimit_Action{
+				ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+					RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+						HeaderName:    ":path",
+						DescriptorKey: "path",
+					},
+				},
+			})
+		}
+	}
+	return rl
+}
+
+func makeRetryPolicy(rp *RetryPolicy) *route.RetryPolicy {
+	policy := &route.RetryPolicy{
+		RetryOn: rp.RetryOn,
+	}
+	if policy.RetryOn == "" {
+		policy.RetryOn = "connect-failure,refused-stream,unavailable,cancelled,retriable-status-codes"
+	}
+	if rp.NumRetries != 0 {
+		policy.NumRetries = &wrappers.UInt32Value{Value: rp.NumRetries}
+	}
+	if rp.PerTryTimeout != 0 {
+		policy.PerTryTimeout = ptypes.DurationProto(rp.PerTryTimeout)
+	}
+	if rp.BackoffBase != 0 {
+		policy.RetryBackOff = &route.RetryPolicy_RetryBackOff{
+			BaseInterval: ptypes.DurationProto(rp.BackoffBase),
+		}
+		if rp.BackoffMax != 0 {
+			policy.RetryBackOff.MaxInterval = ptypes.DurationProto(rp.BackoffMax)
+		}
+	}
+	return policy
+}
+
+func makeCorsPolicy(c *CorsPolicy) *route.CorsPolicy {
+	policy := &route.CorsPolicy{
+		AllowMethods:  strings.Join(c.AllowMethods, ","),
+		AllowHeaders:  strings.Join(c.AllowHeaders, ","),
+		ExposeHeaders: strings.Join(c.ExposeHeaders, ","),
+	}
+	for _, origin := range c.AllowOrigins {
+		policy.AllowOriginStringMatch = append(policy.AllowOriginStringMatch, &matcher.StringMatcher{
+			MatchPattern: &matcher.StringMatcher_Exact{Exact: origin},
+		})
+	}
+	for _, regex := range c.AllowOriginRegexes {
+		policy.AllowOriginStringMatch = append(policy.AllowOriginStringMatch, &matcher.StringMatcher{
+			MatchPattern: &matcher.StringMatcher_SafeRegex{
+				SafeRegex: &matcher.RegexMatcher{
+					EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+					Regex:      regex,
 				},
 			},
-			Action: &route.Route_Route{
-				Route: &route.RouteAction{
-					ClusterSpecifier: &route.RouteAction_Cluster{
-						Cluster: r.Cluster,
+		})
+	}
+	if c.MaxAge != 0 {
+		policy.MaxAge = strconv.FormatInt(int64(c.MaxAge.Seconds()), 10)
+	}
+	if c.AllowCredentials {
+		policy.AllowCredentials = &wrappers.BoolValue{Value: true}
+	}
+	return policy
+}
+
+func makeHeaderPolicy(h HeaderPolicy) ([]*core.HeaderValueOption, []string) {
+	var add []*core.HeaderValueOption
+	for _, header := range h.Add {
+		add = append(add, &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: header.Name, Value: header.Value},
+			Append: &wrappers.BoolValue{Value: true},
+		})
+	}
+	for _, header := range h.Set {
+		add = append(add, &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: header.Name, Value: header.Value},
+			Append: &wrappers.BoolValue{Value: false},
+		})
+	}
+	return add, h.Remove
+}
+
+func makeHeaderMatcher(m ValueMatch) *route.HeaderMatcher {
+	return &route.HeaderMatcher{
+		Name:                 m.Name,
+		HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: makeValueMatcher(m)},
+	}
+}
+
+func makeValueMatcher(m ValueMatch) *matcher.StringMatcher {
+	if m.Regex {
+		return makeRegexMatcher(m.Value)
+	}
+	return &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: m.Value}}
+}
+
+func makeHashPolicy(hp *HashPolicy) []*route.RouteAction_HashPolicy {
+	if hp == nil {
+		return nil
+	}
+	var policies []*route.RouteAction_HashPolicy
+	if hp.Header != "" {
+		policies = append(policies, &route.RouteAction_HashPolicy{
+			PolicySpecifier: &route.RouteAction_HashPolicy_Header_{
+				Header: &route.RouteAction_HashPolicy_Header{HeaderName: hp.Header},
+			},
+		})
+	}
+	if hp.Cookie != "" {
+		cookie := &route.RouteAction_HashPolicy_Cookie{Name: hp.Cookie}
+		if hp.CookieTTL != 0 {
+			cookie.Ttl = ptypes.DurationProto(hp.CookieTTL)
+		}
+		policies = append(policies, &route.RouteAction_HashPolicy{
+			PolicySpecifier: &route.RouteAction_HashPolicy_Cookie_{Cookie: cookie},
+		})
+	}
+	if hp.SourceIP {
+		policies = append(policies, &route.RouteAction_HashPolicy{
+			PolicySpecifier: &route.RouteAction_HashPolicy_ConnectionProperties_{
+				ConnectionProperties: &route.RouteAction_HashPolicy_ConnectionProperties{SourceIp: true},
+			},
+		})
+	}
+	return policies
+}
+
+// routeSpecificity orders exact paths before prefixes, longer prefixes before
+// shorter ones, and prefixes before regular expressions, as Envoy picks the first
+// route that matches. Among routes of the same path, those matching the method, then
+// more headers, then more query parameters come first.
+func routeSpecificity(r Route) int {
+	conditions := len(r.QueryParamMatches) + len(r.HeaderMatches)<<8
+	if r.Method != "" {
+		conditions += 1 << 16
+	}
+	switch {
+	case r.Path != "":
+		return (1<<16+len(r.Path))<<24 + conditions
+	case r.PathSeparatedPrefix != "":
+		return len(r.PathSeparatedPrefix)<<24 + conditions
+	case r.PathRegex != "":
+		return conditions
+	default:
+		return len(r.Prefix)<<24 + conditions
+	}
+}
+
+// MakeHTTPListener serves plaintext HTTP, or HTTPS when the listener has TLS filter
+// chains, one per set of server names.
+func MakeHTTPListener(l Listener) *listener.Listener {
+	lis := &listener.Listener{
+		Name: l.Name,
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Protocol: core.SocketAddress_TCP,
+					Address:  l.Address,
+					PortSpecifier: &core.SocketAddress_PortValue{
+						PortValue: l.Port,
 					},
+					// accept IPv4 connections too when bound to all IPv6 addresses
+					Ipv4Compat: isIPv6Unspecified(l.Address),
 				},
 			},
+		},
+	}
+
+	// the connections buffer the largest response of the routes
+	if l.MaxResponseBytes > defaultConnectionBufferLimit {
+		lis.PerConnectionBufferLimitBytes = &wrappers.UInt32Value{Value: l.MaxResponseBytes}
+	}
+
+	// the PROXY protocol header comes before anything else on the connection,
+	// including the TLS client hello
+	if l.ProxyProtocol {
+		lis.ListenerFilters = append(lis.ListenerFilters, makeListenerFilter(wellknown.ProxyProtocol, &proxyprotocol.ProxyProtocol{}))
+	}
+
+	if len(l.TLSFilterChains) == 0 {
+		lis.FilterChains = []*listener.FilterChain{{
+			Filters: []*listener.Filter{makeHTTPConnectionManager(l, l.RouteNames[0], nil)},
+		}}
+		return lis
+	}
+
+	lis.ListenerFilters = append(lis.ListenerFilters, makeListenerFilter(wellknown.TLSInspector, &tlsinspector.TlsInspector{}))
+	for _, chain := range l.TLSFilterChains {
+		fc := &listener.FilterChain{
+			Filters:         []*listener.Filter{makeHTTPConnectionManager(l, tlsRouteName(l, chain), chain.ClientValidation)},
+			TransportSocket: makeDownstreamTLSTransportSocket(chain),
+		}
+		if len(chain.ServerNames) > 0 {
+			fc.FilterChainMatch = &listener.FilterChainMatch{ServerNames: chain.ServerNames}
+		}
+		lis.FilterChains = append(lis.FilterChains, fc)
+	}
+	return lis
+}
+
+// isIPv6Unspecified reports whether address is the IPv6 unspecified address, in any
+// of its spellings such as :: or 0:0::0.
+func isIPv6Unspecified(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil && ip.IsUnspecified()
+}
+
+func makeTracing(t *Tracing) *hcm.HttpConnectionManager_Tracing {
+	if t == nil {
+		return nil
+	}
+	var name string
+	var config proto.Message
+	switch t.Provider {
+	case "zipkin":
+		name = wellknown.Zipkin
+		config = &trace.ZipkinConfig{
+			CollectorCluster:         t.Cluster,
+			CollectorHostname:        t.Hostname,
+			CollectorEndpoint:        "/api/v2/spans",
+			CollectorEndpointVersion: trace.ZipkinConfig_HTTP_JSON,
+			TraceId_128Bit:           true,
+		}
+	default:
+		name = openTelemetryTracer
+		config = &trace.OpenTelemetryConfig{
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: t.Cluster},
+				},
+			},
+		}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return &hcm.HttpConnectionManager_Tracing{
+		RandomSampling: &typev3.Percent{Value: t.Sampling},
+		CustomTags:     makeCustomTags(t.Tags),
+		Provider: &trace.Tracing_Http{
+			Name:       name,
+			ConfigType: &trace.Tracing_Http_TypedConfig{TypedConfig: pbst},
+		},
+	}
+}
+
+func makeRouteTracing(t *RouteTracing) *route.Tracing {
+	if t == nil {
+		return nil
+	}
+	tracing := &route.Tracing{CustomTags: makeCustomTags(t.Tags)}
+	if t.Sampling != nil {
+		// a million parts keep four decimals of the percentage
+		tracing.RandomSampling = &typev3.FractionalPercent{
+			Numerator:   uint32(*t.Sampling * 10000),
+			Denominator: typev3.FractionalPercent_MILLION,
+		}
+	}
+	return tracing
+}
+
+func makeCustomTags(tags []TracingTag) []*tracingtype.CustomTag {
+	var customTags []*tracingtype.CustomTag
+	for _, t := range tags {
+		tag := &tracingtype.CustomTag{
+			Tag: t.Name,
+			Type: &tracingtype.CustomTag_Literal_{
+				Literal: &tracingtype.CustomTag_Literal{Value: t.Literal},
+			},
+		}
+		if t.Header != "" {
+			tag.Type = &tracingtype.CustomTag_RequestHeader{
+				RequestHeader: &tracingtype.CustomTag_Header{Name: t.Header},
+			}
+		}
+		customTags = append(customTags, tag)
+	}
+	return customTags
+}
+
+func makeAccessLogs(l Listener) []*accesslog.AccessLog {
+	var logs []*accesslog.AccessLog
+	for _, a := range l.AccessLogs {
+		var name string
+		var config proto.Message
+		if a.GRPCCluster != "" {
+			name = wellknown.HTTPGRPCAccessLog
+			config = &grpcaccesslog.HttpGrpcAccessLogConfig{
+				CommonConfig: &grpcaccesslog.CommonGrpcAccessLogConfig{
+					LogName: l.Name,
+					GrpcService: &core.GrpcService{
+						TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: a.GRPCCluster},
+						},
+					},
+					TransportApiVersion: resource.DefaultAPIVersion,
+				},
+			}
+		} else {
+			name = wellknown.FileAccessLog
+			format := &core.SubstitutionFormatString{
+				Format: &core.SubstitutionFormatString_TextFormat{TextFormat: a.TextFormat},
+			}
+			if len(a.JSONFormat) > 0 {
+				format.Format = &core.SubstitutionFormatString_JsonFormat{JsonFormat: makeStringStruct(a.JSONFormat)}
+			}
+			config = &fileaccesslog.FileAccessLog{
+				Path:            a.Path,
+				AccessLogFormat: &fileaccesslog.FileAccessLog_LogFormat{LogFormat: format},
+			}
+		}
+		pbst, err := ptypes.MarshalAny(config)
+		if err != nil {
+			panic(err)
+		}
+		logs = append(logs, &accesslog.AccessLog{
+			Name:       name,
+			ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: pbst},
 		})
 	}
+	return logs
+}
 
-	return &route.RouteConfiguration{
-		Name: "listener_0",
-		VirtualHosts: []*route.VirtualHost{{
-			Name:    "local_service",
-			Domains: []string{"*"},
-			Routes:  rts,
-		}},
+func makeOriginMetadata(o *Origin) *core.Metadata {
+	if o == nil {
+		return nil
+	}
+	return &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			MetadataNamespace: makeStringStruct(map[string]string{
+				"kind":      o.Kind,
+				"namespace": o.Namespace,
+				"name":      o.Name,
+				"uid":       o.UID,
+			}),
+		},
+	}
+}
+
+func makeStringStruct(fields map[string]string) *structpb.Struct {
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	for k, v := range fields {
+		s.Fields[k] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}
 	}
+	return s
 }
 
-func MakeHTTPListener(listenerName, route, address string, port uint32) *listener.Listener {
+func makeListenerFilter(name string, config proto.Message) *listener.ListenerFilter {
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return &listener.ListenerFilter{
+		Name: name,
+		ConfigType: &listener.ListenerFilter_TypedConfig{
+			TypedConfig: pbst,
+		},
+	}
+}
+
+func makeHTTPConnectionManager(l Listener, routeName string, clientValidation *ClientValidation) *listener.Filter {
 	// HTTP filter configuration
 	manager := &hcm.HttpConnectionManager{
 		CodecType:  hcm.HttpConnectionManager_AUTO,
-		StatPrefix: "http",
+		StatPrefix: l.Name,
 		RouteSpecifier: &hcm.HttpConnectionManager_Rds{
 			Rds: &hcm.Rds{
 				ConfigSource:    makeConfigSource(),
-				RouteConfigName: "listener_0",
+				RouteConfigName: routeName,
 			},
 		},
-		HttpFilters: []*hcm.HttpFilter{{
-			Name: wellknown.Router,
+		HttpFilters: makeHTTPFilters(l),
+		// upgrades are allowed per route
+		UpgradeConfigs: []*hcm.HttpConnectionManager_UpgradeConfig{{
+			UpgradeType: webSocketUpgrade,
+			Enabled:     &wrappers.BoolValue{Value: false},
 		}},
+		AccessLog:                 makeAccessLogs(l),
+		Tracing:                   makeTracing(l.Tracing),
+		GenerateRequestId:         &wrappers.BoolValue{Value: l.GenerateRequestID},
+		PreserveExternalRequestId: l.PreserveExternalRequestID,
+		// the client address is the peer of the connection, or the entry of
+		// X-Forwarded-For added by the outermost trusted proxy
+		UseRemoteAddress:  &wrappers.BoolValue{Value: l.UseRemoteAddress},
+		XffNumTrustedHops: l.XFFNumTrustedHops,
+		LocalReplyConfig:  makeLocalReplyConfig(l.ErrorPageRoutes),
+	}
+	if l.Connect {
+		manager.UpgradeConfigs = append(manager.UpgradeConfigs, &hcm.HttpConnectionManager_UpgradeConfig{
+			UpgradeType: connectUpgrade,
+			Enabled:     &wrappers.BoolValue{Value: false},
+		})
+		manager.Http2ProtocolOptions = &core.Http2ProtocolOptions{AllowConnect: true}
+	}
+	if clientValidation != nil {
+		// replace whatever the client sent with the subject of the verified certificate
+		manager.ForwardClientCertDetails = hcm.HttpConnectionManager_SANITIZE_SET
+		manager.SetCurrentClientCertDetails = &hcm.HttpConnectionManager_SetCurrentClientCertDetails{
+			Subject: &wrappers.BoolValue{Value: true},
+		}
 	}
 	pbst, err := ptypes.MarshalAny(manager)
 	if err != nil {
 		panic(err)
 	}
+	return &listener.Filter{
+		Name: wellknown.HTTPConnectionManager,
+		ConfigType: &listener.Filter_TypedConfig{
+			TypedConfig: pbst,
+		},
+	}
+}
 
-	return &listener.Listener{
-		Name: listenerName,
-		Address: &core.Address{
-			Address: &core.Address_SocketAddress{
-				SocketAddress: &core.SocketAddress{
-					Protocol: core.SocketAddress_TCP,
-					Address:  address,
-					PortSpecifier: &core.SocketAddress_PortValue{
-						PortValue: port,
+// makeHTTPFilters returns the HTTP filters of a listener in the order they run. The
+// filters are configured per virtual host or route, so they are installed on every
+// listener and do nothing where no policy applies.
+func makeHTTPFilters(l Listener) []*hcm.HttpFilter {
+	filters := []*hcm.HttpFilter{
+		makeHTTPFilter(wellknown.CORS, &corsfilter.Cors{}),
+	}
+	if l.IPFiltering {
+		filters = append(filters, makeHTTPFilter(wellknown.HTTPRoleBasedAccessControl, &rbacfilter.RBAC{}))
+	}
+	if len(l.BasicAuth) > 0 {
+		filters = append(filters, makeHTTPFilter(wellknown.Lua, makeBasicAuth(l.BasicAuth)))
+	}
+	if len(l.JWTProviders) > 0 {
+		filters = append(filters, makeHTTPFilter(jwtAuthnFilter, makeJWTAuthn(l.JWTProviders)))
+	}
+	for _, authz := range l.ExtAuthz {
+		filters = append(filters, makeHTTPFilter(authz.Name, makeExtAuthz(authz)))
+	}
+	filters = append(filters, makeHTTPFilter(localRateLimitFilter, &localratelimit.LocalRateLimit{StatPrefix: localRateLimitStatPrefix}))
+	if rls := l.RateLimitService; rls != nil {
+		filters = append(filters, makeHTTPFilter(wellknown.HTTPRateLimit, &ratelimit.RateLimit{
+			Domain:          rls.Domain,
+			FailureModeDeny: rls.FailureModeDeny,
+			RateLimitService: &ratelimitconfig.RateLimitServiceConfig{
+				GrpcService: &core.GrpcService{
+					TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+						EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: rls.Cluster},
+					},
+				},
+				TransportApiVersion: resource.DefaultAPIVersion,
+			},
+		}))
+	}
+	if l.Buffering {
+		// every route sets its own limit through makeBufferPerRoute, the default only
+		// applies to requests matching no route
+		filters = append(filters, makeHTTPFilter(wellknown.Buffer, &buffer.Buffer{
+			MaxRequestBytes: &wrappers.UInt32Value{Value: defaultMaxRequestBytes},
+		}))
+	}
+	if len(l.Compression) > 0 {
+		filters = append(filters, makeCompressors(l.Compression)...)
+	}
+	// error pages replace the responses of the backends before they are compressed
+	if len(l.ErrorPageRoutes) > 0 {
+		filters = append(filters, makeHTTPFilter(errorPagesFilter, makeErrorPages()))
+	}
+	if l.WeightedDirectResponses {
+		filters = append(filters, makeHTTPFilter(directResponseFilter, makeDirectResponse()))
+	}
+	// responses are buffered as they come from the backend, before compression
+	if l.MaxResponseBytes > 0 {
+		filters = append(filters, makeHTTPFilter(responseBufferFilter, makeResponseBuffer()))
+	}
+	return append(filters, makeHTTPFilter(wellknown.Router, &router.Router{}))
+}
+
+func makeBufferPerRoute(maxRequestBytes uint32) *any.Any {
+	config := &buffer.BufferPerRoute{Override: &buffer.BufferPerRoute_Disabled{Disabled: true}}
+	if maxRequestBytes > 0 {
+		config.Override = &buffer.BufferPerRoute_Buffer{Buffer: &buffer.Buffer{
+			MaxRequestBytes: &wrappers.UInt32Value{Value: maxRequestBytes},
+		}}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+func makeExtAuthz(a ExtAuthz) *extauthz.ExtAuthz {
+	timeout := a.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	config := &extauthz.ExtAuthz{
+		FailureModeAllow:    a.FailureModeAllow,
+		TransportApiVersion: resource.DefaultAPIVersion,
+	}
+	if a.GRPC {
+		config.Services = &extauthz.ExtAuthz_GrpcService{
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: a.Cluster},
+				},
+				Timeout: ptypes.DurationProto(timeout),
+			},
+		}
+		return config
+	}
+
+	service := &extauthz.HttpService{
+		ServerUri: &core.HttpUri{
+			Uri:              "http://" + a.Cluster,
+			HttpUpstreamType: &core.HttpUri_Cluster{Cluster: a.Cluster},
+			Timeout:          ptypes.DurationProto(timeout),
+		},
+		PathPrefix: a.PathPrefix,
+	}
+	if len(a.RequestHeaders) > 0 {
+		service.AuthorizationRequest = &extauthz.AuthorizationRequest{
+			AllowedHeaders: makeExactListMatcher(a.RequestHeaders),
+		}
+	}
+	if len(a.UpstreamHeaders) > 0 {
+		service.AuthorizationResponse = &extauthz.AuthorizationResponse{
+			AllowedUpstreamHeaders: makeExactListMatcher(a.UpstreamHeaders),
+		}
+	}
+	config.Services = &extauthz.ExtAuthz_HttpService{HttpService: service}
+	return config
+}
+
+// makeIPFilter allows the clients matching the filter through a single RBAC policy,
+// rejecting everyone else with a 403.
+func makeIPFilter(f *IPFilter) *any.Any {
+	var principals []*rbac.Principal
+	if len(f.Allow) > 0 {
+		principals = append(principals, makeRemoteIPPrincipal(f.Allow))
+	}
+	if len(f.Deny) > 0 {
+		principals = append(principals, &rbac.Principal{
+			Identifier: &rbac.Principal_NotId{NotId: makeRemoteIPPrincipal(f.Deny)},
+		})
+	}
+	principal := &rbac.Principal{Identifier: &rbac.Principal_Any{Any: true}}
+	if len(principals) > 0 {
+		principal = &rbac.Principal{
+			Identifier: &rbac.Principal_AndIds{AndIds: &rbac.Principal_Set{Ids: principals}},
+		}
+	}
+	pbst, err := ptypes.MarshalAny(&rbacfilter.RBACPerRoute{
+		Rbac: &rbacfilter.RBAC{
+			Rules: &rbac.RBAC{
+				Action: rbac.RBAC_ALLOW,
+				Policies: map[string]*rbac.Policy{
+					"ip-filter": {
+						Permissions: []*rbac.Permission{{Rule: &rbac.Permission_Any{Any: true}}},
+						Principals:  []*rbac.Principal{principal},
 					},
 				},
 			},
 		},
-		FilterChains: []*listener.FilterChain{{
-			Filters: []*listener.Filter{{
-				Name: wellknown.HTTPConnectionManager,
-				ConfigType: &listener.Filter_TypedConfig{
-					TypedConfig: pbst,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+func makeRemoteIPPrincipal(cidrs []CIDR) *rbac.Principal {
+	var ids []*rbac.Principal
+	for _, c := range cidrs {
+		ids = append(ids, &rbac.Principal{
+			Identifier: &rbac.Principal_RemoteIp{RemoteIp: &core.CidrRange{
+				AddressPrefix: c.Address,
+				PrefixLen:     &wrappers.UInt32Value{Value: c.PrefixLen},
+			}},
+		})
+	}
+	return &rbac.Principal{Identifier: &rbac.Principal_OrIds{OrIds: &rbac.Principal_Set{Ids: ids}}}
+}
+
+// makeJWTAuthn requires nothing by default: the routes pick their provider by name
+// through makeJWTPerRoute. Token payloads are stored in the dynamic metadata of the
+// filter under JWTPayloadMetadataKey, where route header formatters can read claims.
+func makeJWTAuthn(providers []JWTProvider) *jwtauthn.JwtAuthentication {
+	config := &jwtauthn.JwtAuthentication{
+		Providers:      map[string]*jwtauthn.JwtProvider{},
+		RequirementMap: map[string]*jwtauthn.JwtRequirement{},
+	}
+	for _, p := range providers {
+		provider := &jwtauthn.JwtProvider{
+			Issuer:            p.Issuer,
+			Audiences:         p.Audiences,
+			Forward:           p.Forward,
+			PayloadInMetadata: JWTPayloadMetadataKey,
+		}
+		if p.RemoteJWKS != nil {
+			remote := &jwtauthn.RemoteJwks{
+				HttpUri: &core.HttpUri{
+					Uri:              p.RemoteJWKS.URI,
+					HttpUpstreamType: &core.HttpUri_Cluster{Cluster: p.RemoteJWKS.Cluster},
+					Timeout:          ptypes.DurationProto(5 * time.Second),
+				},
+				AsyncFetch: &jwtauthn.JwksAsyncFetch{},
+			}
+			if p.RemoteJWKS.CacheDuration > 0 {
+				remote.CacheDuration = ptypes.DurationProto(p.RemoteJWKS.CacheDuration)
+			}
+			provider.JwksSourceSpecifier = &jwtauthn.JwtProvider_RemoteJwks{RemoteJwks: remote}
+		} else {
+			provider.JwksSourceSpecifier = &jwtauthn.JwtProvider_LocalJwks{
+				LocalJwks: &core.DataSource{
+					Specifier: &core.DataSource_InlineString{InlineString: p.LocalJWKS},
 				},
+			}
+		}
+		config.Providers[p.Name] = provider
+		config.RequirementMap[p.Name] = &jwtauthn.JwtRequirement{
+			RequiresType: &jwtauthn.JwtRequirement_ProviderName{ProviderName: p.Name},
+		}
+	}
+	return config
+}
+
+func makeJWTPerRoute(provider *JWTProvider) *any.Any {
+	config := &jwtauthn.PerRouteConfig{
+		RequirementSpecifier: &jwtauthn.PerRouteConfig_Disabled{Disabled: true},
+	}
+	if provider != nil {
+		config.RequirementSpecifier = &jwtauthn.PerRouteConfig_RequirementName{RequirementName: provider.Name}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
+
+func makeExactListMatcher(values []string) *matcher.ListStringMatcher {
+	list := &matcher.ListStringMatcher{}
+	for _, v := range values {
+		list.Patterns = append(list.Patterns, &matcher.StringMatcher{
+			MatchPattern: &matcher.StringMatcher_Exact{Exact: v},
+			IgnoreCase:   true,
+		})
+	}
+	return list
+}
+
+func makeHTTPFilter(name string, config proto.Message) *hcm.HttpFilter {
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return &hcm.HttpFilter{
+		Name: name,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: pbst,
+		},
+	}
+}
+
+func makeDownstreamTLSTransportSocket(chain TLSFilterChain) *core.TransportSocket {
+	tlsContext := &tls.DownstreamTlsContext{
+		CommonTlsContext: &tls.CommonTlsContext{
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{{
+				Name:      chain.Certificate,
+				SdsConfig: makeConfigSource(),
 			}},
-		}},
+		},
+	}
+	if cv := chain.ClientValidation; cv != nil {
+		validation := &tls.CertificateValidationContext{
+			TrustedCa: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: cv.CACert},
+			},
+		}
+		if len(cv.CRL) > 0 {
+			validation.Crl = &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: cv.CRL},
+			}
+		}
+		if cv.VerifyDepth != 0 {
+			validation.MaxVerifyDepth = &wrappers.UInt32Value{Value: cv.VerifyDepth}
+		}
+		switch cv.Mode {
+		case "optional_no_ca":
+			validation.TrustChainVerification = tls.CertificateValidationContext_ACCEPT_UNTRUSTED
+		case "optional":
+		default:
+			tlsContext.RequireClientCertificate = &wrappers.BoolValue{Value: true}
+		}
+		tlsContext.CommonTlsContext.ValidationContextType = &tls.CommonTlsContext_ValidationContext{
+			ValidationContext: validation,
+		}
+	}
+	pbst, err := ptypes.MarshalAny(tlsContext)
+	if err != nil {
+		panic(err)
+	}
+	return &core.TransportSocket{
+		Name: wellknown.TransportSocketTLS,
+		ConfigType: &core.TransportSocket_TypedConfig{
+			TypedConfig: pbst,
+		},
 	}
 }
+
 func makeConfigSource() *core.ConfigSource {
 	source := &core.ConfigSource{}
 	source.ResourceApiVersion = resource.DefaultAPIVersion
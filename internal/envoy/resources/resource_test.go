@@ -0,0 +1,1297 @@
+package resources
+
+import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	trace "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	grpcaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	jwtauthn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	rbacfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakeEndpoint(t *testing.T) {
+	eps := []Endpoint{
+		{UpstreamHost: "10.0.0.1", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1b"},
+		{UpstreamHost: "10.0.0.2", UpstreamPort: 8080},
+		{UpstreamHost: "10.0.0.3", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1a"},
+		{UpstreamHost: "10.0.0.4", UpstreamPort: 8080, Region: "eu-west-1", Zone: "eu-west-1b"},
+	}
+	want := []struct {
+		region, zone string
+		hosts        []string
+	}{
+		{hosts: []string{"10.0.0.2"}},
+		{region: "eu-west-1", zone: "eu-west-1a", hosts: []string{"10.0.0.3"}},
+		{region: "eu-west-1", zone: "eu-west-1b", hosts: []string{"10.0.0.1", "10.0.0.4"}},
+	}
+
+	cla := MakeEndpoint("default/web/80", eps)
+	if len(cla.Endpoints) != len(want) {
+		t.Fatalf("got %d localities, want %d", len(cla.Endpoints), len(want))
+	}
+	for i, locality := range cla.Endpoints {
+		w := want[i]
+		if locality.GetLocality().GetRegion() != w.region || locality.GetLocality().GetZone() != w.zone {
+			t.Errorf("locality %d is %v, want %s/%s", i, locality.GetLocality(), w.region, w.zone)
+		}
+		if w.region == "" && locality.Locality != nil {
+			t.Errorf("locality %d of endpoints without a zone is %v, want none", i, locality.Locality)
+		}
+		if got := locality.LoadBalancingWeight.GetValue(); got != uint32(len(w.hosts)) {
+			t.Errorf("locality %d weighs %d, want %d", i, got, len(w.hosts))
+		}
+		var hosts []string
+		for _, ep := range locality.LbEndpoints {
+			hosts = append(hosts, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		if !reflect.DeepEqual(hosts, w.hosts) {
+			t.Errorf("locality %d has endpoints %v, want %v", i, hosts, w.hosts)
+		}
+	}
+}
+
+func TestMakeCluster(t *testing.T) {
+	tests := []struct {
+		name         string
+		cluster      Cluster
+		discovery    cluster.Cluster_DiscoveryType
+		family       cluster.Cluster_DnsLookupFamily
+		lbPolicy     cluster.Cluster_LbPolicy
+		connect      time.Duration
+		loadAssigned bool
+		localityLb   bool
+		zoneAwareLb  bool
+		breakers     bool
+		protocolOpts bool
+		healthCheck  bool
+		outlier      bool
+		tls          bool
+	}{
+		{
+			name:      "defaults",
+			cluster:   Cluster{Name: "default/web/80"},
+			discovery: cluster.Cluster_EDS,
+			family:    cluster.Cluster_V4_ONLY,
+			lbPolicy:  cluster.Cluster_ROUND_ROBIN,
+			connect:   5 * time.Second,
+		},
+		{
+			name: "load balancing and limits",
+			cluster: Cluster{
+				Name:                     "default/web/80",
+				LbPolicy:                 "maglev",
+				ConnectTimeout:           time.Second,
+				MaxRequestsPerConnection: 100,
+				CircuitBreakers:          CircuitBreakers{MaxConnections: 10},
+			},
+			discovery:    cluster.Cluster_EDS,
+			family:       cluster.Cluster_V4_ONLY,
+			lbPolicy:     cluster.Cluster_MAGLEV,
+			connect:      time.Second,
+			breakers:     true,
+			protocolOpts: true,
+		},
+		{
+			name: "strict DNS",
+			cluster: Cluster{
+				Name:            "default/ext/443",
+				Type:            StrictDNSCluster,
+				DNSLookupFamily: "v6_only",
+				Endpoints:       []Endpoint{{UpstreamHost: "example.com", UpstreamPort: 443}},
+				UpstreamTLS:     &UpstreamTLS{SNI: "example.com"},
+			},
+			discovery:    cluster.Cluster_STRICT_DNS,
+			family:       cluster.Cluster_V6_ONLY,
+			lbPolicy:     cluster.Cluster_ROUND_ROBIN,
+			connect:      5 * time.Second,
+			loadAssigned: true,
+			tls:          true,
+		},
+		{
+			name:         "logical DNS",
+			cluster:      Cluster{Name: "default/ext/80", Type: LogicalDNSCluster, DNSLookupFamily: "all"},
+			discovery:    cluster.Cluster_LOGICAL_DNS,
+			family:       cluster.Cluster_ALL,
+			lbPolicy:     cluster.Cluster_ROUND_ROBIN,
+			connect:      5 * time.Second,
+			loadAssigned: true,
+		},
+		{
+			name: "health checked",
+			cluster: Cluster{
+				Name:             "default/grpc/80",
+				HealthCheck:      &HealthCheck{Protocol: "grpc"},
+				OutlierDetection: &OutlierDetection{Consecutive5xx: 5},
+				HTTP2:            true,
+			},
+			discovery:    cluster.Cluster_EDS,
+			family:       cluster.Cluster_V4_ONLY,
+			lbPolicy:     cluster.Cluster_ROUND_ROBIN,
+			connect:      5 * time.Second,
+			protocolOpts: true,
+			healthCheck:  true,
+			outlier:      true,
+		},
+		{
+			name:       "locality weights",
+			cluster:    Cluster{Name: "default/web/80", LocalityWeightedLb: true},
+			discovery:  cluster.Cluster_EDS,
+			family:     cluster.Cluster_V4_ONLY,
+			lbPolicy:   cluster.Cluster_ROUND_ROBIN,
+			connect:    5 * time.Second,
+			localityLb: true,
+		},
+		{
+			name:        "zone-aware",
+			cluster:     Cluster{Name: "default/web/80", ZoneAwareLb: true},
+			discovery:   cluster.Cluster_EDS,
+			family:      cluster.Cluster_V4_ONLY,
+			lbPolicy:    cluster.Cluster_ROUND_ROBIN,
+			connect:     5 * time.Second,
+			zoneAwareLb: true,
+		},
+		{
+			name:       "locality weights win over zone-aware",
+			cluster:    Cluster{Name: "default/web/80", LocalityWeightedLb: true, ZoneAwareLb: true},
+			discovery:  cluster.Cluster_EDS,
+			family:     cluster.Cluster_V4_ONLY,
+			lbPolicy:   cluster.Cluster_ROUND_ROBIN,
+			connect:    5 * time.Second,
+			localityLb: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MakeCluster(tt.cluster)
+			if c.GetName() != tt.cluster.Name {
+				t.Errorf("name %q, want %q", c.GetName(), tt.cluster.Name)
+			}
+			if got := c.GetType(); got != tt.discovery {
+				t.Errorf("discovery type %v, want %v", got, tt.discovery)
+			}
+			if got := c.GetDnsLookupFamily(); got != tt.family {
+				t.Errorf("DNS lookup family %v, want %v", got, tt.family)
+			}
+			if got := c.GetLbPolicy(); got != tt.lbPolicy {
+				t.Errorf("LB policy %v, want %v", got, tt.lbPolicy)
+			}
+			if got := c.GetConnectTimeout().AsDuration(); got != tt.connect {
+				t.Errorf("connect timeout %v, want %v", got, tt.connect)
+			}
+			if got := c.GetCircuitBreakers() != nil; got != tt.breakers {
+				t.Errorf("circuit breakers %v, want %v", got, tt.breakers)
+			}
+			if got := len(c.GetTypedExtensionProtocolOptions()) > 0; got != tt.protocolOpts {
+				t.Errorf("HTTP protocol options %v, want %v", got, tt.protocolOpts)
+			}
+			if got := len(c.GetHealthChecks()) > 0; got != tt.healthCheck {
+				t.Errorf("health check %v, want %v", got, tt.healthCheck)
+			}
+			if got := c.GetOutlierDetection() != nil; got != tt.outlier {
+				t.Errorf("outlier detection %v, want %v", got, tt.outlier)
+			}
+			if got := c.GetTransportSocket() != nil; got != tt.tls {
+				t.Errorf("transport socket %v, want %v", got, tt.tls)
+			}
+			if got := c.GetLoadAssignment() != nil; got != tt.loadAssigned {
+				t.Errorf("load assignment %v, want %v", got, tt.loadAssigned)
+			}
+			if got := c.GetCommonLbConfig().GetLocalityWeightedLbConfig() != nil; got != tt.localityLb {
+				t.Errorf("locality weighted LB %v, want %v", got, tt.localityLb)
+			}
+			if got := c.GetCommonLbConfig().GetZoneAwareLbConfig() != nil; got != tt.zoneAwareLb {
+				t.Errorf("zone-aware LB %v, want %v", got, tt.zoneAwareLb)
+			}
+		})
+	}
+}
+
+func TestMakeHealthCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check HealthCheck
+		want  string
+	}{
+		{name: "HTTP", check: HealthCheck{Protocol: "http", Path: "/healthz"}, want: "/healthz"},
+		{name: "HTTP root", check: HealthCheck{Protocol: "http"}, want: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := makeHealthCheck(&tt.check, "")
+			if got := hc.GetHttpHealthCheck().GetPath(); got != tt.want {
+				t.Errorf("got path %q, want %q", got, tt.want)
+			}
+			if hc.Interval.AsDuration() != 10*time.Second || hc.Timeout.AsDuration() != 5*time.Second {
+				t.Errorf("got interval %v and timeout %v, want the defaults", hc.Interval, hc.Timeout)
+			}
+			if hc.HealthyThreshold.GetValue() != 2 || hc.UnhealthyThreshold.GetValue() != 3 {
+				t.Errorf("got thresholds %v and %v, want the defaults", hc.HealthyThreshold, hc.UnhealthyThreshold)
+			}
+		})
+	}
+	if makeHealthCheck(&HealthCheck{Protocol: "tcp"}, "").GetTcpHealthCheck() == nil {
+		t.Error("tcp health check has no TCP checker")
+	}
+}
+
+func TestMakeUpstreamTLSTransportSocket(t *testing.T) {
+	tests := []struct {
+		name       string
+		tls        UpstreamTLS
+		wantVerify bool
+		wantSAN    string
+		wantSDS    string
+	}{
+		{name: "unverified", tls: UpstreamTLS{SNI: "web.internal"}},
+		{name: "verified", tls: UpstreamTLS{SNI: "web.internal", CACert: []byte("CA")}, wantVerify: true, wantSAN: "web.internal"},
+		{name: "client certificate", tls: UpstreamTLS{ClientCertificate: &Secret{Name: "default.client"}}, wantSDS: "default.client"},
+		{name: "CA file", tls: UpstreamTLS{SNI: "issuer.example.com", CAFile: "/etc/ssl/certs/ca-certificates.crt"}, wantVerify: true, wantSAN: "issuer.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &tls.UpstreamTlsContext{}
+			if err := makeUpstreamTLSTransportSocket(&tt.tls).GetTypedConfig().UnmarshalTo(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Sni != tt.tls.SNI {
+				t.Errorf("got SNI %q, want %q", ctx.Sni, tt.tls.SNI)
+			}
+			validation := ctx.CommonTlsContext.GetValidationContext()
+			if got := validation != nil; got != tt.wantVerify {
+				t.Errorf("got validation %v, want %v", validation, tt.wantVerify)
+			}
+			var san string
+			if sans := validation.GetMatchTypedSubjectAltNames(); len(sans) > 0 {
+				san = sans[0].Matcher.GetExact()
+			}
+			if got := validation.GetTrustedCa().GetFilename(); got != tt.tls.CAFile {
+				t.Errorf("got CA file %q, want %q", got, tt.tls.CAFile)
+			}
+			if san != tt.wantSAN {
+				t.Errorf("got SAN %q, want %q", san, tt.wantSAN)
+			}
+			var sds string
+			if configs := ctx.CommonTlsContext.TlsCertificateSdsSecretConfigs; len(configs) > 0 {
+				sds = configs[0].Name
+			}
+			if sds != tt.wantSDS {
+				t.Errorf("got SDS secret %q, want %q", sds, tt.wantSDS)
+			}
+		})
+	}
+}
+
+func TestApplyRewrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		path  string
+		want  string
+	}{
+		{name: "no rewrite", route: Route{Prefix: "/api"}, path: "/api/users", want: "/api/users"},
+		{name: "strip prefix", route: Route{Prefix: "/api", PrefixRewrite: "/"}, path: "/api/users", want: "/users"},
+		{name: "strip prefix with slash", route: Route{Prefix: "/api/", PrefixRewrite: "/"}, path: "/api/users", want: "/users"},
+		{name: "strip prefix of the prefix", route: Route{Prefix: "/api", PrefixRewrite: "/"}, path: "/api", want: "/"},
+		{name: "replace prefix", route: Route{Prefix: "/api", PrefixRewrite: "/v2/"}, path: "/api/users", want: "/v2/users"},
+		{name: "replace prefix without slash", route: Route{Prefix: "/api", PrefixRewrite: "/v2"}, path: "/api/users", want: "/v2/users"},
+		{name: "root prefix", route: Route{Prefix: "/", PrefixRewrite: "/app/"}, path: "/users", want: "/app/users"},
+		{
+			name:  "strip path separated prefix",
+			route: Route{PathSeparatedPrefix: "/api", PrefixRewrite: "/"},
+			path:  "/api/users",
+			want:  "/users",
+		},
+		{name: "exact path", route: Route{Path: "/login", PrefixRewrite: "/auth/login"}, path: "/login", want: "/auth/login"},
+		{name: "full path", route: Route{Prefix: "/api", FullPathRewrite: "/status"}, path: "/api/users", want: "/status"},
+		{
+			name:  "regex",
+			route: Route{Prefix: "/", RegexRewrite: &RegexRewrite{Pattern: "^/v1/", Substitution: "/v2/"}},
+			path:  "/v1/users",
+			want:  "/v2/users",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := &route.RouteAction{}
+			applyRewrite(action, tt.route)
+			got := tt.path
+			switch {
+			case action.RegexRewrite != nil:
+				re := regexp.MustCompile(action.RegexRewrite.Pattern.Regex)
+				got = re.ReplaceAllLiteralString(tt.path, action.RegexRewrite.Substitution)
+			case action.PrefixRewrite != "":
+				got = action.PrefixRewrite + strings.TrimPrefix(tt.path, tt.route.Prefix)
+			}
+			if got != tt.want {
+				t.Errorf("%s rewritten to %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeRoute(t *testing.T) {
+	exact := func(name, value string) *route.HeaderMatcher {
+		return makeHeaderMatcher(ValueMatch{Name: name, Value: value})
+	}
+
+	tests := []struct {
+		name     string
+		route    Route
+		match    *route.RouteMatch
+		cluster  string
+		hashes   int
+		action   *route.RouteAction
+		tls      bool
+		redirect bool
+		hsts     string
+		status   uint32
+	}{
+		{
+			name:    "prefix",
+			route:   Route{Prefix: "/", Cluster: "default/web/80"},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			cluster: "default/web/80",
+		},
+		{
+			name:    "exact path",
+			route:   Route{Path: "/login", Cluster: "default/web/80"},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Path{Path: "/login"}},
+			cluster: "default/web/80",
+		},
+		{
+			name:    "path segment prefix",
+			route:   Route{PathSeparatedPrefix: "/api", Cluster: "default/web/80"},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: "/api"}},
+			cluster: "default/web/80",
+		},
+		{
+			name:    "path regex",
+			route:   Route{PathRegex: "/v[0-9]+", Cluster: "default/web/80"},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_SafeRegex{SafeRegex: makeRegexMatcher("/v[0-9]+").GetSafeRegex()}},
+			cluster: "default/web/80",
+		},
+		{
+			name: "method, headers and query parameters",
+			route: Route{
+				Prefix:            "/",
+				Method:            "POST",
+				HeaderMatches:     []ValueMatch{{Name: "x-version", Value: "2"}},
+				QueryParamMatches: []ValueMatch{{Name: "debug", Value: "1"}},
+				Cluster:           "default/web/80",
+			},
+			match: &route.RouteMatch{
+				PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+				Headers:       []*route.HeaderMatcher{exact(":method", "POST"), exact("x-version", "2")},
+				QueryParameters: []*route.QueryParameterMatcher{{
+					Name:                         "debug",
+					QueryParameterMatchSpecifier: &route.QueryParameterMatcher_StringMatch{StringMatch: makeValueMatcher(ValueMatch{Value: "1"})},
+				}},
+			},
+			cluster: "default/web/80",
+		},
+		{
+			name:  "weighted clusters",
+			route: Route{Prefix: "/", WeightedClusters: []WeightedCluster{{Name: "default/web/80", Weight: 90}, {Name: "default/api/80", Weight: 10}}},
+			match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			action: &route.RouteAction{ClusterSpecifier: &route.RouteAction_WeightedClusters{WeightedClusters: &route.WeightedCluster{
+				Clusters: []*route.WeightedCluster_ClusterWeight{
+					{Name: "default/web/80", Weight: &wrappers.UInt32Value{Value: 90}},
+					{Name: "default/api/80", Weight: &wrappers.UInt32Value{Value: 10}},
+				},
+			}}},
+		},
+		{
+			name:    "hash policy",
+			route:   Route{Prefix: "/", Cluster: "default/web/80", HashPolicy: &HashPolicy{Header: "x-user", Cookie: "session", SourceIP: true}},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			cluster: "default/web/80",
+			hashes:  3,
+		},
+		{
+			name: "timeouts and retries",
+			route: Route{
+				Prefix:      "/",
+				Cluster:     "default/web/80",
+				Timeout:     30 * time.Second,
+				IdleTimeout: time.Minute,
+				RetryPolicy: &RetryPolicy{NumRetries: 3, PerTryTimeout: time.Second, BackoffBase: 100 * time.Millisecond, HedgeOnPerTryTimeout: true},
+			},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			cluster: "default/web/80",
+			action: &route.RouteAction{
+				ClusterSpecifier: &route.RouteAction_Cluster{Cluster: "default/web/80"},
+				Timeout:          ptypes.DurationProto(30 * time.Second),
+				IdleTimeout:      ptypes.DurationProto(time.Minute),
+				RetryPolicy: &route.RetryPolicy{
+					RetryOn:       "connect-failure,refused-stream,unavailable,cancelled,retriable-status-codes",
+					NumRetries:    &wrappers.UInt32Value{Value: 3},
+					PerTryTimeout: ptypes.DurationProto(time.Second),
+					RetryBackOff:  &route.RetryPolicy_RetryBackOff{BaseInterval: ptypes.DurationProto(100 * time.Millisecond)},
+				},
+				HedgePolicy: &route.HedgePolicy{HedgeOnPerTryTimeout: true},
+			},
+		},
+		{
+			name:    "disabled timeout",
+			route:   Route{Prefix: "/", Cluster: "default/web/80", Timeout: NoTimeout, IdleTimeout: time.Minute},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			cluster: "default/web/80",
+			action: &route.RouteAction{
+				ClusterSpecifier: &route.RouteAction_Cluster{Cluster: "default/web/80"},
+				Timeout:          ptypes.DurationProto(0),
+				IdleTimeout:      ptypes.DurationProto(time.Minute),
+			},
+		},
+		{
+			name:     "HTTPS redirect",
+			route:    Route{Prefix: "/", Cluster: "default/web/80", HTTPSRedirectCode: 308, HSTS: &HSTS{MaxAge: time.Hour}},
+			match:    &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			redirect: true,
+		},
+		{
+			name:    "HTTPS redirect of a TLS listener",
+			route:   Route{Prefix: "/", Cluster: "default/web/80", HTTPSRedirectCode: 308, HSTS: &HSTS{MaxAge: time.Hour, Preload: true}},
+			match:   &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			cluster: "default/web/80",
+			tls:     true,
+			hsts:    "max-age=3600; preload",
+		},
+		{
+			name:   "direct response",
+			route:  Route{Prefix: "/", DirectResponseStatus: 503},
+			match:  &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			status: 503,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}}
+			if tt.tls {
+				l.TLSFilterChains = []TLSFilterChain{{Certificate: "default.cert"}}
+			}
+			config := MakeRoute(l, l.RouteNames[0], []Route{tt.route})
+			if len(config.VirtualHosts) != 1 || len(config.VirtualHosts[0].Routes) != 1 {
+				t.Fatalf("got virtual hosts %v, want a single route", config.VirtualHosts)
+			}
+			rt := config.VirtualHosts[0].Routes[0]
+			if !proto.Equal(rt.Match, tt.match) {
+				t.Errorf("got match %v, want %v", rt.Match, tt.match)
+			}
+			if got := rt.GetRoute().GetCluster(); got != tt.cluster {
+				t.Errorf("got cluster %q, want %q", got, tt.cluster)
+			}
+			if tt.action != nil && !proto.Equal(rt.GetRoute(), tt.action) {
+				t.Errorf("got action %v, want %v", rt.GetRoute(), tt.action)
+			}
+			if got := rt.GetRedirect().GetHttpsRedirect(); got != tt.redirect {
+				t.Errorf("got HTTPS redirect %v, want %v", got, tt.redirect)
+			}
+			var hsts string
+			for _, h := range rt.ResponseHeadersToAdd {
+				if h.Header.Key == "Strict-Transport-Security" {
+					hsts = h.Header.Value
+				}
+			}
+			if hsts != tt.hsts {
+				t.Errorf("got HSTS %q, want %q", hsts, tt.hsts)
+			}
+			if got := len(rt.GetRoute().GetHashPolicy()); got != tt.hashes {
+				t.Errorf("got %d hash policies, want %d", got, tt.hashes)
+			}
+			if got := rt.GetDirectResponse().GetStatus(); got != tt.status {
+				t.Errorf("got direct response %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestMakeRouteHeaders(t *testing.T) {
+	routes := []Route{
+		{
+			Prefix:                    "/api",
+			Cluster:                   "default/api/80",
+			RequestHeaders:            HeaderPolicy{Add: []Header{{Name: "X-Tenant", Value: "a"}}, Remove: []string{"X-Debug"}},
+			ResponseHeaders:           HeaderPolicy{Set: []Header{{Name: "Cache-Control", Value: "no-store"}}},
+			VirtualHostRequestHeaders: HeaderPolicy{Set: []Header{{Name: "X-Gateway", Value: "a"}}},
+		},
+		// the host headers of the first route win
+		{Prefix: "/", Cluster: "default/web/80", VirtualHostRequestHeaders: HeaderPolicy{Set: []Header{{Name: "X-Gateway", Value: "b"}}}},
+	}
+	config := MakeRoute(Listener{Name: "listener_0", RouteNames: []string{"listener_0"}}, "listener_0", routes)
+	vhost := config.VirtualHosts[0]
+	if len(vhost.RequestHeadersToAdd) != 1 || vhost.RequestHeadersToAdd[0].Header.Value != "a" || vhost.RequestHeadersToAdd[0].Append.GetValue() {
+		t.Errorf("got virtual host headers %v, want X-Gateway set to a", vhost.RequestHeadersToAdd)
+	}
+	api := vhost.Routes[0]
+	if len(api.RequestHeadersToAdd) != 1 || !api.RequestHeadersToAdd[0].Append.GetValue() {
+		t.Errorf("got request headers %v, want X-Tenant appended", api.RequestHeadersToAdd)
+	}
+	if !reflect.DeepEqual(api.RequestHeadersToRemove, []string{"X-Debug"}) {
+		t.Errorf("got removed request headers %v, want X-Debug", api.RequestHeadersToRemove)
+	}
+	if len(api.ResponseHeadersToAdd) != 1 || api.ResponseHeadersToAdd[0].Append.GetValue() {
+		t.Errorf("got response headers %v, want Cache-Control set", api.ResponseHeadersToAdd)
+	}
+}
+
+func TestMakeCorsPolicy(t *testing.T) {
+	policy := makeCorsPolicy(&CorsPolicy{
+		AllowOrigins:       []string{"https://example.com"},
+		AllowOriginRegexes: []string{`https://.*\.example\.org`},
+		AllowMethods:       []string{"GET", "POST"},
+		MaxAge:             time.Hour,
+		AllowCredentials:   true,
+	})
+	if got := policy.AllowOriginStringMatch; len(got) != 2 || got[0].GetExact() != "https://example.com" || got[1].GetSafeRegex().GetRegex() != `https://.*\.example\.org` {
+		t.Errorf("got origins %v, want the exact origin and the regex", got)
+	}
+	if policy.AllowMethods != "GET,POST" || policy.MaxAge != "3600" || !policy.AllowCredentials.GetValue() {
+		t.Errorf("got methods %q, max age %q and credentials %v", policy.AllowMethods, policy.MaxAge, policy.AllowCredentials)
+	}
+}
+
+func TestMakeHTTPFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener Listener
+		want     []string
+	}{
+		{
+			name:     "without a rate limit service",
+			listener: Listener{Name: "listener_0"},
+			want:     []string{"envoy.filters.http.cors", "envoy.filters.http.local_ratelimit", "envoy.filters.http.router"},
+		},
+		{
+			name:     "with a rate limit service",
+			listener: Listener{Name: "listener_0", RateLimitService: &RateLimitService{Cluster: "default/ratelimit/8081"}},
+			want:     []string{"envoy.filters.http.cors", "envoy.filters.http.local_ratelimit", "envoy.filters.http.ratelimit", "envoy.filters.http.router"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, f := range makeHTTPFilters(tt.listener) {
+				names = append(names, f.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("got filters %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeRouteRateLimits(t *testing.T) {
+	r := Route{
+		Prefix:               "/",
+		Cluster:              "default/web/80",
+		LocalRateLimit:       &LocalRateLimit{MaxTokens: 10, TokensPerFill: 10, FillInterval: time.Second},
+		RateLimitDescriptors: []RateLimitDescriptor{{Kind: "remote_address"}, {Kind: "header", Header: "X-Api-Key"}, {Kind: "path"}},
+	}
+	rt := makeRoute(Listener{Name: "listener_0"}, r)
+	if _, ok := rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"]; !ok {
+		t.Error("the route has no local rate limit")
+	}
+	limits := rt.GetRoute().RateLimits
+	if len(limits) != 1 || len(limits[0].Actions) != 3 {
+		t.Fatalf("got rate limits %v, want one with 3 actions", limits)
+	}
+	if got := limits[0].Actions[1].GetRequestHeaders(); got.HeaderName != "X-Api-Key" || got.DescriptorKey != "x-api-key" {
+		t.Errorf("got header action %v, want X-Api-Key as x-api-key", got)
+	}
+	if got := limits[0].Actions[2].GetRequestHeaders(); got.HeaderName != ":path" || got.DescriptorKey != "path" {
+		t.Errorf("got path action %v, want :path as path", got)
+	}
+}
+
+func TestMakeExtAuthz(t *testing.T) {
+	grpc := ExtAuthz{Name: "envoy.filters.http.ext_authz.a", Cluster: "default/authz/9000", GRPC: true}
+	http := ExtAuthz{
+		Name:            "envoy.filters.http.ext_authz.b",
+		Cluster:         "default/authz/8080",
+		PathPrefix:      "/check",
+		Timeout:         250 * time.Millisecond,
+		RequestHeaders:  []string{"Authorization"},
+		UpstreamHeaders: []string{"X-User"},
+	}
+
+	config := makeExtAuthz(grpc)
+	if got := config.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != grpc.Cluster {
+		t.Errorf("got gRPC cluster %q, want %q", got, grpc.Cluster)
+	}
+	if got := config.GetGrpcService().GetTimeout().AsDuration(); got != time.Second {
+		t.Errorf("got default timeout %v, want 1s", got)
+	}
+
+	service := makeExtAuthz(http).GetHttpService()
+	if service.GetServerUri().GetCluster() != http.Cluster || service.PathPrefix != "/check" {
+		t.Errorf("got HTTP service %v, want %s with /check", service, http.Cluster)
+	}
+	if got := service.GetServerUri().GetTimeout().AsDuration(); got != 250*time.Millisecond {
+		t.Errorf("got timeout %v, want 250ms", got)
+	}
+	if got := service.GetAuthorizationRequest().GetAllowedHeaders().GetPatterns(); len(got) != 1 || got[0].GetExact() != "Authorization" {
+		t.Errorf("got allowed headers %v, want Authorization", got)
+	}
+	if got := service.GetAuthorizationResponse().GetAllowedUpstreamHeaders().GetPatterns(); len(got) != 1 || got[0].GetExact() != "X-User" {
+		t.Errorf("got upstream headers %v, want X-User", got)
+	}
+
+	// each filter is disabled on the routes guarded by another one or by none
+	l := Listener{Name: "listener_0", ExtAuthz: []ExtAuthz{grpc, http}}
+	var names []string
+	for _, f := range makeHTTPFilters(l) {
+		names = append(names, f.Name)
+	}
+	want := []string{"envoy.filters.http.cors", grpc.Name, http.Name, "envoy.filters.http.local_ratelimit", "envoy.filters.http.router"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got filters %v, want %v", names, want)
+	}
+	guarded := makeRoute(l, Route{Prefix: "/", Cluster: "default/web/80", ExtAuthz: &grpc})
+	if _, ok := guarded.TypedPerFilterConfig[grpc.Name]; ok {
+		t.Error("the filter guarding the route is disabled")
+	}
+	if _, ok := guarded.TypedPerFilterConfig[http.Name]; !ok {
+		t.Error("the filter of another route is enabled")
+	}
+	open := makeRoute(l, Route{Prefix: "/", Cluster: "default/web/80"})
+	if len(open.TypedPerFilterConfig) != 2 {
+		t.Errorf("got per-filter config %v of an unguarded route, want both filters disabled", open.TypedPerFilterConfig)
+	}
+}
+
+func TestMakeJWTAuthn(t *testing.T) {
+	remote := JWTProvider{
+		Name:       "default.api",
+		Issuer:     "https://issuer.example.com",
+		Audiences:  []string{"api"},
+		RemoteJWKS: &RemoteJWKS{URI: "https://issuer.example.com/jwks", Cluster: "uri.issuer.example.com:443", CacheDuration: time.Hour},
+	}
+	local := JWTProvider{Name: "default.admin", Issuer: "admin", LocalJWKS: `{"keys":[]}`, Forward: true}
+
+	config := makeJWTAuthn([]JWTProvider{remote, local})
+	if len(config.Providers) != 2 || len(config.RequirementMap) != 2 {
+		t.Fatalf("got providers %v and requirements %v, want 2 each", config.Providers, config.RequirementMap)
+	}
+	got := config.Providers["default.api"]
+	if got.Issuer != remote.Issuer || !reflect.DeepEqual(got.Audiences, remote.Audiences) || got.PayloadInMetadata != JWTPayloadMetadataKey {
+		t.Errorf("got provider %v, want %+v", got, remote)
+	}
+	if uri := got.GetRemoteJwks().GetHttpUri(); uri.GetUri() != remote.RemoteJWKS.URI || uri.GetCluster() != remote.RemoteJWKS.Cluster {
+		t.Errorf("got JWKS URI %v, want %s through %s", uri, remote.RemoteJWKS.URI, remote.RemoteJWKS.Cluster)
+	}
+	if d := got.GetRemoteJwks().GetCacheDuration().AsDuration(); d != time.Hour {
+		t.Errorf("got cache duration %v, want 1h", d)
+	}
+	got = config.Providers["default.admin"]
+	if got.GetLocalJwks().GetInlineString() != local.LocalJWKS || !got.Forward {
+		t.Errorf("got provider %v, want %+v", got, local)
+	}
+	if name := config.RequirementMap["default.admin"].GetProviderName(); name != "default.admin" {
+		t.Errorf("got requirement of provider %q, want default.admin", name)
+	}
+
+	// routes of listeners with providers pick theirs or disable the filter
+	l := Listener{Name: "listener_0", JWTProviders: []JWTProvider{remote}}
+	perRoute := &jwtauthn.PerRouteConfig{}
+	if err := makeRoute(l, Route{Prefix: "/", JWT: &remote}).TypedPerFilterConfig[jwtAuthnFilter].UnmarshalTo(perRoute); err != nil {
+		t.Fatal(err)
+	}
+	if perRoute.GetRequirementName() != "default.api" {
+		t.Errorf("got per-route config %v, want the default.api requirement", perRoute)
+	}
+	if err := makeRoute(l, Route{Prefix: "/"}).TypedPerFilterConfig[jwtAuthnFilter].UnmarshalTo(perRoute); err != nil {
+		t.Fatal(err)
+	}
+	if !perRoute.GetDisabled() {
+		t.Errorf("got per-route config %v of an open route, want disabled", perRoute)
+	}
+	if _, ok := makeRoute(Listener{Name: "listener_0"}, Route{Prefix: "/"}).TypedPerFilterConfig[jwtAuthnFilter]; ok {
+		t.Error("got per-route JWT config without providers")
+	}
+}
+
+func TestMakeIPFilter(t *testing.T) {
+	allow := []CIDR{{Address: "10.0.0.0", PrefixLen: 8}, {Address: "fd00::", PrefixLen: 64}}
+	deny := []CIDR{{Address: "10.0.0.1", PrefixLen: 32}}
+	tests := []struct {
+		name   string
+		filter IPFilter
+		// wantIds are the identifiers of the principals and-ed together
+		wantIds []string
+	}{
+		{name: "allowlist", filter: IPFilter{Allow: allow}, wantIds: []string{"or"}},
+		{name: "denylist", filter: IPFilter{Deny: deny}, wantIds: []string{"not"}},
+		{name: "both", filter: IPFilter{Allow: allow, Deny: deny}, wantIds: []string{"or", "not"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perRoute := &rbacfilter.RBACPerRoute{}
+			if err := makeIPFilter(&tt.filter).UnmarshalTo(perRoute); err != nil {
+				t.Fatal(err)
+			}
+			rules := perRoute.GetRbac().GetRules()
+			if rules.Action != rbac.RBAC_ALLOW || len(rules.Policies) != 1 {
+				t.Fatalf("got rules %v, want a single allow policy", rules)
+			}
+			principals := rules.Policies["ip-filter"].GetPrincipals()
+			if len(principals) != 1 {
+				t.Fatalf("got principals %v, want one", principals)
+			}
+			var ids []string
+			for _, id := range principals[0].GetAndIds().GetIds() {
+				switch {
+				case id.GetOrIds() != nil:
+					ids = append(ids, "or")
+					if got := len(id.GetOrIds().GetIds()); got != len(allow) {
+						t.Errorf("got %d allowed ranges, want %d", got, len(allow))
+					}
+				case id.GetNotId() != nil:
+					ids = append(ids, "not")
+					if got := id.GetNotId().GetOrIds().GetIds()[0].GetRemoteIp(); got.AddressPrefix != "10.0.0.1" || got.PrefixLen.GetValue() != 32 {
+						t.Errorf("got denied range %v, want 10.0.0.1/32", got)
+					}
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("got principals %v, want %v", ids, tt.wantIds)
+			}
+		})
+	}
+
+	manager := &hcm.HttpConnectionManager{}
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, UseRemoteAddress: true, XFFNumTrustedHops: 1, IPFiltering: true}
+	if err := makeHTTPConnectionManager(l, l.RouteNames[0], nil).GetTypedConfig().UnmarshalTo(manager); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.UseRemoteAddress.GetValue() || manager.XffNumTrustedHops != 1 {
+		t.Errorf("got remote address %v with %d trusted hops, want 1", manager.UseRemoteAddress, manager.XffNumTrustedHops)
+	}
+	if got := manager.HttpFilters[1].Name; got != "envoy.filters.http.rbac" {
+		t.Errorf("got second filter %s, want the RBAC filter", got)
+	}
+}
+
+func TestMakeAccessLogs(t *testing.T) {
+	l := Listener{Name: "listener_0", AccessLogs: []AccessLog{
+		{Path: "/dev/stdout", TextFormat: "%RESPONSE_CODE%\n"},
+		{Path: "/var/log/envoy.json", JSONFormat: map[string]string{"status": "%RESPONSE_CODE%"}},
+		{GRPCCluster: "logging/als/9001"},
+	}}
+	logs := makeAccessLogs(l)
+	if len(logs) != 3 {
+		t.Fatalf("got %d access logs, want 3", len(logs))
+	}
+
+	text := &fileaccesslog.FileAccessLog{}
+	if err := logs[0].GetTypedConfig().UnmarshalTo(text); err != nil {
+		t.Fatal(err)
+	}
+	if text.Path != "/dev/stdout" || text.GetLogFormat().GetTextFormat() != "%RESPONSE_CODE%\n" {
+		t.Errorf("got file access log %v, want the text format on /dev/stdout", text)
+	}
+	json := &fileaccesslog.FileAccessLog{}
+	if err := logs[1].GetTypedConfig().UnmarshalTo(json); err != nil {
+		t.Fatal(err)
+	}
+	if got := json.GetLogFormat().GetJsonFormat().GetFields()["status"].GetStringValue(); got != "%RESPONSE_CODE%" {
+		t.Errorf("got JSON status %q, want %%RESPONSE_CODE%%", got)
+	}
+	grpc := &grpcaccesslog.HttpGrpcAccessLogConfig{}
+	if logs[2].Name != "envoy.access_loggers.http_grpc" {
+		t.Errorf("got access log %s, want the gRPC access log", logs[2].Name)
+	}
+	if err := logs[2].GetTypedConfig().UnmarshalTo(grpc); err != nil {
+		t.Fatal(err)
+	}
+	if got := grpc.CommonConfig.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != "logging/als/9001" || grpc.CommonConfig.LogName != "listener_0" {
+		t.Errorf("got gRPC access log %v, want logging.als.9001 named listener_0", grpc.CommonConfig)
+	}
+}
+
+func TestMakeRouteOrigin(t *testing.T) {
+	rt := makeRoute(Listener{Name: "listener_0"}, Route{
+		Name:    "default/web/0/0",
+		Prefix:  "/",
+		Cluster: "default/web/80",
+		Origin:  &Origin{Kind: "Ingress", Namespace: "default", Name: "web", UID: "ingress-uid"},
+	})
+	if rt.Name != "default/web/0/0" {
+		t.Errorf("got route name %q, want default/web/0/0", rt.Name)
+	}
+	fields := rt.GetMetadata().GetFilterMetadata()[MetadataNamespace].GetFields()
+	for k, want := range map[string]string{"kind": "Ingress", "namespace": "default", "name": "web", "uid": "ingress-uid"} {
+		if got := fields[k].GetStringValue(); got != want {
+			t.Errorf("got metadata %s %q, want %q", k, got, want)
+		}
+	}
+	if rt := makeRoute(Listener{Name: "listener_0"}, Route{Prefix: "/"}); rt.Metadata != nil {
+		t.Errorf("got metadata %v of a route without origin", rt.Metadata)
+	}
+
+	cls := MakeCluster(Cluster{Name: "default/web/80", Origin: &Origin{Kind: "Service", Namespace: "default", Name: "web"}})
+	if got := cls.GetMetadata().GetFilterMetadata()[MetadataNamespace].GetFields()["kind"].GetStringValue(); got != "Service" {
+		t.Errorf("got cluster origin kind %q, want Service", got)
+	}
+}
+
+func TestMakeTracing(t *testing.T) {
+	if makeTracing(nil) != nil {
+		t.Error("got tracing of a listener without tracing")
+	}
+	tags := []TracingTag{{Name: "cluster", Literal: "eu"}, {Name: "tenant", Header: "X-Tenant"}}
+
+	otel := makeTracing(&Tracing{Provider: "opentelemetry", Cluster: "tracing/collector/4317", Sampling: 10, Tags: tags})
+	if otel.GetProvider().Name != "envoy.tracers.opentelemetry" || otel.RandomSampling.GetValue() != 10 {
+		t.Errorf("got tracing %v, want OpenTelemetry sampling 10%%", otel)
+	}
+	otelConfig := &trace.OpenTelemetryConfig{}
+	if err := otel.GetProvider().GetTypedConfig().UnmarshalTo(otelConfig); err != nil {
+		t.Fatal(err)
+	}
+	if got := otelConfig.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != "tracing/collector/4317" {
+		t.Errorf("got collector %q, want tracing/collector/4317", got)
+	}
+	if len(otel.CustomTags) != 2 || otel.CustomTags[0].GetLiteral().GetValue() != "eu" || otel.CustomTags[1].GetRequestHeader().GetName() != "X-Tenant" {
+		t.Errorf("got tags %v, want cluster=eu and tenant from X-Tenant", otel.CustomTags)
+	}
+
+	zipkin := makeTracing(&Tracing{Provider: "zipkin", Cluster: "tracing/collector/9411", Sampling: 100})
+	zipkinConfig := &trace.ZipkinConfig{}
+	if err := zipkin.GetProvider().GetTypedConfig().UnmarshalTo(zipkinConfig); err != nil {
+		t.Fatal(err)
+	}
+	if zipkinConfig.CollectorCluster != "tracing/collector/9411" || zipkinConfig.CollectorEndpoint != "/api/v2/spans" {
+		t.Errorf("got Zipkin config %v, want tracing/collector/9411 at /api/v2/spans", zipkinConfig)
+	}
+
+	half := 12.5
+	rt := makeRoute(Listener{Name: "listener_0"}, Route{Prefix: "/", Tracing: &RouteTracing{Sampling: &half, Tags: tags[:1]}})
+	if got := rt.Tracing.GetRandomSampling(); got.GetNumerator() != 125000 || got.GetDenominator() != typev3.FractionalPercent_MILLION {
+		t.Errorf("got route sampling %v, want 125000 per million", got)
+	}
+	if len(rt.Tracing.CustomTags) != 1 {
+		t.Errorf("got route tags %v, want one", rt.Tracing.CustomTags)
+	}
+	if rt := makeRoute(Listener{Name: "listener_0"}, Route{Prefix: "/"}); rt.Tracing != nil {
+		t.Errorf("got tracing %v of a route without tracing", rt.Tracing)
+	}
+}
+
+func TestRequestBuffer(t *testing.T) {
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, Buffering: true}
+	filters := makeHTTPFilters(l)
+	if got := filters[len(filters)-2].Name; got != "envoy.filters.http.buffer" {
+		t.Errorf("got filter %s before the router, want the buffer filter", got)
+	}
+
+	tests := []struct {
+		name  string
+		route Route
+		want  uint32
+	}{
+		{name: "limited", route: Route{Prefix: "/", Cluster: "default/web/80", MaxRequestBytes: 1024}, want: 1024},
+		{name: "unlimited", route: Route{Prefix: "/", Cluster: "default/web/80"}},
+		{name: "HTTPS redirect", route: Route{Prefix: "/", Cluster: "default/web/80", HTTPSRedirectCode: 308, MaxRequestBytes: 1024}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &buffer.BufferPerRoute{}
+			if err := makeRoute(l, tt.route).TypedPerFilterConfig["envoy.filters.http.buffer"].UnmarshalTo(config); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == 0 {
+				if !config.GetDisabled() {
+					t.Errorf("got buffer %v, want disabled", config)
+				}
+				return
+			}
+			if got := config.GetBuffer().GetMaxRequestBytes().GetValue(); got != tt.want {
+				t.Errorf("got limit %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgrades(t *testing.T) {
+	upgrades := func(configs []*route.RouteAction_UpgradeConfig) []string {
+		var types []string
+		for _, c := range configs {
+			if c.Enabled.GetValue() {
+				types = append(types, c.UpgradeType)
+			}
+		}
+		return types
+	}
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}}
+	if got := upgrades(makeRoute(l, Route{Prefix: "/", Cluster: "default/web/80"}).GetRoute().UpgradeConfigs); got != nil {
+		t.Errorf("got upgrades %v of a plain route, want none", got)
+	}
+	if got := upgrades(makeRoute(l, Route{Prefix: "/", Cluster: "default/web/80", WebSocket: true}).GetRoute().UpgradeConfigs); !reflect.DeepEqual(got, []string{"websocket"}) {
+		t.Errorf("got upgrades %v, want websocket", got)
+	}
+
+	connect := Route{Connect: true, Cluster: "default/web/80"}
+	if got := upgrades(makeRoute(l, connect).GetRoute().UpgradeConfigs); got != nil {
+		t.Errorf("got upgrades %v on a listener without CONNECT, want none", got)
+	}
+	l.Connect = true
+	rt := makeRoute(l, connect)
+	if rt.Match.GetConnectMatcher() == nil {
+		t.Errorf("got match %v, want a CONNECT matcher", rt.Match)
+	}
+	if got := upgrades(rt.GetRoute().UpgradeConfigs); !reflect.DeepEqual(got, []string{"CONNECT"}) {
+		t.Errorf("got upgrades %v, want CONNECT", got)
+	}
+
+	manager := &hcm.HttpConnectionManager{}
+	if err := makeHTTPConnectionManager(l, l.RouteNames[0], nil).GetTypedConfig().UnmarshalTo(manager); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.UpgradeConfigs) != 2 || manager.UpgradeConfigs[0].Enabled.GetValue() || manager.UpgradeConfigs[1].Enabled.GetValue() {
+		t.Errorf("got upgrades %v, want websocket and CONNECT disabled by default", manager.UpgradeConfigs)
+	}
+	if !manager.Http2ProtocolOptions.GetAllowConnect() {
+		t.Error("the listener does not allow the extended CONNECT of HTTP/2")
+	}
+}
+
+func TestMakeRouteOrder(t *testing.T) {
+	routes := []Route{
+		{Prefix: "/", Cluster: "root"},
+		{PathRegex: "/api/v[0-9]+", Cluster: "regex"},
+		{PathSeparatedPrefix: "/api", Cluster: "api"},
+		{PathSeparatedPrefix: "/api", HeaderMatches: []ValueMatch{{Name: "x-version", Value: "2"}}, Cluster: "api-v2"},
+		{PathSeparatedPrefix: "/api/users", Cluster: "api-users"},
+		{Path: "/login", Cluster: "login"},
+		{Host: "other.example.com", Prefix: "/", Cluster: "other"},
+	}
+	config := MakeRoute(Listener{Name: "listener_0", RouteNames: []string{"listener_0"}}, "listener_0", routes)
+
+	want := map[string][]string{
+		"other.example.com": {"other"},
+		"*":                 {"login", "api-users", "api-v2", "api", "root", "regex"},
+	}
+	var hosts []string
+	for _, vhost := range config.VirtualHosts {
+		hosts = append(hosts, vhost.Name)
+		var clusters []string
+		for _, rt := range vhost.Routes {
+			clusters = append(clusters, rt.GetRoute().GetCluster())
+		}
+		if !reflect.DeepEqual(clusters, want[vhost.Name]) {
+			t.Errorf("virtual host %s has routes %v, want %v", vhost.Name, clusters, want[vhost.Name])
+		}
+	}
+	if !reflect.DeepEqual(hosts, []string{"other.example.com", "*"}) {
+		t.Errorf("got virtual hosts %v, want the catch-all last", hosts)
+	}
+}
+
+func TestMakeHTTPListener(t *testing.T) {
+	tests := []struct {
+		name            string
+		listener        Listener
+		ipv4Compat      bool
+		listenerFilters []string
+		serverNames     [][]string
+		requireCert     []bool
+	}{
+		{
+			name:     "IPv4",
+			listener: Listener{Name: "listener_0", Address: "0.0.0.0", Port: 8080, RouteNames: []string{"listener_0"}},
+		},
+		{
+			name:       "IPv6",
+			listener:   Listener{Name: "listener_0", Address: "::", Port: 8080, RouteNames: []string{"listener_0"}},
+			ipv4Compat: true,
+		},
+		{
+			name:            "PROXY protocol",
+			listener:        Listener{Name: "listener_0", Address: "0.0.0.0", Port: 8080, RouteNames: []string{"listener_0"}, ProxyProtocol: true},
+			listenerFilters: []string{"envoy.filters.listener.proxy_protocol"},
+		},
+		{
+			name: "TLS with the PROXY protocol",
+			listener: Listener{
+				Name:          "listener_https",
+				Address:       "0.0.0.0",
+				Port:          8443,
+				RouteNames:    []string{"listener_0"},
+				ProxyProtocol: true,
+				TLSFilterChains: []TLSFilterChain{
+					{ServerNames: []string{"web.example.com"}, Certificate: "default.web"},
+				},
+			},
+			listenerFilters: []string{"envoy.filters.listener.proxy_protocol", "envoy.filters.listener.tls_inspector"},
+			serverNames:     [][]string{{"web.example.com"}},
+			requireCert:     []bool{false},
+		},
+		{
+			name: "TLS",
+			listener: Listener{
+				Name:       "listener_https",
+				Address:    "0.0.0.0",
+				Port:       8443,
+				RouteNames: []string{"listener_0"},
+				TLSFilterChains: []TLSFilterChain{
+					{ServerNames: []string{"web.example.com"}, Certificate: "default.web", ClientValidation: &ClientValidation{CACert: []byte("CA")}},
+					{Certificate: "default.fallback"},
+				},
+			},
+			listenerFilters: []string{"envoy.filters.listener.tls_inspector"},
+			serverNames:     [][]string{{"web.example.com"}, nil},
+			requireCert:     []bool{true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis := MakeHTTPListener(tt.listener)
+			address := lis.Address.GetSocketAddress()
+			if address.Address != tt.listener.Address || address.GetPortValue() != tt.listener.Port || address.Ipv4Compat != tt.ipv4Compat {
+				t.Errorf("got address %v, want %s:%d with IPv4 compatibility %v", address, tt.listener.Address, tt.listener.Port, tt.ipv4Compat)
+			}
+			var filters []string
+			for _, f := range lis.ListenerFilters {
+				filters = append(filters, f.Name)
+			}
+			if !reflect.DeepEqual(filters, tt.listenerFilters) {
+				t.Errorf("got listener filters %v, want %v", filters, tt.listenerFilters)
+			}
+			if tt.serverNames == nil {
+				if len(lis.FilterChains) != 1 || lis.FilterChains[0].TransportSocket != nil {
+					t.Errorf("got filter chains %v, want a single plaintext one", lis.FilterChains)
+				}
+				return
+			}
+			if len(lis.FilterChains) != len(tt.serverNames) {
+				t.Fatalf("got %d filter chains, want %d", len(lis.FilterChains), len(tt.serverNames))
+			}
+			for i, fc := range lis.FilterChains {
+				if got := fc.GetFilterChainMatch().GetServerNames(); !reflect.DeepEqual(got, tt.serverNames[i]) {
+					t.Errorf("filter chain %d matches server names %v, want %v", i, got, tt.serverNames[i])
+				}
+				ctx := &tls.DownstreamTlsContext{}
+				if err := fc.TransportSocket.GetTypedConfig().UnmarshalTo(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if got := ctx.RequireClientCertificate.GetValue(); got != tt.requireCert[i] {
+					t.Errorf("filter chain %d requires client certificates %v, want %v", i, got, tt.requireCert[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsIPv6Unspecified(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{address: "::", want: true},
+		{address: "0:0::0", want: true},
+		{address: "0000:0000:0000:0000:0000:0000:0000:0000", want: true},
+		{address: "::1", want: false},
+		{address: "0.0.0.0", want: false},
+		{address: "::ffff:0.0.0.0", want: false},
+		{address: "fd00::1", want: false},
+		{address: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isIPv6Unspecified(tt.address); got != tt.want {
+			t.Errorf("isIPv6Unspecified(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+// TestMakeRoutesTLSHostIsolation checks that a client connecting with the server name
+// of a chain without client certificates cannot reach the hosts of the chain
+// requiring them by sending their Host header.
+func TestMakeRoutesTLSHostIsolation(t *testing.T) {
+	l := Listener{
+		Name:       "listener_https",
+		RouteNames: []string{"listener_https"},
+		TLSFilterChains: []TLSFilterChain{
+			{ServerNames: []string{"secure.example.com"}, Certificate: "default.secure", ClientValidation: &ClientValidation{CACert: []byte("CA"), Mode: "on"}},
+			{ServerNames: []string{"*.example.com"}, Certificate: "default.wildcard"},
+			{ServerNames: []string{"public.example.com"}, Certificate: "default.public"},
+			{Certificate: "default.fallback"},
+		},
+	}
+	routes := []Route{
+		{Name: "secure", Host: "secure.example.com", Prefix: "/", Cluster: "default/secure/80"},
+		{Name: "public", Host: "public.example.com", Prefix: "/", Cluster: "default/public/80"},
+		{Name: "www", Host: "www.example.com", Prefix: "/", Cluster: "default/www/80"},
+		{Name: "wildcard", Host: "*.example.com", Prefix: "/", Cluster: "default/wildcard/80"},
+		{Name: "other", Host: "other.org", Prefix: "/", Cluster: "default/other/80"},
+		{Name: "default", Prefix: "/", Cluster: "default/default/80"},
+	}
+
+	want := map[string][]string{
+		"listener_https/secure.example.com": {"secure.example.com"},
+		"listener_https/*.example.com":      {"*.example.com", "www.example.com"},
+		"listener_https/public.example.com": {"public.example.com"},
+		"listener_https":                    {"other.org", "*"},
+	}
+	configs := MakeRoutes(l, routes)
+	if len(configs) != len(want) {
+		t.Fatalf("got %d route configurations, want %d", len(configs), len(want))
+	}
+	for _, config := range configs {
+		var hosts []string
+		for _, vhost := range config.VirtualHosts {
+			hosts = append(hosts, vhost.Name)
+		}
+		if !reflect.DeepEqual(hosts, want[config.Name]) {
+			t.Errorf("route configuration %s has hosts %v, want %v", config.Name, hosts, want[config.Name])
+		}
+	}
+
+	// each chain serves its own route configuration
+	lis := MakeHTTPListener(l)
+	for i, fc := range lis.FilterChains {
+		manager := &hcm.HttpConnectionManager{}
+		if err := fc.Filters[0].GetTypedConfig().UnmarshalTo(manager); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := manager.GetRds().RouteConfigName, tlsRouteName(l, l.TLSFilterChains[i]); got != want {
+			t.Errorf("filter chain %d uses route configuration %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestMakeRoutesScheme(t *testing.T) {
+	routes := []Route{
+		{Name: "both", Host: "web.example.com", Prefix: "/both", Cluster: "default/web/80"},
+		{Name: "plain", Host: "web.example.com", Prefix: "/plain", Cluster: "default/web/80", Scheme: "http"},
+		{Name: "secure", Host: "web.example.com", Prefix: "/secure", Cluster: "default/web/80", Scheme: "https"},
+	}
+	tests := []struct {
+		name     string
+		listener Listener
+		want     []string
+	}{
+		{
+			name:     "plaintext",
+			listener: Listener{Name: "listener_0", RouteNames: []string{"listener_0"}},
+			want:     []string{"both", "plain"},
+		},
+		{
+			name:     "tls",
+			listener: Listener{Name: "listener_https", RouteNames: []string{"listener_https"}, TLSFilterChains: []TLSFilterChain{{Certificate: "default.cert"}}},
+			want:     []string{"both", "secure"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs := MakeRoutes(tt.listener, routes)
+			if len(configs) != 1 || len(configs[0].VirtualHosts) != 1 {
+				t.Fatalf("got route configurations %v", configs)
+			}
+			var names []string
+			for _, r := range configs[0].VirtualHosts[0].Routes {
+				names = append(names, r.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("got routes %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	cls := MakeCluster(Cluster{
+		Name:        "default/web/80",
+		Hostname:    "web.default.svc.cluster.local",
+		HealthCheck: &HealthCheck{Protocol: "http", Path: "/healthz"},
+	})
+	if got := cls.HealthChecks[0].GetHttpHealthCheck().Host; got != "web.default.svc.cluster.local" {
+		t.Errorf("health checks are sent with Host %q", got)
+	}
+
+	tracing := makeTracing(&Tracing{Provider: "zipkin", Cluster: "tracing/zipkin/9411", Hostname: "zipkin.tracing.svc.cluster.local"})
+	zipkin := &trace.ZipkinConfig{}
+	if err := tracing.Provider.GetTypedConfig().UnmarshalTo(zipkin); err != nil {
+		t.Fatal(err)
+	}
+	if zipkin.CollectorHostname != "zipkin.tracing.svc.cluster.local" {
+		t.Errorf("spans are sent with Host %q", zipkin.CollectorHostname)
+	}
+}
+
+func TestResponseBuffer(t *testing.T) {
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, MaxResponseBytes: 8 << 20}
+	if got := MakeHTTPListener(l).PerConnectionBufferLimitBytes.GetValue(); got != 8<<20 {
+		t.Errorf("connections buffer %d bytes, want %d", got, 8<<20)
+	}
+	if got := MakeHTTPListener(Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, MaxResponseBytes: 1024}).PerConnectionBufferLimitBytes; got != nil {
+		t.Errorf("the default connection buffer limit was lowered to %d", got.GetValue())
+	}
+
+	limited := makeRoute(l, Route{Name: "limited", Prefix: "/", Cluster: "default/web/80", MaxResponseBytes: 1024})
+	config := &lua.LuaPerRoute{}
+	if err := limited.TypedPerFilterConfig[responseBufferFilter].UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if code := config.GetSourceCode().GetInlineString(); !strings.Contains(code, "local limit = 1024\n") {
+		t.Errorf("unexpected response buffer script %q", code)
+	}
+
+	unlimited := makeRoute(l, Route{Name: "unlimited", Prefix: "/", Cluster: "default/web/80"})
+	if err := unlimited.TypedPerFilterConfig[responseBufferFilter].UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if !config.GetDisabled() {
+		t.Errorf("the responses of a route without a limit are buffered")
+	}
+}
+
+func TestWeightedDirectResponse(t *testing.T) {
+	l := Listener{Name: "listener_0", RouteNames: []string{"listener_0"}, WeightedDirectResponses: true}
+	filters := makeHTTPFilters(l)
+	found := false
+	for _, f := range filters {
+		found = found || f.Name == directResponseFilter
+	}
+	if !found {
+		t.Errorf("filter %s is not installed", directResponseFilter)
+	}
+
+	r := makeRoute(l, Route{Name: "split", Prefix: "/", WeightedClusters: []WeightedCluster{
+		{Name: "default/web/80", Weight: 3},
+		{Weight: 1, DirectResponseStatus: 500},
+	}})
+	clusters := r.GetRoute().GetWeightedClusters().GetClusters()
+	if len(clusters) != 2 {
+		t.Fatalf("got %d weighted clusters, want 2", len(clusters))
+	}
+	if clusters[0].Name != "default/web/80" || clusters[0].TypedPerFilterConfig[directResponseFilter] != nil {
+		t.Errorf("the share of a backend is answered directly: %v", clusters[0])
+	}
+	if clusters[1].Name != directResponseCluster || clusters[1].Weight.GetValue() != 1 {
+		t.Errorf("direct response share %v", clusters[1])
+	}
+	config := &lua.LuaPerRoute{}
+	if err := clusters[1].TypedPerFilterConfig[directResponseFilter].UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if code := config.GetSourceCode().GetInlineString(); !strings.Contains(code, `[":status"] = "500"`) {
+		t.Errorf("unexpected direct response script %q", code)
+	}
+}
@@ -0,0 +1,61 @@
+package resources
+
+import (
+	"fmt"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+const (
+	// responseBufferFilter buffers the responses of the routes with a response limit,
+	// as the buffer filter only buffers requests.
+	responseBufferFilter = "envoy.filters.http.lua.response_buffer"
+	// defaultConnectionBufferLimit is the buffer limit of the connections of Envoy,
+	// which a buffered response cannot exceed.
+	defaultConnectionBufferLimit = 1 << 20
+)
+
+// responseBufferCode drops the body of the responses larger than the limit formatted
+// in, without buffering those whose Content-Length already exceeds it.
+const responseBufferCode = `
+local limit = %d
+
+function envoy_on_response(handle)
+  local headers = handle:headers()
+  local length = tonumber(headers:get("content-length"))
+  if length ~= nil and length > limit then
+    headers:replace(":status", "502")
+    headers:remove("content-length")
+    for chunk in handle:bodyChunks() do
+      chunk:setBytes("")
+    end
+    return
+  end
+  local body = handle:body()
+  if body ~= nil and body:length() > limit then
+    headers:replace(":status", "502")
+    headers:replace("content-length", "0")
+    body:setBytes("")
+  end
+end
+`
+
+func makeResponseBuffer() *lua.Lua {
+	return &lua.Lua{InlineCode: "function envoy_on_response(handle) end"}
+}
+
+func makeResponseBufferPerRoute(maxResponseBytes uint32) *any.Any {
+	config := &lua.LuaPerRoute{Override: &lua.LuaPerRoute_Disabled{Disabled: true}}
+	if maxResponseBytes > 0 {
+		config.Override = &lua.LuaPerRoute_SourceCode{SourceCode: &core.DataSource{
+			Specifier: &core.DataSource_InlineString{InlineString: fmt.Sprintf(responseBufferCode, maxResponseBytes)},
+		}}
+	}
+	pbst, err := ptypes.MarshalAny(config)
+	if err != nil {
+		panic(err)
+	}
+	return pbst
+}
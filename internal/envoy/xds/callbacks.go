@@ -0,0 +1,64 @@
+package xds
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/go-logr/logr"
+	"sync"
+)
+
+// NodeZone returns the zone of a proxy: the zone of its locality, as set by
+// --service-zone or the bootstrap, else the "zone" field of its metadata.
+func NodeZone(node *core.Node) string {
+	if node == nil {
+		return ""
+	}
+	if zone := node.GetLocality().GetZone(); zone != "" {
+		return zone
+	}
+	return node.GetMetadata().GetFields()["zone"].GetStringValue()
+}
+
+// NewCallbacks logs the zone of each proxy on the first request of its streams.
+// With zone-aware routing, Envoy only keeps requests in the zone of its locality,
+// so a proxy without one is reported, along with the zone of its metadata if any.
+func NewCallbacks(log logr.Logger, zoneAware bool) serverv3.Callbacks {
+	var mu sync.Mutex
+	seen := map[int64]bool{}
+	closed := func(id int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(seen, id)
+	}
+	request := func(id int64, node *core.Node) {
+		mu.Lock()
+		first := !seen[id]
+		seen[id] = true
+		mu.Unlock()
+		if first {
+			logNode(log, node, zoneAware)
+		}
+	}
+	return serverv3.CallbackFuncs{
+		StreamClosedFunc:      closed,
+		DeltaStreamClosedFunc: closed,
+		StreamRequestFunc: func(id int64, req *discovery.DiscoveryRequest) error {
+			request(id, req.GetNode())
+			return nil
+		},
+		StreamDeltaRequestFunc: func(id int64, req *discovery.DeltaDiscoveryRequest) error {
+			request(id, req.GetNode())
+			return nil
+		},
+	}
+}
+
+func logNode(log logr.Logger, node *core.Node, zoneAware bool) {
+	log = log.WithValues("node", node.GetId(), "zone", NodeZone(node))
+	if zoneAware && node.GetLocality().GetZone() == "" {
+		log.Info("proxy has no locality zone, Envoy ignores the zone of its metadata and routes to all zones; start it with --service-zone")
+		return
+	}
+	log.Info("proxy connected")
+}
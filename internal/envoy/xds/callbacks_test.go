@@ -0,0 +1,31 @@
+package xds
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+func TestNodeZone(t *testing.T) {
+	metadata, err := structpb.NewStruct(map[string]interface{}{"zone": "zone-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		node *core.Node
+		want string
+	}{
+		{name: "no node"},
+		{name: "no zone", node: &core.Node{Id: "envoy"}},
+		{name: "locality", node: &core.Node{Locality: &core.Locality{Zone: "zone-a"}, Metadata: metadata}, want: "zone-a"},
+		{name: "metadata", node: &core.Node{Metadata: metadata}, want: "zone-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NodeZone(tt.node); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package xds
 
 import (
+	"context"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
@@ -10,8 +12,20 @@ import (
 	secretservice "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"google.golang.org/grpc"
+	"net"
 )
 
+// Fleet is the snapshot key of the proxies, which are all served the same
+// configuration.
+const Fleet = "fleet"
+
+// FleetHash maps every node to the Fleet snapshot.
+type FleetHash struct{}
+
+func (FleetHash) ID(*core.Node) string {
+	return Fleet
+}
+
 func registerServer(g *grpc.Server, srv serverv3.Server) {
 	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(g, srv)
 	secretservice.RegisterSecretDiscoveryServiceServer(g, srv)
@@ -27,3 +41,16 @@ func NewServer(server serverv3.Server, opts ...grpc.ServerOption) *grpc.Server {
 	registerServer(g, server)
 	return g
 }
+
+// Serve accepts xDS connections on address until the context is done.
+func Serve(ctx context.Context, g *grpc.Server, address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		g.GracefulStop()
+	}()
+	return g.Serve(l)
+}
@@ -1,8 +1,14 @@
 package xdscache
 
 import (
+	"fmt"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"hash/fnv"
 	"kubernetes-controller/internal/envoy/resources"
+	"sort"
 )
 
 type Cache struct {
@@ -10,33 +16,104 @@ type Cache struct {
 	Routes    map[string]resources.Route
 	Clusters  map[string]resources.Cluster
 	Endpoints map[string]resources.Endpoint
+	Secrets   map[string]resources.Secret
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		Listeners: make(map[string]resources.Listener),
+		Routes:    make(map[string]resources.Route),
+		Clusters:  make(map[string]resources.Cluster),
+		Endpoints: make(map[string]resources.Endpoint),
+		Secrets:   make(map[string]resources.Secret),
+	}
+}
+
+// Snapshot returns the resources of the cache as a snapshot for the xDS server. Its
+// version is a hash of the resources, so rebuilding unchanged objects does not push
+// a new configuration to the proxies.
+func (cache *Cache) Snapshot() (*cachev3.Snapshot, error) {
+	contents := map[resource.Type][]types.Resource{
+		resource.ClusterType:  cache.ClusterContents(),
+		resource.EndpointType: cache.EndpointsContents(),
+		resource.ListenerType: cache.ListenerContents(),
+		resource.RouteType:    cache.RouteContents(),
+		resource.SecretType:   cache.SecretContents(),
+	}
+	version, err := contentsVersion(contents)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := cachev3.NewSnapshot(version, contents)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Consistent(); err != nil {
+		return nil, fmt.Errorf("inconsistent snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+func contentsVersion(contents map[resource.Type][]types.Resource) (string, error) {
+	var typeURLs []string
+	for typeURL := range contents {
+		typeURLs = append(typeURLs, typeURL)
+	}
+	sort.Strings(typeURLs)
+
+	h := fnv.New64a()
+	for _, typeURL := range typeURLs {
+		var serialized []string
+		for _, r := range contents[typeURL] {
+			// unlike the wire format, JSON expands Any fields and sorts map keys
+			b, err := protojson.Marshal(r)
+			if err != nil {
+				return "", err
+			}
+			serialized = append(serialized, string(b))
+		}
+		// the resources come out of maps in no particular order
+		sort.Strings(serialized)
+		h.Write([]byte(typeURL))
+		for _, b := range serialized {
+			h.Write([]byte(b))
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
 }
 
 func (cache *Cache) ClusterContents() []types.Resource {
 	var r []types.Resource
 
 	for _, c := range cache.Clusters {
-		r = append(r, resources.MakeCluster(c.Name))
+		r = append(r, resources.MakeCluster(c))
 	}
 
 	return r
 }
 
+// RouteContents builds the route configurations of every listener from the same routes.
 func (cache *Cache) RouteContents() []types.Resource {
+	var r []types.Resource
 
-	var routesArray []resources.Route
-	for _, r := range cache.Routes {
-		routesArray = append(routesArray, r)
+	for _, l := range cache.Listeners {
+		var routesArray []resources.Route
+		for _, rt := range cache.Routes {
+			routesArray = append(routesArray, rt)
+		}
+		for _, config := range resources.MakeRoutes(l, routesArray) {
+			r = append(r, config)
+		}
 	}
 
-	return []types.Resource{resources.MakeRoute(routesArray)}
+	return r
 }
 
 func (cache *Cache) ListenerContents() []types.Resource {
 	var r []types.Resource
 
 	for _, l := range cache.Listeners {
-		r = append(r, resources.MakeHTTPListener(l.Name, l.RouteNames[0], l.Address, l.Port))
+		r = append(r, resources.MakeHTTPListener(l))
 	}
 
 	return r
@@ -46,11 +123,23 @@ func (cache *Cache) EndpointsContents() []types.Resource {
 	var r []types.Resource
 
 	for _, c := range cache.Clusters {
+		if c.Type != resources.EDSCluster {
+			continue // DNS clusters carry their endpoints inline
+		}
 		r = append(r, resources.MakeEndpoint(c.Name, c.Endpoints))
 	}
 
 	return r
 }
+func (cache *Cache) SecretContents() []types.Resource {
+	var r []types.Resource
+
+	for _, s := range cache.Secrets {
+		r = append(r, resources.MakeSecret(s))
+	}
+
+	return r
+}
 func (cache *Cache) AddListener(name string, routeNames []string, address string, port uint32) {
 	cache.Listeners[name] = resources.Listener{
 		Name:       name,
@@ -1,10 +1,13 @@
 package manager
 
 import (
+	"fmt"
 	"github.com/spf13/pflag"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"kubernetes-controller/internal/annotations"
+	"kubernetes-controller/internal/envoy/parser"
+	"kubernetes-controller/internal/envoy/resources"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"time"
 )
@@ -24,6 +27,7 @@ type Config struct {
 	IngressNetV1Enabled      bool
 	IngressClassNetV1Enabled bool
 	ServiceEnabled           bool
+	GatewayEnabled           bool
 	LeaderElectionID         string
 
 	UpdateStatus bool
@@ -32,6 +36,38 @@ type Config struct {
 	ProbeAddr   string
 
 	TermDelay time.Duration
+
+	XDSAddress        string
+	ProxySyncInterval time.Duration
+
+	ProxyListenAddress string
+	ProxyHTTPPort      uint32
+	ProxyHTTPSPort     uint32
+	DNSLookupFamily    string
+	ClusterDomain      string
+	ProxyProtocol      bool
+	UseRemoteAddress   bool
+	XFFNumTrustedHops  uint32
+
+	TracingProvider           string
+	TracingService            string
+	TracingSampling           float64
+	TracingTags               []string
+	GenerateRequestID         bool
+	PreserveExternalRequestID bool
+
+	Compression []string
+
+	AccessLogPath       string
+	AccessLogFormatType string
+	AccessLogFormat     string
+	AccessLogService    string
+
+	RateLimitService         string
+	RateLimitDomain          string
+	RateLimitFailureModeDeny bool
+
+	ProxyService string
 }
 
 func (c *Config) FlagSet() *pflag.FlagSet {
@@ -39,10 +75,83 @@ func (c *Config) FlagSet() *pflag.FlagSet {
 	flagSet.StringVar(&c.KubeConfigPath, "kubeconfig", "C:\\Users\\longqing\\.kube\\config", "Path to the kubeconfig file.")
 
 	flagSet.StringVar(&c.IngressClassName, "ingress-class", annotations.DefaultIngressClass, `Name of the ingress class to route through this controller.`)
-	
+
 	flagSet.BoolVar(&c.IngressNetV1Enabled, "enable-controller-ingress-networkingv1", true, "Enable the networking.k8s.io/v1 Ingress controller.")
+	flagSet.BoolVar(&c.IngressClassNetV1Enabled, "enable-controller-ingress-class-networkingv1", true, "Enable the networking.k8s.io/v1 IngressClass controller.")
+	flagSet.BoolVar(&c.ServiceEnabled, "enable-controller-service", true, "Enable the controllers of Services and their endpoints, which Ingress backends are resolved against.")
+	flagSet.BoolVar(&c.GatewayEnabled, "enable-controller-gateway", true, "Enable the Gateway API controllers of GatewayClasses, Gateways, HTTPRoutes and BackendTLSPolicies, when their CRDs are installed.")
+
+	flagSet.StringVar(&c.XDSAddress, "xds-address", ":18000", "Address the xDS server serving the Envoy configuration listens on.")
+	flagSet.DurationVar(&c.ProxySyncInterval, "proxy-sync-interval", 3*time.Second, "Minimum interval between two updates of the Envoy configuration.")
+	flagSet.StringVar(&c.ProxyListenAddress, "proxy-listen-address", "0.0.0.0", `Address the Envoy listeners bind to. Use "::" to accept both IPv6 and IPv4 connections.`)
+	flagSet.Uint32Var(&c.ProxyHTTPPort, "proxy-http-port", 8080, "Port of the Envoy HTTP listener.")
+	flagSet.Uint32Var(&c.ProxyHTTPSPort, "proxy-https-port", 8443, "Port of the Envoy HTTPS listener serving the TLS hosts of Ingresses.")
+	flagSet.BoolVar(&c.ProxyProtocol, "proxy-protocol", false, "Expect a PROXY protocol header on connections to the Envoy listeners, as sent by some network load balancers.")
+	flagSet.BoolVar(&c.UseRemoteAddress, "use-remote-address", true, "Take the peer address of connections, rather than X-Forwarded-For, as the client address.")
+	flagSet.Uint32Var(&c.XFFNumTrustedHops, "xff-num-trusted-hops", 0, "Number of proxies in front of Envoy whose X-Forwarded-For entries are trusted to find the client address.")
+	flagSet.StringVar(&c.TracingProvider, "tracing-provider", "", `Tracing provider of the Envoy listeners, "opentelemetry" or "zipkin". Tracing is off when empty.`)
+	flagSet.StringVar(&c.TracingService, "tracing-service", "", "Trace collector Service, as namespace/name:port.")
+	flagSet.Float64Var(&c.TracingSampling, "tracing-sampling", 100, "Percentage of requests traced. Routes may override it with an annotation.")
+	flagSet.StringSliceVar(&c.TracingTags, "tracing-tags", nil, "Tags added to every span, as name=value or name=header:<header>.")
+	flagSet.BoolVar(&c.GenerateRequestID, "generate-request-id", true, "Set x-request-id on requests that have none.")
+	flagSet.BoolVar(&c.PreserveExternalRequestID, "preserve-external-request-id", false, "Keep the x-request-id of requests from external clients instead of replacing it.")
+	flagSet.StringSliceVar(&c.Compression, "compression", nil, `Algorithms responses are compressed with, "gzip" and "brotli". Routes may override it with an annotation.`)
+	flagSet.StringVar(&c.AccessLogPath, "access-log-path", "/dev/stdout", `File the Envoy access logs are written to, or "off". The IngressClass may override the access log flags with annotations.`)
+	flagSet.StringVar(&c.AccessLogFormatType, "access-log-format-type", "text", `Format of file access logs, "text" or "json".`)
+	flagSet.StringVar(&c.AccessLogFormat, "access-log-format", "", "Envoy format string of file access logs, or for the json type a JSON object of format strings.")
+	flagSet.StringVar(&c.AccessLogService, "access-log-service", "", "Envoy gRPC access log service, as namespace/name:port.")
+	flagSet.StringVar(&c.RateLimitService, "ratelimit-service", "", "Envoy RLS gRPC service used for global rate limiting, as namespace/name:port.")
+	flagSet.StringVar(&c.RateLimitDomain, "ratelimit-domain", "ingress", "Domain of the descriptors sent to the rate limit service.")
+	flagSet.BoolVar(&c.RateLimitFailureModeDeny, "ratelimit-failure-mode-deny", false, "Reject requests when the rate limit service cannot be reached.")
+	flagSet.StringVar(&c.ProxyService, "proxy-service", "", "Service of the Envoy proxies, as namespace/name. Its endpoints make up the local_cluster of zone-aware routing, which the Envoy bootstrap must name in cluster_manager.local_cluster_name; the proxies report their zone with --service-zone.")
+	flagSet.StringVar(&c.DNSLookupFamily, "dns-lookup-family", "v4_only", `DNS lookup family of upstream clusters, one of "auto", "v4_only", "v6_only", "v4_preferred" or "all".`)
+	flagSet.StringVar(&c.ClusterDomain, "cluster-domain", "cluster.local", "DNS domain of the Services of the cluster, which their DNS names end with.")
 	return flagSet
 }
+
+// Validate checks the flags that cannot be checked while they are parsed.
+func (c *Config) Validate() error {
+	if !resources.IsDNSLookupFamily(c.DNSLookupFamily) {
+		return fmt.Errorf("invalid --dns-lookup-family %q", c.DNSLookupFamily)
+	}
+	return nil
+}
+
+func (c *Config) ParserConfig() parser.Config {
+	return parser.Config{
+		IngressClass: c.IngressClassName,
+
+		ListenAddress:     c.ProxyListenAddress,
+		HTTPPort:          c.ProxyHTTPPort,
+		HTTPSPort:         c.ProxyHTTPSPort,
+		DNSLookupFamily:   c.DNSLookupFamily,
+		ClusterDomain:     c.ClusterDomain,
+		ProxyProtocol:     c.ProxyProtocol,
+		UseRemoteAddress:  c.UseRemoteAddress,
+		XFFNumTrustedHops: c.XFFNumTrustedHops,
+
+		TracingProvider:           c.TracingProvider,
+		TracingService:            c.TracingService,
+		TracingSampling:           c.TracingSampling,
+		TracingTags:               c.TracingTags,
+		GenerateRequestID:         c.GenerateRequestID,
+		PreserveExternalRequestID: c.PreserveExternalRequestID,
+
+		Compression: c.Compression,
+
+		AccessLogPath:       c.AccessLogPath,
+		AccessLogFormatType: c.AccessLogFormatType,
+		AccessLogFormat:     c.AccessLogFormat,
+		AccessLogService:    c.AccessLogService,
+
+		RateLimitService:         c.RateLimitService,
+		RateLimitDomain:          c.RateLimitDomain,
+		RateLimitFailureModeDeny: c.RateLimitFailureModeDeny,
+
+		ProxyService: c.ProxyService,
+	}
+}
+
 func (c *Config) GetKubeConfig() (*rest.Config, error) {
 	config, err := clientcmd.BuildConfigFromFlags(c.APIServerHost, c.KubeConfigPath)
 	if err != nil {
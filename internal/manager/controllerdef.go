@@ -3,11 +3,15 @@ package manager
 import (
 	"fmt"
 	"kubernetes-controller/internal/controllers/configuration"
+	"kubernetes-controller/internal/controllers/gateway"
+	ctrlutils "kubernetes-controller/internal/controllers/utils"
 	"kubernetes-controller/internal/store"
 	"kubernetes-controller/internal/util/kubernetes/object/status"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 type Controller interface {
@@ -34,6 +38,7 @@ func setupControllers(
 	mgr manager.Manager,
 	kubernetesStatusQueue *status.Queue,
 	cache *store.CacheStores,
+	syncer configuration.Syncer,
 	c *Config) ([]ControllerDef, error) {
 
 	restMapper := mgr.GetClient().RESTMapper()
@@ -41,6 +46,8 @@ func setupControllers(
 	if err != nil {
 		return nil, fmt.Errorf("ingress version picker failed: %w", err)
 	}
+	gatewayEnabled := c.GatewayEnabled && ctrlutils.CRDExists(restMapper, gatewayv1.SchemeGroupVersion.WithResource("httproutes"))
+	backendTLSPolicyEnabled := c.GatewayEnabled && ctrlutils.CRDExists(restMapper, gatewayv1alpha2.SchemeGroupVersion.WithResource("backendtlspolicies"))
 
 	controllers := []ControllerDef{
 		{
@@ -48,6 +55,7 @@ func setupControllers(
 			Controller: &configuration.NetV1IngressClassReconciler{
 				Client:           mgr.GetClient(),
 				Cache:            cache,
+				Syncer:           syncer,
 				Log:              ctrl.Log.WithName("controllers").WithName("IngressClass").WithName("netv1"),
 				Scheme:           mgr.GetScheme(),
 				CacheSyncTimeout: c.CacheSyncTimeout,
@@ -58,6 +66,7 @@ func setupControllers(
 			Controller: &configuration.NetV1IngressReconciler{
 				Client:                     mgr.GetClient(),
 				Cache:                      cache,
+				Syncer:                     syncer,
 				Log:                        ctrl.Log.WithName("controllers").WithName("Ingress").WithName("netv1"),
 				Scheme:                     mgr.GetScheme(),
 				IngressClassName:           c.IngressClassName,
@@ -70,6 +79,8 @@ func setupControllers(
 			Enabled: c.ServiceEnabled,
 			Controller: &configuration.CoreV1ServiceReconciler{
 				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
 				Log:              ctrl.Log.WithName("controllers").WithName("Service"),
 				Scheme:           mgr.GetScheme(),
 				CacheSyncTimeout: c.CacheSyncTimeout,
@@ -79,20 +90,101 @@ func setupControllers(
 			Enabled: c.ServiceEnabled,
 			Controller: &configuration.CoreV1EndpointsReconciler{
 				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
 				Log:              ctrl.Log.WithName("controllers").WithName("Endpoints"),
 				Scheme:           mgr.GetScheme(),
 				CacheSyncTimeout: c.CacheSyncTimeout,
 			},
 		},
+		{
+			Enabled: c.ServiceEnabled,
+			Controller: &configuration.DiscoveryV1EndpointSliceReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("EndpointSlice"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
+		{
+			Enabled: c.ServiceEnabled,
+			Controller: &configuration.CoreV1NodeReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("Node"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
 		{
 			Enabled: true,
 			Controller: &configuration.CoreV1SecretReconciler{
 				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
 				Log:              ctrl.Log.WithName("controllers").WithName("Secrets"),
 				Scheme:           mgr.GetScheme(),
 				CacheSyncTimeout: c.CacheSyncTimeout,
 			},
 		},
+		{
+			Enabled: true,
+			Controller: &configuration.CoreV1ConfigMapReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("ConfigMaps"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
+		{
+			Enabled: gatewayEnabled,
+			Controller: &gateway.GatewayV1GatewayClassReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("GatewayClass"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
+		{
+			Enabled: gatewayEnabled,
+			Controller: &gateway.GatewayV1GatewayReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("Gateway"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
+		{
+			Enabled: gatewayEnabled,
+			Controller: &gateway.GatewayV1HTTPRouteReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("HTTPRoute"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
+		{
+			Enabled: backendTLSPolicyEnabled,
+			Controller: &gateway.GatewayV1alpha2BackendTLSPolicyReconciler{
+				Client:           mgr.GetClient(),
+				Cache:            cache,
+				Syncer:           syncer,
+				Log:              ctrl.Log.WithName("controllers").WithName("BackendTLSPolicy"),
+				Scheme:           mgr.GetScheme(),
+				CacheSyncTimeout: c.CacheSyncTimeout,
+			},
+		},
 	}
 	return controllers, nil
 }
@@ -3,18 +3,37 @@ package manager
 import (
 	"context"
 	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"kubernetes-controller/internal/dataplane"
+	"kubernetes-controller/internal/envoy/parser"
 	"kubernetes-controller/internal/store"
 	"kubernetes-controller/internal/util/kubernetes/object/status"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 func Run(ctx context.Context, c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
 
 	setupLog := ctrl.Log.WithName("setup")
 
-	mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{})
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := gatewayv1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := gatewayv1alpha2.AddToScheme(scheme); err != nil {
+		return err
+	}
+	mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{Scheme: scheme})
 	if err != nil {
 		return fmt.Errorf("unable to start controller manager: %w", err)
 	}
@@ -27,13 +46,16 @@ func Run(ctx context.Context, c *Config) error {
 		setupLog.Info("status updates disabled, skipping status updater")
 	}
 
-	err = setupXdsServer(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to start xds server: %w", err)
-	}
+	snapshots := setupXdsServer(ctx, c, setupLog)
 
 	cache := store.NewCacheStores()
-	controllers, err := setupControllers(mgr, kubernetesStatusQueue, cache, c)
+	p := parser.NewParser(store.New(*cache, c.IngressClassName), c.ParserConfig())
+	syncer := dataplane.NewSynchronizer(p, snapshots, mgr.GetEventRecorderFor("inendless-ingress-controller"),
+		ctrl.Log.WithName("dataplane"), c.ProxySyncInterval)
+	if err := mgr.Add(syncer); err != nil {
+		return fmt.Errorf("unable to add the proxy synchronizer: %w", err)
+	}
+	controllers, err := setupControllers(mgr, kubernetesStatusQueue, cache, syncer, c)
 	if err != nil {
 		return fmt.Errorf("unable to setup controller as expected %w", err)
 	}
@@ -9,6 +9,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"kubernetes-controller/internal/envoy/xds"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 func setupLoggers(c *Config) (logrus.FieldLogger, logr.Logger, error) {
@@ -21,19 +24,26 @@ func setupControllerOptions(logger logr.Logger, c *Config, scheme *runtime.Schem
 
 	controllerOpts := ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     c.MetricsAddr,
-		Port:                   9443,
+		Metrics:                metricsserver.Options{BindAddress: c.MetricsAddr},
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
 		HealthProbeBindAddress: c.ProbeAddr,
 		LeaderElection:         leaderElection,
 		LeaderElectionID:       c.LeaderElectionID,
-		SyncPeriod:             &c.SyncPeriod,
+		Cache:                  ctrlcache.Options{SyncPeriod: &c.SyncPeriod},
 	}
 	return controllerOpts, nil
 }
 
-func setupXdsServer(ctx context.Context) error {
-	cache := cache.NewSnapshotCache(false, cache.IDHash{}, nil)
-	srv := serverv3.NewServer(ctx, cache, nil)
-	_ = xds.NewServer(srv)
-	return nil
+// setupXdsServer serves the snapshots of the returned cache to the proxies, until
+// the context is done.
+func setupXdsServer(ctx context.Context, c *Config, logger logr.Logger) cache.SnapshotCache {
+	snapshots := cache.NewSnapshotCache(false, xds.FleetHash{}, nil)
+	srv := serverv3.NewServer(ctx, snapshots, xds.NewCallbacks(logger.WithName("xds"), c.ProxyService != ""))
+	g := xds.NewServer(srv)
+	go func() {
+		if err := xds.Serve(ctx, g, c.XDSAddress); err != nil {
+			logger.Error(err, "xDS server stopped", "address", c.XDSAddress)
+		}
+	}()
+	return snapshots
 }
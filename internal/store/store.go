@@ -3,10 +3,13 @@ package store
 import (
 	"fmt"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/cache"
 	"reflect"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	"sort"
 	"strings"
 	"sync"
@@ -14,6 +17,7 @@ import (
 
 const (
 	IngressClassController = "inendless.com/ingress-controller"
+	GatewayClassController = "inendless.com/gateway-controller"
 )
 
 type ErrNotFound struct {
@@ -30,10 +34,16 @@ func (e ErrNotFound) Error() string {
 type Storer interface {
 	GetSecret(namespace, name string) (*corev1.Secret, error)
 	GetService(namespace, name string) (*corev1.Service, error)
+	GetConfigMap(namespace, name string) (*corev1.ConfigMap, error)
 	GetEndpointsForService(namespace, name string) (*corev1.Endpoints, error)
+	GetEndpointSlicesForService(namespace, name string) ([]*discoveryv1.EndpointSlice, error)
+	GetNode(name string) (*corev1.Node, error)
 	GetIngressClassV1(name string) (*netv1.IngressClass, error)
 	ListIngressesV1() []*netv1.Ingress
 	ListIngressClassesV1() []*netv1.IngressClass
+	ListGateways() []*gatewayv1.Gateway
+	ListHTTPRoutes() []*gatewayv1.HTTPRoute
+	ListBackendTLSPolicies() []*gatewayv1alpha2.BackendTLSPolicy
 }
 
 type Store struct {
@@ -47,7 +57,15 @@ type CacheStores struct {
 	IngressClassV1 cache.Store
 	Service        cache.Store
 	Secret         cache.Store
+	ConfigMap      cache.Store
 	Endpoint       cache.Store
+	EndpointSlice  cache.Indexer
+	Node           cache.Store
+
+	GatewayClass     cache.Store
+	Gateway          cache.Store
+	HTTPRoute        cache.Store
+	BackendTLSPolicy cache.Store
 
 	l *sync.RWMutex
 }
@@ -59,7 +77,15 @@ func NewCacheStores() *CacheStores {
 		IngressClassV1: cache.NewStore(clusterResourceKeyFunc),
 		Service:        cache.NewStore(keyFunc),
 		Secret:         cache.NewStore(keyFunc),
+		ConfigMap:      cache.NewStore(keyFunc),
 		Endpoint:       cache.NewStore(keyFunc),
+		EndpointSlice:  cache.NewIndexer(keyFunc, cache.Indexers{endpointSliceServiceIndex: endpointSliceService}),
+		Node:           cache.NewStore(clusterResourceKeyFunc),
+
+		GatewayClass:     cache.NewStore(clusterResourceKeyFunc),
+		Gateway:          cache.NewStore(keyFunc),
+		HTTPRoute:        cache.NewStore(keyFunc),
+		BackendTLSPolicy: cache.NewStore(keyFunc),
 
 		l: &sync.RWMutex{},
 	}
@@ -77,8 +103,22 @@ func (c CacheStores) Get(obj runtime.Object) (item interface{}, exists bool, err
 		return c.Service.Get(obj)
 	case *corev1.Secret:
 		return c.Secret.Get(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Get(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Get(obj)
+	case *discoveryv1.EndpointSlice:
+		return c.EndpointSlice.Get(obj)
+	case *corev1.Node:
+		return c.Node.Get(obj)
+	case *gatewayv1.GatewayClass:
+		return c.GatewayClass.Get(obj)
+	case *gatewayv1.Gateway:
+		return c.Gateway.Get(obj)
+	case *gatewayv1.HTTPRoute:
+		return c.HTTPRoute.Get(obj)
+	case *gatewayv1alpha2.BackendTLSPolicy:
+		return c.BackendTLSPolicy.Get(obj)
 	default:
 		return nil, false, fmt.Errorf("%T is not a supported cache object type", obj)
 	}
@@ -97,8 +137,22 @@ func (c CacheStores) Add(obj runtime.Object) error {
 		return c.Service.Add(obj)
 	case *corev1.Secret:
 		return c.Secret.Add(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Add(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Add(obj)
+	case *discoveryv1.EndpointSlice:
+		return c.EndpointSlice.Add(obj)
+	case *corev1.Node:
+		return c.Node.Add(obj)
+	case *gatewayv1.GatewayClass:
+		return c.GatewayClass.Add(obj)
+	case *gatewayv1.Gateway:
+		return c.Gateway.Add(obj)
+	case *gatewayv1.HTTPRoute:
+		return c.HTTPRoute.Add(obj)
+	case *gatewayv1alpha2.BackendTLSPolicy:
+		return c.BackendTLSPolicy.Add(obj)
 	default:
 		return fmt.Errorf("cannot add unsupported kind %q to the store", obj.GetObjectKind().GroupVersionKind())
 	}
@@ -116,8 +170,22 @@ func (c CacheStores) Delete(obj runtime.Object) error {
 		return c.Service.Delete(obj)
 	case *corev1.Secret:
 		return c.Secret.Delete(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Delete(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Delete(obj)
+	case *discoveryv1.EndpointSlice:
+		return c.EndpointSlice.Delete(obj)
+	case *corev1.Node:
+		return c.Node.Delete(obj)
+	case *gatewayv1.GatewayClass:
+		return c.GatewayClass.Delete(obj)
+	case *gatewayv1.Gateway:
+		return c.Gateway.Delete(obj)
+	case *gatewayv1.HTTPRoute:
+		return c.HTTPRoute.Delete(obj)
+	case *gatewayv1alpha2.BackendTLSPolicy:
+		return c.BackendTLSPolicy.Delete(obj)
 	default:
 		return fmt.Errorf("cannot delete unsupported kind %q from the store", obj.GetObjectKind().GroupVersionKind())
 
@@ -154,6 +222,18 @@ func (s Store) GetService(namespace, name string) (*corev1.Service, error) {
 	return service.(*corev1.Service), nil
 }
 
+func (s Store) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	configMap, exists, err := s.stores.ConfigMap.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound{fmt.Sprintf("ConfigMap %v not found", key)}
+	}
+	return configMap.(*corev1.ConfigMap), nil
+}
+
 func (s Store) ListIngressesV1() []*netv1.Ingress {
 	var ingresses []*netv1.Ingress
 	for _, item := range s.stores.IngressV1.List() {
@@ -189,6 +269,59 @@ func (s Store) ListIngressClassesV1() []*netv1.IngressClass {
 	return classes
 }
 
+// ListGateways returns the Gateways of the GatewayClasses of the controller.
+func (s Store) ListGateways() []*gatewayv1.Gateway {
+	var gateways []*gatewayv1.Gateway
+	for _, item := range s.stores.Gateway.List() {
+		gw, ok := item.(*gatewayv1.Gateway)
+		if !ok {
+			continue
+		}
+		item, exists, err := s.stores.GatewayClass.GetByKey(string(gw.Spec.GatewayClassName))
+		if err != nil || !exists {
+			continue
+		}
+		if class, ok := item.(*gatewayv1.GatewayClass); !ok || class.Spec.ControllerName != GatewayClassController {
+			continue
+		}
+		gateways = append(gateways, gw)
+	}
+	sort.SliceStable(gateways, func(i, j int) bool {
+		return strings.Compare(gateways[i].Namespace+"/"+gateways[i].Name, gateways[j].Namespace+"/"+gateways[j].Name) < 0
+	})
+	return gateways
+}
+
+func (s Store) ListHTTPRoutes() []*gatewayv1.HTTPRoute {
+	var routes []*gatewayv1.HTTPRoute
+	for _, item := range s.stores.HTTPRoute.List() {
+		route, ok := item.(*gatewayv1.HTTPRoute)
+		if !ok {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return strings.Compare(routes[i].Namespace+"/"+routes[i].Name, routes[j].Namespace+"/"+routes[j].Name) < 0
+	})
+	return routes
+}
+
+func (s Store) ListBackendTLSPolicies() []*gatewayv1alpha2.BackendTLSPolicy {
+	var policies []*gatewayv1alpha2.BackendTLSPolicy
+	for _, item := range s.stores.BackendTLSPolicy.List() {
+		policy, ok := item.(*gatewayv1alpha2.BackendTLSPolicy)
+		if !ok {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return strings.Compare(policies[i].Namespace+"/"+policies[i].Name, policies[j].Namespace+"/"+policies[j].Name) < 0
+	})
+	return policies
+}
+
 func (s Store) GetEndpointsForService(namespace, name string) (*corev1.Endpoints, error) {
 	key := fmt.Sprintf("%v/%v", namespace, name)
 	eps, exists, err := s.stores.Endpoint.GetByKey(key)
@@ -201,6 +334,37 @@ func (s Store) GetEndpointsForService(namespace, name string) (*corev1.Endpoints
 	return eps.(*corev1.Endpoints), nil
 }
 
+func (s Store) GetEndpointSlicesForService(namespace, name string) ([]*discoveryv1.EndpointSlice, error) {
+	items, err := s.stores.EndpointSlice.ByIndex(endpointSliceServiceIndex, namespace+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	var slices []*discoveryv1.EndpointSlice
+	for _, item := range items {
+		if slice, ok := item.(*discoveryv1.EndpointSlice); ok {
+			slices = append(slices, slice)
+		}
+	}
+	if len(slices) == 0 {
+		return nil, ErrNotFound{fmt.Sprintf("EndpointSlices for service %v/%v not found", namespace, name)}
+	}
+	sort.SliceStable(slices, func(i, j int) bool {
+		return strings.Compare(slices[i].Name, slices[j].Name) < 0
+	})
+	return slices, nil
+}
+
+func (s Store) GetNode(name string) (*corev1.Node, error) {
+	node, exists, err := s.stores.Node.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound{fmt.Sprintf("Node %v not found", name)}
+	}
+	return node.(*corev1.Node), nil
+}
+
 func (s Store) GetIngressClassV1(name string) (*netv1.IngressClass, error) {
 	p, exists, err := s.stores.IngressClassV1.GetByKey(name)
 	if err != nil {
@@ -212,6 +376,22 @@ func (s Store) GetIngressClassV1(name string) (*netv1.IngressClass, error) {
 	return p.(*netv1.IngressClass), nil
 }
 
+// endpointSliceServiceIndex indexes the EndpointSlices by the namespace/name of the
+// Service of their kubernetes.io/service-name label.
+const endpointSliceServiceIndex = "service"
+
+func endpointSliceService(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, nil
+	}
+	name := slice.Labels[discoveryv1.LabelServiceName]
+	if name == "" {
+		return nil, nil
+	}
+	return []string{slice.Namespace + "/" + name}, nil
+}
+
 func keyFunc(obj interface{}) (string, error) {
 	v := reflect.Indirect(reflect.ValueOf(obj))
 	name := v.FieldByName("Name")